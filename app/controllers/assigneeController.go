@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/pkg/utils"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTodoAssignees は Todo に関わっているユーザーと役割を返します。
+func (mc *TodoController) GetTodoAssignees(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      assignees, err := mc.model(c).GetTodoAssignees(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertAssigneesToOutput(assignees)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// AddTodoAssignee は Todo にユーザーを追加します。既に関わっているユーザーの場合は役割を変更します。
+func (mc *TodoController) AddTodoAssignee(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.AssigneeInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      todo, err := mc.model(c).AddTodoAssignee(uint(id), version, input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodoToOutput(todo)
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// SetTodoAssignees は Todo に関わっているユーザーをまとめて置き換えます。
+func (mc *TodoController) SetTodoAssignees(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.SetAssigneesInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      todo, err := mc.model(c).ReplaceTodoAssignees(uint(id), version, input.Assignees)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodoToOutput(todo)
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// RemoveTodoAssignee は Todo からユーザーを外します。持ち主は外せません。
+func (mc *TodoController) RemoveTodoAssignee(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+      userID, err := strconv.Atoi(c.Param("user_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+            return
+      }
+
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      todo, err := mc.model(c).RemoveTodoAssignee(uint(id), version, uint(userID))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodoToOutput(todo)
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
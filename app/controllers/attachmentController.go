@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"app/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead は multipart の境界やヘッダの分として、ファイルの最大サイズに上乗せして受け付けるバイト数です。
+const multipartOverhead = 1 << 20
+
+// attachmentParams は :id（Todo）と :attachment_id（添付ファイル）を取り出します。
+func attachmentParams(c *gin.Context) (uint, uint, bool) {
+      todoID, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return 0, 0, false
+      }
+      attachmentID, err := strconv.Atoi(c.Param("attachment_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+            return 0, 0, false
+      }
+      return uint(todoID), uint(attachmentID), true
+}
+
+// GetAttachments は Todo の添付ファイルを、ダウンロード用の署名付き URL と一緒に返します。
+func (mc *TodoController) GetAttachments(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      attachments, err := mc.model(c).GetAttachments(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output, err := mc.model(c).ConvertAttachmentsToOutput(attachments)
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// CreateAttachment は multipart の file を Todo に添付します。
+// サイズの上限は ATTACHMENT_MAX_BYTES で、ファイルの種類は拡張子ではなく中身から判定します。
+func (mc *TodoController) CreateAttachment(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      // 上限を大きく超えるリクエストは、最後まで読まずに打ち切る
+      c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, models.AttachmentMaxBytes()+multipartOverhead)
+      header, err := c.FormFile("file")
+      if err != nil {
+            var maxBytesErr *http.MaxBytesError
+            if errors.As(err, &maxBytesErr) {
+                  c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": models.ErrAttachmentTooLarge.Error()})
+                  return
+            }
+            c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+            return
+      }
+      file, err := header.Open()
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+      defer file.Close()
+
+      attachment, err := mc.model(c).CreateAttachment(uint(id), header.Filename, file, header.Size)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output, err := mc.model(c).ConvertAttachmentToOutput(attachment)
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) GetAttachment(c *gin.Context) {
+      todoID, attachmentID, ok := attachmentParams(c)
+      if !ok {
+            return
+      }
+
+      attachment, err := mc.model(c).GetAttachment(todoID, attachmentID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output, err := mc.model(c).ConvertAttachmentToOutput(attachment)
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// DownloadAttachment は添付ファイルの署名付き URL にリダイレクトします。
+func (mc *TodoController) DownloadAttachment(c *gin.Context) {
+      todoID, attachmentID, ok := attachmentParams(c)
+      if !ok {
+            return
+      }
+
+      attachment, err := mc.model(c).GetAttachment(todoID, attachmentID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      url, _, err := mc.model(c).AttachmentURL(attachment)
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+
+      c.Redirect(http.StatusFound, url)
+}
+
+func (mc *TodoController) DeleteAttachment(c *gin.Context) {
+      todoID, attachmentID, ok := attachmentParams(c)
+      if !ok {
+            return
+      }
+
+      if err := mc.model(c).DeleteAttachment(todoID, attachmentID); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
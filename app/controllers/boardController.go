@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/pkg/utils"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (mc *TodoController) GetBoards(c *gin.Context) {
+      boards, err := mc.model(c).GetBoards()
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+
+      output := []requests.BoardOutput{}
+      for _, board := range boards {
+            output = append(output, mc.model(c).ConvertBoardToOutput(board, nil))
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) CreateBoard(c *gin.Context) {
+      var input requests.CreateBoardInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      board, err := mc.model(c).CreateBoard(input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertBoardToOutput(board, nil)})
+}
+
+// GetBoard はボードの列と、列ごとに並び順どおりの Todo を返します。
+func (mc *TodoController) GetBoard(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      board, columns, err := mc.model(c).GetBoard(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertBoardToOutput(board, columns)})
+}
+
+func (mc *TodoController) DeleteBoard(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      if err := mc.model(c).DeleteBoard(uint(id)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// MoveTodo はドラッグ&ドロップで Todo を別の列・位置へ移動したときに呼ばれます。
+func (mc *TodoController) MoveTodo(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.MoveTodoInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      todo, err := mc.model(c).MoveTodo(uint(id), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodoToOutput(todo)
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"app/models"
+	"app/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCalendarFeed は GET /ical/:token.ics で、トークンの持ち主が担当している Todo を iCalendar 形式で返します。
+// ?component=vevent を付けると VTODO の代わりに VEVENT で返します。
+func (mc *TodoController) GetCalendarFeed(c *gin.Context) {
+      token, ok := strings.CutSuffix(c.Param("token"), ".ics")
+      if !ok {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+            return
+      }
+
+      user, err := mc.model(c).GetUserByCalendarToken(token)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      todos, err := mc.model(c).GetCalendarTodos(user.ID)
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+      calendar, err := mc.model(c).ConvertTodosToICal(todos, c.DefaultQuery("component", models.CalendarComponentTodo), c.Request.Host)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar))
+}
+
+// RegenerateCalendarToken はフィード用のトークンを作り直し、購読用の URL を返します。以前の URL は使えなくなります。
+func (mc *TodoController) RegenerateCalendarToken(c *gin.Context) {
+      token, err := mc.model(c).RegenerateCalendarToken(c.GetUint(middleware.UserIDKey))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      scheme := "http"
+      if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+            scheme = "https"
+      }
+      c.JSON(http.StatusOK, gin.H{"data": gin.H{
+            "token": token,
+            "url":   scheme + "://" + c.Request.Host + "/ical/" + token + ".ics",
+      }})
+}
+
+// RevokeCalendarToken はフィード用のトークンを無効にします。
+func (mc *TodoController) RevokeCalendarToken(c *gin.Context) {
+      if err := mc.model(c).RevokeCalendarToken(c.GetUint(middleware.UserIDKey)); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
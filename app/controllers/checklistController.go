@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checklistParams は :id（Todo）と :item_id（チェックリストの項目）を取り出します。
+func checklistParams(c *gin.Context) (uint, uint, bool) {
+      todoID, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return 0, 0, false
+      }
+      itemID, err := strconv.Atoi(c.Param("item_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+            return 0, 0, false
+      }
+      return uint(todoID), uint(itemID), true
+}
+
+func (mc *TodoController) GetChecklist(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      items, err := mc.model(c).GetChecklistItems(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertChecklistToOutput(items)})
+}
+
+func (mc *TodoController) CreateChecklistItem(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.CreateChecklistItemInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      item, err := mc.model(c).CreateChecklistItem(uint(id), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertChecklistItemToOutput(item)})
+}
+
+func (mc *TodoController) UpdateChecklistItem(c *gin.Context) {
+      todoID, itemID, ok := checklistParams(c)
+      if !ok {
+            return
+      }
+
+      var input requests.UpdateChecklistItemInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      item, err := mc.model(c).UpdateChecklistItem(todoID, itemID, input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertChecklistItemToOutput(item)})
+}
+
+func (mc *TodoController) DeleteChecklistItem(c *gin.Context) {
+      todoID, itemID, ok := checklistParams(c)
+      if !ok {
+            return
+      }
+
+      if err := mc.model(c).DeleteChecklistItem(todoID, itemID); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// ReorderChecklist はチェックリストの項目を、指定された ID の順番に並び替えます。
+func (mc *TodoController) ReorderChecklist(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.ReorderChecklistInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      items, err := mc.model(c).ReorderChecklist(uint(id), input.ItemIDs)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertChecklistToOutput(items)})
+}
+
+// PromoteChecklistItem はチェックリストの項目をサブタスクに変換し、作成されたサブタスクを返します。
+func (mc *TodoController) PromoteChecklistItem(c *gin.Context) {
+      todoID, itemID, ok := checklistParams(c)
+      if !ok {
+            return
+      }
+
+      todo, err := mc.model(c).PromoteChecklistItem(todoID, itemID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTodoToOutput(todo)})
+}
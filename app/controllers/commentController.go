@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetComments は Todo のコメントをスレッドごとに返します。?page=1&per_page=20 でページを指定します。
+func (mc *TodoController) GetComments(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var query requests.PageQuery
+      if err := c.ShouldBindQuery(&query); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      comments, page, err := mc.model(c).GetComments(uint(id), query)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertCommentsToOutput(comments), "pagination": page})
+}
+
+func (mc *TodoController) GetComment(c *gin.Context) {
+      todoID, commentID, ok := commentParams(c)
+      if !ok {
+            return
+      }
+
+      comment, err := mc.model(c).GetComment(todoID, commentID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertCommentToOutput(comment)})
+}
+
+// CreateComment はコメントを作成します。parent_id を指定すると返信になります。
+func (mc *TodoController) CreateComment(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.CreateCommentInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      comment, err := mc.model(c).CreateComment(uint(id), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertCommentToOutput(comment)})
+}
+
+// UpdateComment はコメントの本文を変更します。書いた本人だけが変更できます。
+func (mc *TodoController) UpdateComment(c *gin.Context) {
+      todoID, commentID, ok := commentParams(c)
+      if !ok {
+            return
+      }
+
+      var input requests.UpdateCommentInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      comment, err := mc.model(c).UpdateComment(todoID, commentID, input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertCommentToOutput(comment)})
+}
+
+func (mc *TodoController) DeleteComment(c *gin.Context) {
+      todoID, commentID, ok := commentParams(c)
+      if !ok {
+            return
+      }
+
+      if err := mc.model(c).DeleteComment(todoID, commentID); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// GetCommentHistory はコメントの本文の履歴を古い順に返します。
+func (mc *TodoController) GetCommentHistory(c *gin.Context) {
+      todoID, commentID, ok := commentParams(c)
+      if !ok {
+            return
+      }
+
+      revisions, err := mc.model(c).GetCommentHistory(todoID, commentID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertCommentRevisionsToOutput(revisions)})
+}
+
+func commentParams(c *gin.Context) (uint, uint, bool) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return 0, 0, false
+      }
+      commentID, err := strconv.Atoi(c.Param("comment_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+            return 0, 0, false
+      }
+      return uint(id), uint(commentID), true
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/pkg/utils"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTodoDependencies は Todo が完了を待っている Todo（depends_on）と、この Todo の完了を待っている Todo（blocking）を返します。
+func (mc *TodoController) GetTodoDependencies(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      dependencies, err := mc.model(c).GetTodoDependencies(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTodoDependenciesToOutput(dependencies)})
+}
+
+// AddTodoDependency は Todo が depends_on_id の Todo の完了を待つようにします。循環する場合は 422 を返します。If-Match が必要です。
+func (mc *TodoController) AddTodoDependency(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      var input requests.AddTodoDependencyInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      todo, err := mc.model(c).AddTodoDependency(uint(id), version, input.DependsOnID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTodoToOutput(todo)})
+}
+
+// RemoveTodoDependency は Todo が depends_on_id の Todo を待たないようにします。If-Match が必要です。
+func (mc *TodoController) RemoveTodoDependency(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+      dependsOnID, err := strconv.Atoi(c.Param("depends_on_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid depends_on ID"})
+            return
+      }
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      todo, err := mc.model(c).RemoveTodoDependency(uint(id), version, uint(dependsOnID))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTodoToOutput(todo)})
+}
+
+// GetProjectCriticalPath はプロジェクトの未完了の Todo の予定と、全体の終わりを決めている Todo の並び（クリティカルパス）を返します。
+func (mc *TodoController) GetProjectCriticalPath(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      path, err := mc.model(c).GetProjectCriticalPath(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertCriticalPathToOutput(path)})
+}
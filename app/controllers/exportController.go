@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportTodos は GET /api/todos/export?format=csv|json|md|xlsx で、一覧と同じ条件で絞り込んだ Todo をファイルとして返します。
+// Todo は少しずつ読み込みながら書き出すので、件数が多くてもすべてをメモリに載せることはありません。
+func (mc *TodoController) ExportTodos(c *gin.Context) {
+      var query requests.TodoListQuery
+      if err := c.ShouldBindQuery(&query); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      export, err := mc.model(c).NewTodoExport(c.DefaultQuery("format", "csv"), query)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.Header("Content-Type", export.ContentType())
+      c.Header("Content-Disposition", `attachment; filename="`+export.FileName()+`"`)
+      c.Status(http.StatusOK)
+      // 書き出しを始めた後はステータスコードを変えられないので、エラーはログに残して途中で打ち切る
+      if err := export.Write(c.Writer); err != nil {
+            log.Printf("export todos: %v", err)
+            c.Abort()
+      }
+}
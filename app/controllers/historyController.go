@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTodoHistory は Todo に対して行われた操作を、誰がいつどの項目をどう変えたか新しい順に返します。
+// ?page=1&per_page=20 でページを指定します。
+func (mc *TodoController) GetTodoHistory(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var query requests.PageQuery
+      if err := c.ShouldBindQuery(&query); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      events, page, err := mc.model(c).GetTodoHistory(uint(id), query)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTodoEventsToOutput(events), "pagination": page})
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"app/models"
+	"app/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportTodos は POST /api/todos/import で、アップロードされたファイル（multipart の file）から Todo を取り込みます。
+// format（csv、json、ics）を省略した場合はファイルの拡張子で判断します。CSV の列は mapping（JSON のオブジェクト）で対応付けます。
+// dry_run=true の場合は検証だけを行い、行ごとの誤りを返します。誤りが 1 件でもあれば 422 を返し、1 件も取り込みません。
+// 件数が多い場合はバックグラウンドで取り込み、202 と進捗を確認するためのジョブを返します。
+func (mc *TodoController) ImportTodos(c *gin.Context) {
+      header, err := c.FormFile("file")
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+            return
+      }
+      format := strings.ToLower(c.PostForm("format"))
+      if format == "" {
+            format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+      }
+      var mapping map[string]string
+      if value := c.PostForm("mapping"); value != "" {
+            if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+                  c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON object: " + err.Error()})
+                  return
+            }
+      }
+      dryRun := false
+      if value := c.DefaultPostForm("dry_run", c.Query("dry_run")); value != "" {
+            if dryRun, err = strconv.ParseBool(value); err != nil {
+                  c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+                  return
+            }
+      }
+
+      file, err := header.Open()
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+      defer file.Close()
+      rows, err := models.ParseImportFile(format, file, mapping)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      model := mc.model(c)
+      result, err := model.ImportTodos(c.GetUint(middleware.UserIDKey), format, rows, dryRun)
+      if errors.Is(err, models.ErrImportRowsInvalid) {
+            c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "data": model.ConvertImportResultToOutput(result)})
+            return
+      }
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := model.ConvertImportResultToOutput(result)
+
+      if result.Job != nil {
+            c.JSON(http.StatusAccepted, gin.H{"data": output})
+            return
+      }
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// GetImportJob はバックグラウンドで行っている取り込みの進捗を返します。
+func (mc *TodoController) GetImportJob(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("job_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      job, err := mc.model(c).GetImportJob(c.GetUint(middleware.UserIDKey), uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertImportJobToOutput(job)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/models"
+	"app/pkg/middleware"
+	"app/pkg/utils"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWorkspaceInvitation はメールアドレスを指定してワークスペースに招待します。レスポンスの link を招待する相手に送ります。
+func (mc *TodoController) CreateWorkspaceInvitation(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.InvitationInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      invitation, token, err := mc.Model.CreateWorkspaceInvitation(uint(id), c.GetUint(middleware.UserIDKey), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertInvitationToOutput(invitation)
+      output.Link = models.InvitationLink(token)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) GetWorkspaceInvitations(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      invitations, err := mc.Model.GetWorkspaceInvitations(uint(id), c.GetUint(middleware.UserIDKey))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertInvitationsToOutput(invitations)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// RevokeWorkspaceInvitation は返事待ちの招待を取り消します。取り消した招待のリンクは使えなくなります。
+func (mc *TodoController) RevokeWorkspaceInvitation(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+      invitationID, err := strconv.Atoi(c.Param("invitation_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+            return
+      }
+
+      if err := mc.Model.RevokeWorkspaceInvitation(uint(id), c.GetUint(middleware.UserIDKey), uint(invitationID)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// GetInvitation は招待リンクのトークンから招待の内容を返します。ログインしていなくても使えます。
+func (mc *TodoController) GetInvitation(c *gin.Context) {
+      invitation, err := mc.Model.GetInvitation(c.Param("token"))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertInvitationToOutput(invitation)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// AcceptInvitation はログインしているユーザーとして招待を承諾します。
+func (mc *TodoController) AcceptInvitation(c *gin.Context) {
+      member, err := mc.Model.AcceptInvitation(c.Param("token"), c.GetUint(middleware.UserIDKey))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertWorkspaceToOutput(member)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// SignUpWithInvitation はアカウントを持っていない人が、招待されたメールアドレスでサインアップして招待を承諾します。
+func (mc *TodoController) SignUpWithInvitation(c *gin.Context) {
+      var input requests.InvitationSignUpInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      user, member, err := mc.Model.SignUpWithInvitation(c.Param("token"), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      token, err := utils.GenerateToken(user.ID)
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{
+                  "message": "Failed to sign up",
+            })
+            return
+      }
+
+      var output requests.AuthOutput
+      output.ID = user.ID
+      output.Name = user.Name
+      output.Email = user.Email
+      output.TimeZone = user.TimeZone
+
+      // Cookieの有効期限を設定
+      cookieMaxAge := 60 * 60 * 24 * 30 // 30日
+      // Cookieにトークンをセット
+      c.SetCookie("token", token, cookieMaxAge, "/", "localhost", false, true)
+
+      c.JSON(http.StatusOK, gin.H{
+            "data": map[string]interface{}{
+                  "user":      output,
+                  "workspace": mc.Model.ConvertWorkspaceToOutput(member),
+            },
+      })
+}
+
+// DeclineInvitation は招待を辞退します。ログインしていなくても、招待リンクのトークンだけで辞退できます。
+func (mc *TodoController) DeclineInvitation(c *gin.Context) {
+      if err := mc.Model.DeclineInvitation(c.Param("token")); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
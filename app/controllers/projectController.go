@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireTodoWrite は /todos/:id 以下の更新系のリクエストで、Todo のプロジェクトのメンバーかどうかを確認するミドルウェアです。
+// プロジェクトに属さない Todo は、ワークスペースのメンバーなら誰でも変更できます。
+func (mc *TodoController) RequireTodoWrite(c *gin.Context) {
+      if c.Request.Method == http.MethodGet || !strings.HasPrefix(c.FullPath(), "/api/todos/:id") {
+            c.Next()
+            return
+      }
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            // ID の形式はハンドラーで確認する
+            c.Next()
+            return
+      }
+      if err := mc.model(c).CheckTodoWrite(uint(id)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            c.Abort()
+            return
+      }
+
+      c.Next()
+}
+
+func (mc *TodoController) GetProjects(c *gin.Context) {
+      var query requests.ProjectListQuery
+      if err := c.ShouldBindQuery(&query); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      projects, err := mc.model(c).GetProjects(query.IncludeArchived)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectsToOutput(projects)})
+}
+
+func (mc *TodoController) GetProject(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      project, err := mc.model(c).GetProjectByID(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectToOutput(project)})
+}
+
+// CreateProject はプロジェクトを作成します。作成したユーザーがマネージャーになります。
+func (mc *TodoController) CreateProject(c *gin.Context) {
+      var input requests.CreateProjectInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      project, err := mc.model(c).CreateProject(input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectToOutput(project)})
+}
+
+func (mc *TodoController) UpdateProject(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.UpdateProjectInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      project, err := mc.model(c).UpdateProject(uint(id), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectToOutput(project)})
+}
+
+// ArchiveProject はプロジェクトをアーカイブします。プロジェクトの Todo は通常の一覧に出てこなくなります。
+func (mc *TodoController) ArchiveProject(c *gin.Context) {
+      mc.setProjectArchived(c, true)
+}
+
+// UnarchiveProject はアーカイブしたプロジェクトを元に戻します。
+func (mc *TodoController) UnarchiveProject(c *gin.Context) {
+      mc.setProjectArchived(c, false)
+}
+
+func (mc *TodoController) setProjectArchived(c *gin.Context, archived bool) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      project, err := mc.model(c).SetProjectArchived(uint(id), archived)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectToOutput(project)})
+}
+
+// DeleteProject はプロジェクトを削除します。プロジェクトの Todo は削除されず、プロジェクトに属さない Todo になります。
+func (mc *TodoController) DeleteProject(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      if err := mc.model(c).DeleteProject(uint(id)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// GetProjectStats はプロジェクトの進み具合（完了した割合、期限切れの件数、ステータスごとの件数）を返します。
+func (mc *TodoController) GetProjectStats(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      stats, err := mc.model(c).GetProjectStats(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectStatsToOutput(stats)})
+}
+
+func (mc *TodoController) GetProjectMembers(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      members, err := mc.model(c).GetProjectMembers(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectMembersToOutput(members)})
+}
+
+func (mc *TodoController) AddProjectMember(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.ProjectMemberInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      member, err := mc.model(c).AddProjectMember(uint(id), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectMemberToOutput(member)})
+}
+
+func (mc *TodoController) UpdateProjectMember(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+      userID, err := strconv.Atoi(c.Param("user_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+            return
+      }
+
+      var input requests.ProjectMemberRoleInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      member, err := mc.model(c).UpdateProjectMember(uint(id), uint(userID), input.Role)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertProjectMemberToOutput(member)})
+}
+
+// RemoveProjectMember はメンバーをプロジェクトから外します。自分の ID を指定するとプロジェクトから抜けます。
+func (mc *TodoController) RemoveProjectMember(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+      userID, err := strconv.Atoi(c.Param("user_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+            return
+      }
+
+      if err := mc.model(c).RemoveProjectMember(uint(id), uint(userID)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
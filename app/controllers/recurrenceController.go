@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/pkg/utils"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTodoRecurrence は Todo の繰り返しのルールを返します。
+func (mc *TodoController) GetTodoRecurrence(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      series, err := mc.model(c).GetTodoRecurrence(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output, err := mc.model(c).ConvertRecurrenceToOutput(series)
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// SetTodoRecurrence は Todo を繰り返しにする、または繰り返しのルールを変更します。
+func (mc *TodoController) SetTodoRecurrence(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.SetRecurrenceInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      series, err := mc.model(c).SetTodoRecurrence(uint(id), version, input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output, err := mc.model(c).ConvertRecurrenceToOutput(series)
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+
+      c.Header("ETag", utils.ETag(uint(id), version+1))
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// StopTodoRecurrence はこの回で繰り返しを終わらせます。
+func (mc *TodoController) StopTodoRecurrence(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      if err := mc.model(c).StopTodoRecurrence(uint(id), version); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.Header("ETag", utils.ETag(uint(id), version+1))
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/pkg/middleware"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetReminderRules はログインしているユーザーのリマインダーの設定を返します。
+func (mc *TodoController) GetReminderRules(c *gin.Context) {
+      rules, err := mc.model(c).GetReminderRules(c.GetUint(middleware.UserIDKey))
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertReminderRulesToOutput(rules)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) CreateReminderRule(c *gin.Context) {
+      var input requests.ReminderRuleInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      rule, err := mc.model(c).CreateReminderRule(c.GetUint(middleware.UserIDKey), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertReminderRuleToOutput(rule)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) UpdateReminderRule(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.ReminderRuleInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      rule, err := mc.model(c).UpdateReminderRule(c.GetUint(middleware.UserIDKey), uint(id), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertReminderRuleToOutput(rule)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) DeleteReminderRule(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      if err := mc.model(c).DeleteReminderRule(c.GetUint(middleware.UserIDKey), uint(id)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
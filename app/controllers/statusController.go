@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/pkg/utils"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (mc *TodoController) GetStatuses(c *gin.Context) {
+      statuses, err := mc.model(c).GetStatuses()
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertStatusesToOutput(statuses)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) CreateStatus(c *gin.Context) {
+      var input requests.CreateStatusInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      status, err := mc.model(c).CreateStatus(input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertStatusToOutput(status)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) UpdateStatus(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.UpdateStatusInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      status, err := mc.model(c).UpdateStatus(uint(id), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertStatusToOutput(status)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) DeleteStatus(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      if err := mc.model(c).DeleteStatus(uint(id)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// SetStatusTransitions はステータスから遷移できるステータスをまとめて置き換えます。
+func (mc *TodoController) SetStatusTransitions(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.SetStatusTransitionsInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      status, err := mc.model(c).SetStatusTransitions(uint(id), input.StatusIDs)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertStatusToOutput(status)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// ChangeTodoStatus は Todo のステータスを変更します。許可されていない遷移の場合は 422 を返します。
+func (mc *TodoController) ChangeTodoStatus(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.ChangeTodoStatusInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      todo, err := mc.model(c).ChangeTodoStatus(uint(id), version, input.StatusID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodoToOutput(todo)
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// GetTodoStatusHistory は Todo がいつどのステータスに入ったかを返します。
+func (mc *TodoController) GetTodoStatusHistory(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      histories, err := mc.model(c).GetTodoStatusHistory(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      output := []requests.TodoStatusHistoryOutput{}
+      for _, history := range histories {
+            output = append(output, requests.TodoStatusHistoryOutput{
+                  StatusID:   history.StatusID,
+                  StatusName: history.Status.Name,
+                  EnteredAt:  history.EnteredAt,
+                  LeftAt:     history.LeftAt,
+            })
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
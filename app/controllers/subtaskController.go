@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/pkg/utils"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChildTodos は直下のサブタスクを返します。
+func (mc *TodoController) GetChildTodos(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      todos, err := mc.model(c).GetChildTodos(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodosToOutput(todos)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// GetTodoTree は Todo とその子孫すべてを入れ子の形で返します。
+func (mc *TodoController) GetTodoTree(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      root, descendants, err := mc.model(c).GetTodoTree(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodoTreeToOutput(root, descendants)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// SetTodoParent は Todo の親を付け替えます。循環してしまう場合は 422 を返します。
+func (mc *TodoController) SetTodoParent(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.SetTodoParentInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
+
+      todo, err := mc.model(c).SetTodoParent(uint(id), version, input.ParentID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodoToOutput(todo)
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
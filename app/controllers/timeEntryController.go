@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"app/models"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRunningTimer はログイン中のユーザーの計測中のタイマーを返します。計測していない場合は null です。
+func (mc *TodoController) GetRunningTimer(c *gin.Context) {
+      entry, err := mc.model(c).GetRunningTimer()
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      if entry == nil {
+            c.JSON(http.StatusOK, gin.H{"data": nil})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTimeEntryToOutput(*entry)})
+}
+
+// StartTimer は Todo のタイマーを開始します。他の Todo のタイマーを計測中の場合は、それを止めてから開始します。
+func (mc *TodoController) StartTimer(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      entry, err := mc.model(c).StartTimer(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTimeEntryToOutput(entry)})
+}
+
+// StopTimer はログイン中のユーザーの計測中のタイマーを止めます。計測していない場合は 404 を返します。
+func (mc *TodoController) StopTimer(c *gin.Context) {
+      entry, err := mc.model(c).StopTimer()
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTimeEntryToOutput(entry)})
+}
+
+// GetTimeEntries は Todo の時間の記録を新しい順に返します。?page=1&per_page=20 でページを指定します。
+func (mc *TodoController) GetTimeEntries(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var query requests.PageQuery
+      if err := c.ShouldBindQuery(&query); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      entries, page, err := mc.model(c).GetTimeEntries(uint(id), query)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTimeEntriesToOutput(entries), "pagination": page})
+}
+
+// CreateTimeEntry は Todo に使った時間を手で入力して記録します。ended_at か minutes のどちらかが必要です。
+func (mc *TodoController) CreateTimeEntry(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.CreateTimeEntryInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      entry, err := mc.model(c).CreateTimeEntry(uint(id), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTimeEntryToOutput(entry)})
+}
+
+// UpdateTimeEntry は記録の日時やメモを変更します。記録した本人だけが変更できます。
+func (mc *TodoController) UpdateTimeEntry(c *gin.Context) {
+      todoID, entryID, ok := timeEntryParams(c)
+      if !ok {
+            return
+      }
+
+      var input requests.UpdateTimeEntryInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      entry, err := mc.model(c).UpdateTimeEntry(todoID, entryID, input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTimeEntryToOutput(entry)})
+}
+
+func (mc *TodoController) DeleteTimeEntry(c *gin.Context) {
+      todoID, entryID, ok := timeEntryParams(c)
+      if !ok {
+            return
+      }
+
+      if err := mc.model(c).DeleteTimeEntry(todoID, entryID); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+// GetTimesheet は開始日時が期間内の記録を週・ユーザー・プロジェクトごとに集計して返します。?format=csv で CSV ファイルとして返します。
+func (mc *TodoController) GetTimesheet(c *gin.Context) {
+      var query requests.TimesheetQuery
+      if err := c.ShouldBindQuery(&query); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+      format := c.DefaultQuery("format", models.TimesheetFormatJSON)
+      if format != models.TimesheetFormatJSON && format != models.TimesheetFormatCSV {
+            c.JSON(errorStatus(models.ErrInvalidTimesheetFormat), gin.H{"error": models.ErrInvalidTimesheetFormat.Error()})
+            return
+      }
+
+      sheet, err := mc.model(c).GetTimesheet(query)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      output := mc.model(c).ConvertTimesheetToOutput(sheet)
+      if format == models.TimesheetFormatJSON {
+            c.JSON(http.StatusOK, gin.H{"data": output})
+            return
+      }
+      c.Header("Content-Type", "text/csv; charset=utf-8")
+      c.Header("Content-Disposition", `attachment; filename="timesheet-`+output.From+`-`+output.To+`.csv"`)
+      c.Status(http.StatusOK)
+      // 書き出しを始めた後はステータスコードを変えられないので、エラーはログに残して途中で打ち切る
+      if err := mc.model(c).WriteTimesheetCSV(c.Writer, sheet); err != nil {
+            log.Printf("export timesheet: %v", err)
+            c.Abort()
+      }
+}
+
+func timeEntryParams(c *gin.Context) (uint, uint, bool) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return 0, 0, false
+      }
+      entryID, err := strconv.Atoi(c.Param("entry_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time entry ID"})
+            return 0, 0, false
+      }
+      return uint(id), uint(entryID), true
+}
@@ -1,15 +1,19 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"app/models"
+	"app/pkg/middleware"
 	"app/pkg/utils"
 	"app/requests"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
  
 type TodoController struct {
@@ -22,16 +26,177 @@ func NewTodoController(m *models.TodoModel) *TodoController {
       return &TodoController{Model: m}
 }
  
+// model は呼び出したユーザーのタイムゾーンで日時を扱い、選択しているワークスペースのデータだけを扱う TodoModel を返します。
+// X-Time-Zone ヘッダがあれば、ユーザーの設定より優先します（海外に出かけているときなど）。
+// ワークスペースは RequireWorkspace で選択します。選択していない場合、ワークスペースごとのデータを扱うと ErrWorkspaceRequired になります。
+func (mc *TodoController) model(c *gin.Context) *models.TodoModel {
+      if model, ok := c.Get(requestModelKey); ok {
+            return model.(*models.TodoModel)
+      }
+      loc, err := models.LoadTimeZone(c.GetHeader("X-Time-Zone"))
+      if err != nil {
+            loc, err = mc.Model.GetUserLocation(c.GetUint(middleware.UserIDKey))
+      }
+      if err != nil {
+            loc, _ = models.LoadTimeZone(models.DefaultTimeZone())
+      }
+      model := mc.Model.WithLocation(loc)
+      if member, ok := c.Get(workspaceMemberKey); ok {
+            model = model.WithWorkspace(member.(models.WorkspaceMember).WorkspaceID).WithUser(c.GetUint(middleware.UserIDKey))
+            // 変更のリクエストには操作の ID を付け、POST /api/undo/:operation_id で取り消せるようにする
+            if c.Request.Method != http.MethodGet {
+                  if operationID, err := models.NewOperationID(); err == nil {
+                        model = model.WithOperation(operationID)
+                        c.Header("X-Operation-ID", operationID)
+                  }
+            }
+      }
+      c.Set(requestModelKey, model)
+      return model
+}
+
+const requestModelKey = "request_model"
+
+// errorStatus はモデル層から返されたエラーを HTTP ステータスコードに変換します。
+func errorStatus(err error) int {
+      switch {
+      case errors.Is(err, gorm.ErrRecordNotFound),
+            errors.Is(err, models.ErrInvalidInvitation),
+            errors.Is(err, models.ErrNoRunningTimer):
+            return http.StatusNotFound
+      case errors.Is(err, models.ErrInvitationExpired),
+            errors.Is(err, models.ErrUndoExpired):
+            return http.StatusGone
+      case errors.Is(err, models.ErrVersionMismatch):
+            return http.StatusPreconditionFailed
+      case errors.Is(err, models.ErrAttachmentTooLarge):
+            return http.StatusRequestEntityTooLarge
+      case errors.Is(err, models.ErrAttachmentTypeNotAllowed):
+            return http.StatusUnsupportedMediaType
+      case errors.Is(err, models.ErrNotWorkspaceMember),
+            errors.Is(err, models.ErrNoWorkspace),
+            errors.Is(err, models.ErrWorkspaceForbidden),
+            errors.Is(err, models.ErrProjectForbidden),
+            errors.Is(err, models.ErrCommentForbidden),
+            errors.Is(err, models.ErrAttachmentForbidden),
+            errors.Is(err, models.ErrTimeEntryForbidden),
+            errors.Is(err, models.ErrTimesheetForbidden),
+            errors.Is(err, models.ErrInvitationEmailMismatch):
+            return http.StatusForbidden
+      case errors.Is(err, models.ErrNotInTrash),
+            errors.Is(err, models.ErrTagNameTaken),
+            errors.Is(err, models.ErrHasChildren),
+            errors.Is(err, models.ErrStatusInUse),
+            errors.Is(err, models.ErrStatusNameTaken),
+            errors.Is(err, models.ErrDefaultStatusRequired),
+            errors.Is(err, models.ErrDefaultStatusDelete),
+            errors.Is(err, models.ErrWorkspaceNotEmpty),
+            errors.Is(err, models.ErrAlreadyWorkspaceMember),
+            errors.Is(err, models.ErrInvitationNotPending),
+            errors.Is(err, models.ErrProjectArchived),
+            errors.Is(err, models.ErrAlreadyProjectMember),
+            errors.Is(err, models.ErrAlreadyUndone),
+            errors.Is(err, models.ErrUndoConflict):
+            return http.StatusConflict
+      case errors.Is(err, models.ErrInvalidTransition),
+            errors.Is(err, models.ErrNotInColumn),
+            errors.Is(err, models.ErrTodoCycle),
+            errors.Is(err, models.ErrDependencyCycle),
+            errors.Is(err, models.ErrNoDeadline),
+            errors.Is(err, models.ErrNotRecurring),
+            errors.Is(err, models.ErrImportRowsInvalid),
+            errors.Is(err, models.ErrOwnerRequired),
+            errors.Is(err, models.ErrAssigneeNotMember),
+            errors.Is(err, models.ErrLastWorkspaceOwner),
+            errors.Is(err, models.ErrLastProjectManager),
+            errors.Is(err, models.ErrCrossWorkspace):
+            return http.StatusUnprocessableEntity
+      case errors.Is(err, models.ErrInvalidGroupBy),
+            errors.Is(err, models.ErrInvalidTagMatch),
+            errors.Is(err, models.ErrChecklistOrderMismatch),
+            errors.Is(err, models.ErrInvalidDeleteMode),
+            errors.Is(err, models.ErrInvalidPriority),
+            errors.Is(err, models.ErrInvalidEstimate),
+            errors.Is(err, models.ErrInvalidTimeEntry),
+            errors.Is(err, models.ErrInvalidTimesheetRange),
+            errors.Is(err, models.ErrInvalidTimesheetFormat),
+            errors.Is(err, models.ErrInvalidTodoSort),
+            errors.Is(err, models.ErrInvalidRecurrenceScope),
+            errors.Is(err, models.ErrInvalidRecurrenceGenerate),
+            errors.Is(err, utils.ErrInvalidRRule),
+            errors.Is(err, models.ErrInvalidReminderChannel),
+            errors.Is(err, models.ErrInvalidReminderTarget),
+            errors.Is(err, models.ErrInvalidTimeZone),
+            errors.Is(err, models.ErrInvalidDue),
+            errors.Is(err, utils.ErrUnrecognizedDeadline),
+            errors.Is(err, models.ErrInvalidCalendarComponent),
+            errors.Is(err, models.ErrInvalidImportFormat),
+            errors.Is(err, models.ErrInvalidImportFile),
+            errors.Is(err, models.ErrInvalidImportMapping),
+            errors.Is(err, models.ErrTooManyImportRows),
+            errors.Is(err, models.ErrInvalidExportFormat),
+            errors.Is(err, models.ErrExportSortUrgency),
+            errors.Is(err, models.ErrInvalidAssigneeRole),
+            errors.Is(err, models.ErrAssigneeUserRequired),
+            errors.Is(err, models.ErrDuplicateAssignee),
+            errors.Is(err, models.ErrInvalidWorkspaceRole),
+            errors.Is(err, models.ErrInvalidProjectRole),
+            errors.Is(err, models.ErrInvalidProjectDate),
+            errors.Is(err, models.ErrEmptyComment),
+            errors.Is(err, models.ErrEmptyAttachment):
+            return http.StatusBadRequest
+      default:
+            return http.StatusInternalServerError
+      }
+}
+
+// checkIfMatch は If-Match ヘッダを現在の Todo の ETag と比較し、一致した場合にその version を返します。
+// ヘッダが無い場合は 428、一致しない場合は 412 を返してリクエストを中断します。
+func (mc *TodoController) checkIfMatch(c *gin.Context, id uint) (uint, bool) {
+      return mc.checkIfMatchWith(c, id, mc.model(c).GetTodoByID)
+}
+
+// checkIfMatchWith は checkIfMatch と同じ検証を、getTodo で取得した Todo に対して行います。
+// ゴミ箱の Todo のように、通常の GetTodoByID では取得できないものに使います。
+func (mc *TodoController) checkIfMatchWith(c *gin.Context, id uint, getTodo func(uint) (models.Todo, error)) (uint, bool) {
+      header := c.GetHeader("If-Match")
+      if header == "" {
+            c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+            return 0, false
+      }
+
+      todo, err := getTodo(id)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return 0, false
+      }
+
+      // 更新系のリクエストでは強い比較を使い、GET の ETag に含まれる本文のハッシュは見ずに version だけで判定する
+      if !utils.MatchVersionETag(header, todo.ID, todo.Version) {
+            c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+            c.JSON(http.StatusPreconditionFailed, gin.H{"error": models.ErrVersionMismatch.Error()})
+            return 0, false
+      }
+      return todo.Version, true
+}
+
 // gin.ContextはGinの中心的な部分で、リクエストとレスポンスの情報を含んでいます
 func (mc *TodoController) GetTodos(c *gin.Context) {
-      // models/todo.goのGetAll関数で全件取得
-      todos, err := mc.Model.GetTodoAll()
+      // ShouldBindQueryメソッドは、URLのクエリパラメータ（?tag=1&tag_match=all など）を構造体にバインドします。
+      var query requests.TodoListQuery
+      if err := c.ShouldBindQuery(&query); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      // models/todo.goのGetAll関数で条件に一致するものを取得
+      todos, err := mc.model(c).GetTodoAll(query)
       if err != nil {
             // 500エラーを返す
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
             return
       }
-      output := mc.Model.ConvertTodosToOutput(todos)
+      output := mc.model(c).ConvertTodosToOutput(todos)
 
       // JSONメソッドは、HTTPレスポンスをJSON形式で生成するためのメソッド
       // gin.HはGinが提供する便利な関数で、map[string]interface{}型のマップを短く書くためのものです。この場合、"data": todosはクライアントに返すJSONのキーと値を設定しています。
@@ -48,14 +213,31 @@ func (mc *TodoController) GetTodo(c *gin.Context) {
  
       // GetByID関数はuint型を引数として受け取るのでuinit型に変換
       // uint型: 0および正の整数のみを表現できます
-      todo, err := mc.Model.GetTodoByID(uint(id))
+      todo, err := mc.model(c).GetTodoByID(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      output := mc.model(c).ConvertTodoToOutput(todo)
+      body, err := json.Marshal(gin.H{"data": output})
       if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
       }
-      output := mc.Model.ConvertTodoToOutput(todo)
+
+      // 完了待ちかどうかや緊急度、記録された時間のように version を上げずに変わる値や、
+      // タイムゾーンごとの期限の表し方の違いも区別できるよう、ETag には本文のハッシュを含める
+      etag := utils.RepresentationETag(todo.ID, todo.Version, body)
+      c.Header("ETag", etag)
+      c.Header("Vary", "X-Time-Zone")
+      // クライアントが持っているものと同じなら 304 を返す
+      if utils.MatchETag(c.GetHeader("If-None-Match"), etag, true) {
+            c.Status(http.StatusNotModified)
+            return
+      }
  
-      c.JSON(http.StatusOK, gin.H{"data": output})
+      c.Data(http.StatusOK, "application/json; charset=utf-8", body)
 }
  
 func (mc *TodoController) CreateTodo(c *gin.Context) {
@@ -70,29 +252,47 @@ func (mc *TodoController) CreateTodo(c *gin.Context) {
       }
  
       // 入力されたcontentを引数に
-      todo, err := mc.Model.CreateTodo(input)
+      todo, err := mc.model(c).CreateTodo(input)
       if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
             return
       }
  
-      c.JSON(http.StatusOK, gin.H{"data": todo})
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTodoToOutput(todo)})
 }
  
+// UpdateTodo は PUT /todos（ボディの id を使用）と PUT・PATCH /todos/:id の両方を処理します。
 func (mc *TodoController) UpdateTodo(c *gin.Context) {
       var input requests.UpdateTodoInput
       if err := c.ShouldBindJSON(&input); err != nil {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
       }
+
+      id := input.ID
+      if c.Param("id") != "" {
+            paramID, err := strconv.Atoi(c.Param("id"))
+            if err != nil {
+                  c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+                  return
+            }
+            id = uint(paramID)
+      }
+
+      version, ok := mc.checkIfMatch(c, id)
+      if !ok {
+            return
+      }
  
-      todo, err := mc.Model.UpdateTodo(uint(input.ID), input)
+      todo, err := mc.model(c).UpdateTodo(id, version, input)
       if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
             return
       }
  
-      c.JSON(http.StatusOK, gin.H{"data": todo})
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTodoToOutput(todo)})
 }
  
 func (mc *TodoController) DeleteTodo(c *gin.Context) {
@@ -101,17 +301,77 @@ func (mc *TodoController) DeleteTodo(c *gin.Context) {
             c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
             return
       }
+
+      version, ok := mc.checkIfMatch(c, uint(id))
+      if !ok {
+            return
+      }
  
-      if err := mc.Model.DeleteTodo(uint(id)); err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+      // サブタスクの扱い（orphan / cascade / block）はクエリパラメータで指定できる
+      mode := c.DefaultQuery("subtasks", models.DefaultSubtaskDeleteMode())
+ 
+      if err := mc.model(c).DeleteTodo(uint(id), version, mode); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
             return
       }
  
       c.JSON(http.StatusOK, gin.H{"data": true})
 }
 
+// GetTrashedTodos はゴミ箱に入っている Todo の一覧を返します。
+func (mc *TodoController) GetTrashedTodos(c *gin.Context) {
+      todos, err := mc.model(c).GetTrashedTodos()
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodosToOutput(todos)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// RestoreTodo はゴミ箱の Todo を元に戻します。
+func (mc *TodoController) RestoreTodo(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      todo, err := mc.model(c).RestoreTodo(uint(id))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.model(c).ConvertTodoToOutput(todo)
+
+      c.Header("ETag", utils.ETag(todo.ID, todo.Version))
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// DeleteTodoPermanently はゴミ箱の Todo を完全に削除します。元に戻すことはできません。
+func (mc *TodoController) DeleteTodoPermanently(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      version, ok := mc.checkIfMatchWith(c, uint(id), mc.model(c).GetTrashedTodoByID)
+      if !ok {
+            return
+      }
+
+      if err := mc.model(c).DeleteTodoPermanently(uint(id), version); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
 func (mc *TodoController) GetUsers(c *gin.Context) {
-      users, err := mc.Model.GetAllUser()
+      users, err := mc.model(c).GetAllUser()
       if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
@@ -123,7 +383,7 @@ func (mc *TodoController) GetUser(c *gin.Context) {
       email := c.Param("email")
       fmt.Printf("%+v\n", email)
  
-      user, err := mc.Model.GetUserByEmail(email)
+      user, err := mc.model(c).GetUserByEmail(email)
       if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
@@ -142,20 +402,60 @@ func (mc *TodoController) UpdateUser(c *gin.Context) {
             return
       }
 
-      user, err := mc.Model.UpdateUser(email, input)
+      user, err := mc.model(c).UpdateUser(email, input)
       if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
             return
       }
 
       c.JSON(http.StatusOK, gin.H{"data": user})
 }
 
+// GetMe はログインしているユーザーを返します。
+func (mc *TodoController) GetMe(c *gin.Context) {
+      user, err := mc.Model.GetUserByID(c.GetUint(middleware.UserIDKey))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      var output requests.AuthOutput
+      output.ID = user.ID
+      output.Name = user.Name
+      output.Email = user.Email
+      output.TimeZone = user.TimeZone
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// UpdateMe はログインしているユーザーの名前とタイムゾーンを変更します。
+func (mc *TodoController) UpdateMe(c *gin.Context) {
+      var input requests.UpdateProfileInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      user, err := mc.Model.UpdateProfile(c.GetUint(middleware.UserIDKey), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      var output requests.AuthOutput
+      output.ID = user.ID
+      output.Name = user.Name
+      output.Email = user.Email
+      output.TimeZone = user.TimeZone
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
 func (mc *TodoController) DeleteUser(c *gin.Context) {
       email := c.Param("email")
       fmt.Printf("%+v\n", email)
  
-      if err := mc.Model.DeleteUserByEmail(email); err != nil {
+      if err := mc.model(c).DeleteUserByEmail(email); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
       }
@@ -171,9 +471,9 @@ func (mc *TodoController) SignUp(c *gin.Context) {
             return
       }
       
-      user, err := mc.Model.CreateUser(input)
+      user, err := mc.model(c).CreateUser(input)
       if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
             return
       }
       fmt.Printf("%+v\n", user)
@@ -190,6 +490,7 @@ func (mc *TodoController) SignUp(c *gin.Context) {
       output.ID = user.ID
       output.Name = user.Name
       output.Email = user.Email
+      output.TimeZone = user.TimeZone
 
       // Cookieの有効期限を設定
       cookieMaxAge := 60 * 60 * 24 * 30 // 30日
@@ -207,13 +508,13 @@ func (mc *TodoController) Login(c *gin.Context) {
             return
       }
       
-      user, err := mc.Model.LoginUser(input)
+      user, err := mc.model(c).LoginUser(input)
       if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
       }
       
-      err = mc.Model.VerifyPassword(user, input.Password)
+      err = mc.model(c).VerifyPassword(user, input.Password)
       if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
@@ -231,6 +532,7 @@ func (mc *TodoController) Login(c *gin.Context) {
       output.ID = user.ID
       output.Name = user.Name
       output.Email = user.Email
+      output.TimeZone = user.TimeZone
 
       // Cookieの有効期限を設定
       cookieMaxAge := 60 * 60 * 24 * 30 // 30日
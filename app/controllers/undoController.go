@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUndoableOperations はログイン中のユーザーがこのワークスペースで行った操作のうち、まだ取り消せるものを新しい順に返します。
+func (mc *TodoController) GetUndoableOperations(c *gin.Context) {
+      operations, err := mc.model(c).GetUndoableOperations()
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertTodoOperationsToOutput(operations)})
+}
+
+// UndoOperation は :operation_id の操作を取り消します。操作の ID は、変更のリクエストのレスポンスの X-Operation-ID ヘッダで返しています。
+// 取り消せる時間を過ぎている場合は 410、既に取り消した場合や、その後に同じ項目が変更されている場合は 409 を返します。
+func (mc *TodoController) UndoOperation(c *gin.Context) {
+      result, err := mc.model(c).UndoOperation(c.Param("operation_id"))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": mc.model(c).ConvertUndoResultToOutput(result)})
+}
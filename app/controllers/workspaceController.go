@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"app/models"
+	"app/pkg/middleware"
+	"app/requests"
+
+	"github.com/gin-gonic/gin"
+)
+
+// workspaceMemberKey は RequireWorkspace で選択したワークスペースでの役割を gin.Context に保存するときのキーです。
+const workspaceMemberKey = "workspace_member"
+
+// RequireWorkspace は X-Workspace-ID ヘッダで指定されたワークスペースを選択するミドルウェアです。
+// ヘッダが無い場合は、ユーザーが最初に参加したワークスペースを選択します。メンバーでないワークスペースは 403 になります。
+func (mc *TodoController) RequireWorkspace(c *gin.Context) {
+      userID := c.GetUint(middleware.UserIDKey)
+      var member models.WorkspaceMember
+      var err error
+      if header := c.GetHeader("X-Workspace-ID"); header != "" {
+            id, convErr := strconv.Atoi(header)
+            if convErr != nil {
+                  c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid X-Workspace-ID"})
+                  c.Abort()
+                  return
+            }
+            member, err = mc.Model.GetWorkspaceMember(uint(id), userID)
+      } else {
+            member, err = mc.Model.GetDefaultWorkspaceMember(userID)
+      }
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            c.Abort()
+            return
+      }
+      c.Set(workspaceMemberKey, member)
+      c.Header("X-Workspace-ID", strconv.Itoa(int(member.WorkspaceID)))
+
+      c.Next()
+}
+
+// GetWorkspaces はログインしているユーザーが参加しているワークスペースを返します。
+func (mc *TodoController) GetWorkspaces(c *gin.Context) {
+      members, err := mc.Model.GetWorkspaces(c.GetUint(middleware.UserIDKey))
+      if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertWorkspacesToOutput(members)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) GetWorkspace(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      member, err := mc.Model.GetWorkspaceMember(uint(id), c.GetUint(middleware.UserIDKey))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertWorkspaceToOutput(member)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// CreateWorkspace はワークスペースを作成します。作成したユーザーが持ち主になります。
+func (mc *TodoController) CreateWorkspace(c *gin.Context) {
+      var input requests.WorkspaceInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      member, err := mc.Model.CreateWorkspace(c.GetUint(middleware.UserIDKey), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertWorkspaceToOutput(member)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+func (mc *TodoController) UpdateWorkspace(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.WorkspaceInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      userID := c.GetUint(middleware.UserIDKey)
+      if _, err := mc.Model.UpdateWorkspace(uint(id), userID, input); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      member, err := mc.Model.GetWorkspaceMember(uint(id), userID)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertWorkspaceToOutput(member)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// DeleteWorkspace はワークスペースを削除します。Todo が残っている場合は 409 になります。
+func (mc *TodoController) DeleteWorkspace(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      if err := mc.Model.DeleteWorkspace(uint(id), c.GetUint(middleware.UserIDKey)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
+
+func (mc *TodoController) GetWorkspaceMembers(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      members, err := mc.Model.GetWorkspaceMembers(uint(id), c.GetUint(middleware.UserIDKey))
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertWorkspaceMembersToOutput(members)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// AddWorkspaceMember は既存のユーザーをワークスペースに追加します。
+func (mc *TodoController) AddWorkspaceMember(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+
+      var input requests.WorkspaceMemberInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      member, err := mc.Model.AddWorkspaceMember(uint(id), c.GetUint(middleware.UserIDKey), input)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertWorkspaceMemberToOutput(member)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// UpdateWorkspaceMember はメンバーの役割を変更します。
+func (mc *TodoController) UpdateWorkspaceMember(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+      userID, err := strconv.Atoi(c.Param("user_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+            return
+      }
+
+      var input requests.WorkspaceMemberRoleInput
+      if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+      }
+
+      member, err := mc.Model.UpdateWorkspaceMember(uint(id), c.GetUint(middleware.UserIDKey), uint(userID), input.Role)
+      if err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+      output := mc.Model.ConvertWorkspaceMemberToOutput(member)
+
+      c.JSON(http.StatusOK, gin.H{"data": output})
+}
+
+// RemoveWorkspaceMember はメンバーをワークスペースから外します。自分の ID を指定するとワークスペースから抜けます。
+func (mc *TodoController) RemoveWorkspaceMember(c *gin.Context) {
+      id, err := strconv.Atoi(c.Param("workspace_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+            return
+      }
+      userID, err := strconv.Atoi(c.Param("user_id"))
+      if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+            return
+      }
+
+      if err := mc.Model.RemoveWorkspaceMember(uint(id), c.GetUint(middleware.UserIDKey), uint(userID)); err != nil {
+            c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+            return
+      }
+
+      c.JSON(http.StatusOK, gin.H{"data": true})
+}
@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"app/models"
+)
+
+// blobCleanerBatchSize は 1 回の実行で削除する blob の最大数です。
+const blobCleanerBatchSize = 500
+
+// ScheduleBlobCleaner は、削除された添付ファイルの blob を interval ごとに Storage から削除するジョブを登録します。
+func ScheduleBlobCleaner(scheduler *Scheduler, model *models.TodoModel, interval time.Duration) {
+	scheduler.Every("blob-cleaner", interval, func() error {
+		deleted, err := model.DeleteQueuedBlobs(blobCleanerBatchSize)
+		if deleted > 0 {
+			log.Printf("deleted %d attachment blobs", deleted)
+		}
+		return err
+	})
+}
@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"app/models"
+)
+
+// ScheduleRecurrenceGenerator は、作成タイミングが schedule の繰り返しについて、lookahead 先までの回の Todo を interval ごとに作成するジョブを登録します。
+func ScheduleRecurrenceGenerator(scheduler *Scheduler, model *models.TodoModel, lookahead time.Duration, interval time.Duration) {
+	scheduler.Every("recurrence-generator", interval, func() error {
+		created, err := model.GenerateScheduledOccurrences(time.Now().Add(lookahead))
+		if err != nil {
+			return err
+		}
+		if created > 0 {
+			log.Printf("generated %d recurring todos", created)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"app/models"
+	"app/pkg/notify"
+)
+
+// reminderSendTimeout は 1 件のリマインダーを送るときのタイムアウトです。
+const reminderSendTimeout = 30 * time.Second
+
+// ScheduleReminderSender は、送る時刻になったリマインダーを interval ごとに channels で送るジョブを登録します。
+// maxDelay より前に送るはずだったリマインダーは送りません。
+func ScheduleReminderSender(scheduler *Scheduler, model *models.TodoModel, channels notify.Channels, maxDelay time.Duration, interval time.Duration) {
+	scheduler.Every("reminder-sender", interval, func() error {
+		reminders, err := model.GetDueReminders(time.Now(), maxDelay)
+		if err != nil {
+			return err
+		}
+		for _, reminder := range reminders {
+			deliveryID, claimed, err := model.ClaimReminder(reminder)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), reminderSendTimeout)
+			sendErr := channels.Send(ctx, reminder.Channel, notify.Reminder{
+				TodoID:         reminder.TodoID,
+				Title:          reminder.Title,
+				Deadline:       reminder.LocalDeadline(),
+				DeadlineAllDay: reminder.DeadlineAllDay,
+				Before:         time.Duration(reminder.BeforeMinutes) * time.Minute,
+				UserID:         reminder.UserID,
+				Target:         reminder.Target,
+			})
+			cancel()
+			if sendErr != nil {
+				log.Printf("failed to send %s reminder for todo %d: %v", reminder.Channel, reminder.TodoID, sendErr)
+			}
+			if err := model.FinishReminder(deliveryID, sendErr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
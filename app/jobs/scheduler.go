@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SchedulerLockKey はスケジューラのリーダー選出に使う advisory lock のキーです。
+const SchedulerLockKey = 35035
+
+// schedulerTick はリーダーの確認と、実行時刻になったジョブの確認を行う間隔です。
+const schedulerTick = 30 * time.Second
+
+// Scheduler は登録したジョブを定期的に実行します。
+// サーバーを複数台で動かしても同じジョブが重複して実行されないよう、Postgres の advisory lock を取得できた 1 台（リーダー）だけが実行します。
+type Scheduler struct {
+	db      *gorm.DB
+	lockKey int64
+	jobs    []*scheduledJob
+	// リーダーの間は advisory lock を取得したコネクションを持ち続ける
+	conn *sql.Conn
+}
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      func() error
+	next     time.Time
+}
+
+func NewScheduler(db *gorm.DB, lockKey int64) *Scheduler {
+	return &Scheduler{db: db, lockKey: lockKey}
+}
+
+// Every は interval ごとに run を実行するジョブを登録します。Start の前に呼んでください。
+func (s *Scheduler) Every(name string, interval time.Duration, run func() error) {
+	s.jobs = append(s.jobs, &scheduledJob{name: name, interval: interval, run: run})
+}
+
+// Start はスケジューラのゴルーチンを起動します。
+func (s *Scheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(schedulerTick)
+		defer ticker.Stop()
+
+		for {
+			if s.ensureLeader() {
+				s.runDueJobs(time.Now())
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ensureLeader はリーダーかどうかを返します。リーダーがいなければ advisory lock を取得してリーダーになります。
+// pg_try_advisory_lock はセッション単位のロックなので、取得したコネクションをプールに返さずに持ち続けます。
+// コネクションが切れるとロックも解放され、他のサーバーがリーダーになります。
+func (s *Scheduler) ensureLeader() bool {
+	ctx := context.Background()
+	if s.conn != nil {
+		if err := s.conn.PingContext(ctx); err == nil {
+			return true
+		}
+		log.Printf("scheduler lost leadership")
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		log.Printf("scheduler failed to get database: %v", err)
+		return false
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		log.Printf("scheduler failed to get connection: %v", err)
+		return false
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", s.lockKey).Scan(&acquired); err != nil || !acquired {
+		if err != nil {
+			log.Printf("scheduler failed to acquire lock: %v", err)
+		}
+		conn.Close()
+		return false
+	}
+
+	log.Printf("scheduler became leader")
+	s.conn = conn
+	// リーダーになったときは、すべてのジョブをすぐに実行する
+	for _, job := range s.jobs {
+		job.next = time.Time{}
+	}
+	return true
+}
+
+func (s *Scheduler) runDueJobs(now time.Time) {
+	for _, job := range s.jobs {
+		if now.Before(job.next) {
+			continue
+		}
+		job.next = now.Add(job.interval)
+		if err := job.run(); err != nil {
+			log.Printf("scheduled job %s failed: %v", job.name, err)
+		}
+	}
+}
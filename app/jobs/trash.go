@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"app/models"
+)
+
+// ScheduleTrashPurger は、retention より長くゴミ箱に入っている Todo を interval ごとに完全削除するジョブを登録します。
+func ScheduleTrashPurger(scheduler *Scheduler, model *models.TodoModel, retention time.Duration, interval time.Duration) {
+	scheduler.Every("trash-purger", interval, func() error {
+		purged, err := model.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("purged %d todos from trash", purged)
+		}
+		return nil
+	})
+}
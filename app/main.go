@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/postgres"
@@ -9,8 +12,13 @@ import (
 	"gorm.io/gorm/logger"
 
 	"app/controllers"
+	"app/jobs"
+	"app/migrate"
 	"app/models"
 	"app/pkg/middleware"
+	"app/pkg/notify"
+	"app/pkg/storage"
+	"app/pkg/utils"
 )
  
 func main() {
@@ -28,24 +36,205 @@ func main() {
  
       // 自動マイグレーション
       // Todoモデルの構造体の通りのスキーマを構築
-      db.AutoMigrate(&models.Todo{}, &models.User{})
+      db.AutoMigrate(&models.Status{}, &models.Todo{}, &models.User{}, &models.TodoAssignee{}, &models.TodoStatusHistory{}, &models.Board{}, &models.ChecklistItem{}, &models.Tag{}, &models.RecurrenceSeries{}, &models.ReminderRule{}, &models.ReminderDelivery{}, &models.ImportJob{}, &models.Workspace{}, &models.WorkspaceMember{}, &models.WorkspaceInvitation{}, &models.Project{}, &models.ProjectMember{}, &models.Comment{}, &models.CommentRevision{}, &models.Attachment{}, &models.BlobDeletion{}, &models.TodoEvent{}, &models.TodoOperation{}, &models.TodoDependency{}, &models.TimeEntry{}, &models.TodoTag{})
+      // state（bool）からステータスへのデータ移行
+      if err := migrate.MigrateTodoState(db); err != nil {
+            panic(err)
+      }
+      // category（文字列）からタグへのデータ移行
+      if err := migrate.MigrateCategoriesToTags(db); err != nil {
+            panic(err)
+      }
+      // user_todos に役割を追加した際のデータ移行
+      if err := migrate.MigrateTodoOwners(db); err != nil {
+            panic(err)
+      }
+      // ワークスペースが無かったころのデータを Default ワークスペースに移行
+      if err := migrate.MigrateWorkspaces(db); err != nil {
+            panic(err)
+      }
+      // 並び順（rank）が未設定の Todo に rank を設定
+      if err := models.RebalanceStatusRanks(db); err != nil {
+            panic(err)
+      }
+      // ここから先、Todo・タグ・ステータス・ボードの読み書きはワークスペースで絞り込まれる
+      if err := models.RegisterWorkspaceScope(db); err != nil {
+            panic(err)
+      }
       // seeder.Seeder(db)
       
       // モデルとコントローラの初期化
       // モデルはデータベースとのやり取りを担当し、コントローラはクライアントからのリクエストを処理し、モデルを通じてデータベースとやり取りをします。
       todoModel := models.NewTodoModel(db)
+      // 添付ファイルの保存先（STORAGE_BACKEND で local か s3 を選ぶ）
+      store, err := storage.NewFromEnv()
+      if err != nil {
+            panic(err)
+      }
+      todoModel.Storage = store
       todoController := controllers.NewTodoController(todoModel)
+
+      // 定期実行するジョブ。複数台で動かしても、advisory lock を取得した 1 台だけが実行する
+      scheduler := jobs.NewScheduler(db, jobs.SchedulerLockKey)
+      // ゴミ箱の自動削除（保持期間は TRASH_RETENTION_DAYS で設定、デフォルトは30日）
+      trashRetention := time.Duration(utils.GetEnvInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+      jobs.ScheduleTrashPurger(scheduler, todoModel.AcrossWorkspaces(), trashRetention, time.Hour)
+      // 繰り返しの Todo の作成（何日先まで作成するかは RECURRENCE_LOOKAHEAD_DAYS で設定、デフォルトは7日）
+      jobs.ScheduleRecurrenceGenerator(scheduler, todoModel.AcrossWorkspaces(), models.RecurrenceLookahead(), time.Hour)
+      // 期限のリマインダー（送り遅れを許容する時間は REMINDER_MAX_DELAY_MINUTES で設定、デフォルトは60分）
+      channels := notify.Channels{
+            models.ReminderChannelWebhook: notify.NewWebhookChannel(os.Getenv("WEBHOOK_SECRET")),
+      }
+      if email := notify.NewEmailChannelFromEnv(); email != nil {
+            channels[models.ReminderChannelEmail] = email
+      }
+      reminderMaxDelay := time.Duration(utils.GetEnvInt("REMINDER_MAX_DELAY_MINUTES", 60)) * time.Minute
+      jobs.ScheduleReminderSender(scheduler, todoModel.AcrossWorkspaces(), channels, reminderMaxDelay, time.Minute)
+      // 削除された添付ファイルの blob の削除
+      jobs.ScheduleBlobCleaner(scheduler, todoModel.AcrossWorkspaces(), time.Minute)
+      scheduler.Start()
       
       // ルーティング設定
       r := gin.Default()
+      // カレンダーアプリから購読するため、Cookie ではなく URL のトークンで認証する
+      r.GET("/ical/:token", todoController.GetCalendarFeed)
+      // 招待リンク。アカウントを持っていない人も開くので、ログインは不要
+      r.GET("/invitations/:token", todoController.GetInvitation)
+      r.POST("/invitations/:token/signup", todoController.SignUpWithInvitation)
+      r.POST("/invitations/:token/decline", todoController.DeclineInvitation)
+      // ローカルに保存した添付ファイルのダウンロード。URL の署名で認証する
+      if handler, ok := store.(http.Handler); ok {
+            r.GET("/files/*key", gin.WrapH(http.StripPrefix("/files", handler)))
+      }
       api := r.Group("/api")
       api.Use(middleware.AuthMiddleware)
       {
-            api.GET("/todos", todoController.GetTodos)
-            api.GET("/todos/:id", todoController.GetTodo)
-            api.POST("/todos", todoController.CreateTodo)
-            api.PUT("/todos", todoController.UpdateTodo)
-            api.DELETE("/todos/:id", todoController.DeleteTodo)
+            api.GET("/me", todoController.GetMe)
+            api.PUT("/me", todoController.UpdateMe)
+
+            api.POST("/ical/token", todoController.RegenerateCalendarToken)
+            api.DELETE("/ical/token", todoController.RevokeCalendarToken)
+
+            api.GET("/reminders", todoController.GetReminderRules)
+            api.POST("/reminders", todoController.CreateReminderRule)
+            api.PUT("/reminders/:id", todoController.UpdateReminderRule)
+            api.DELETE("/reminders/:id", todoController.DeleteReminderRule)
+
+            api.GET("/workspaces", todoController.GetWorkspaces)
+            api.POST("/workspaces", todoController.CreateWorkspace)
+            api.GET("/workspaces/:workspace_id", todoController.GetWorkspace)
+            api.PUT("/workspaces/:workspace_id", todoController.UpdateWorkspace)
+            api.DELETE("/workspaces/:workspace_id", todoController.DeleteWorkspace)
+            api.GET("/workspaces/:workspace_id/members", todoController.GetWorkspaceMembers)
+            api.POST("/workspaces/:workspace_id/members", todoController.AddWorkspaceMember)
+            api.PUT("/workspaces/:workspace_id/members/:user_id", todoController.UpdateWorkspaceMember)
+            api.DELETE("/workspaces/:workspace_id/members/:user_id", todoController.RemoveWorkspaceMember)
+            api.GET("/workspaces/:workspace_id/invitations", todoController.GetWorkspaceInvitations)
+            api.POST("/workspaces/:workspace_id/invitations", todoController.CreateWorkspaceInvitation)
+            api.DELETE("/workspaces/:workspace_id/invitations/:invitation_id", todoController.RevokeWorkspaceInvitation)
+            api.POST("/invitations/:token/accept", todoController.AcceptInvitation)
+
+            // ワークスペースごとのデータ。X-Workspace-ID ヘッダでワークスペースを選ぶ
+            // プロジェクトの Todo を変更できるのはプロジェクトのメンバーだけ
+            ws := api.Group("", todoController.RequireWorkspace, todoController.RequireTodoWrite)
+            {
+                  ws.GET("/todos", todoController.GetTodos)
+                  ws.GET("/todos/trash", todoController.GetTrashedTodos)
+                  ws.POST("/todos/import", todoController.ImportTodos)
+                  ws.GET("/todos/import/:job_id", todoController.GetImportJob)
+                  ws.GET("/todos/export", todoController.ExportTodos)
+                  ws.GET("/todos/:id", todoController.GetTodo)
+                  ws.POST("/todos", todoController.CreateTodo)
+                  ws.PUT("/todos", todoController.UpdateTodo)
+                  ws.PUT("/todos/:id", todoController.UpdateTodo)
+                  ws.PATCH("/todos/:id", todoController.UpdateTodo)
+                  ws.DELETE("/todos/:id", todoController.DeleteTodo)
+                  ws.POST("/todos/:id/restore", todoController.RestoreTodo)
+                  ws.DELETE("/todos/:id/permanent", todoController.DeleteTodoPermanently)
+                  ws.PATCH("/todos/:id/status", todoController.ChangeTodoStatus)
+                  ws.GET("/todos/:id/status-history", todoController.GetTodoStatusHistory)
+                  ws.GET("/todos/:id/history", todoController.GetTodoHistory)
+
+                  ws.GET("/undo", todoController.GetUndoableOperations)
+                  ws.POST("/undo/:operation_id", todoController.UndoOperation)
+                  ws.POST("/todos/:id/move", todoController.MoveTodo)
+                  ws.GET("/todos/:id/children", todoController.GetChildTodos)
+                  ws.GET("/todos/:id/tree", todoController.GetTodoTree)
+                  ws.PUT("/todos/:id/parent", todoController.SetTodoParent)
+                  ws.GET("/todos/:id/dependencies", todoController.GetTodoDependencies)
+                  ws.POST("/todos/:id/dependencies", todoController.AddTodoDependency)
+                  ws.DELETE("/todos/:id/dependencies/:depends_on_id", todoController.RemoveTodoDependency)
+                  ws.GET("/todos/:id/recurrence", todoController.GetTodoRecurrence)
+                  ws.PUT("/todos/:id/recurrence", todoController.SetTodoRecurrence)
+                  ws.DELETE("/todos/:id/recurrence", todoController.StopTodoRecurrence)
+
+                  ws.GET("/todos/:id/assignees", todoController.GetTodoAssignees)
+                  ws.POST("/todos/:id/assignees", todoController.AddTodoAssignee)
+                  ws.PUT("/todos/:id/assignees", todoController.SetTodoAssignees)
+                  ws.DELETE("/todos/:id/assignees/:user_id", todoController.RemoveTodoAssignee)
+
+                  ws.GET("/todos/:id/comments", todoController.GetComments)
+                  ws.POST("/todos/:id/comments", todoController.CreateComment)
+                  ws.GET("/todos/:id/comments/:comment_id", todoController.GetComment)
+                  ws.PUT("/todos/:id/comments/:comment_id", todoController.UpdateComment)
+                  ws.DELETE("/todos/:id/comments/:comment_id", todoController.DeleteComment)
+                  ws.GET("/todos/:id/comments/:comment_id/history", todoController.GetCommentHistory)
+
+                  ws.GET("/todos/:id/attachments", todoController.GetAttachments)
+                  ws.POST("/todos/:id/attachments", todoController.CreateAttachment)
+                  ws.GET("/todos/:id/attachments/:attachment_id", todoController.GetAttachment)
+                  ws.GET("/todos/:id/attachments/:attachment_id/download", todoController.DownloadAttachment)
+                  ws.DELETE("/todos/:id/attachments/:attachment_id", todoController.DeleteAttachment)
+
+                  ws.GET("/todos/:id/time-entries", todoController.GetTimeEntries)
+                  ws.POST("/todos/:id/time-entries", todoController.CreateTimeEntry)
+                  ws.PUT("/todos/:id/time-entries/:entry_id", todoController.UpdateTimeEntry)
+                  ws.DELETE("/todos/:id/time-entries/:entry_id", todoController.DeleteTimeEntry)
+                  ws.POST("/todos/:id/timer/start", todoController.StartTimer)
+                  ws.GET("/timer", todoController.GetRunningTimer)
+                  ws.POST("/timer/stop", todoController.StopTimer)
+                  ws.GET("/timesheet", todoController.GetTimesheet)
+
+                  ws.GET("/todos/:id/checklist", todoController.GetChecklist)
+                  ws.POST("/todos/:id/checklist", todoController.CreateChecklistItem)
+                  ws.PUT("/todos/:id/checklist/order", todoController.ReorderChecklist)
+                  ws.PUT("/todos/:id/checklist/:item_id", todoController.UpdateChecklistItem)
+                  ws.DELETE("/todos/:id/checklist/:item_id", todoController.DeleteChecklistItem)
+                  ws.POST("/todos/:id/checklist/:item_id/promote", todoController.PromoteChecklistItem)
+
+                  ws.GET("/statuses", todoController.GetStatuses)
+                  ws.POST("/statuses", todoController.CreateStatus)
+                  ws.PUT("/statuses/:id", todoController.UpdateStatus)
+                  ws.DELETE("/statuses/:id", todoController.DeleteStatus)
+                  ws.PUT("/statuses/:id/transitions", todoController.SetStatusTransitions)
+
+                  ws.PUT("/todos/:id/tags", todoController.SetTodoTags)
+
+                  ws.GET("/tags", todoController.GetTags)
+                  ws.POST("/tags", todoController.CreateTag)
+                  ws.PUT("/tags/:id", todoController.UpdateTag)
+                  ws.DELETE("/tags/:id", todoController.DeleteTag)
+                  ws.POST("/tags/:id/merge", todoController.MergeTags)
+
+                  ws.GET("/boards", todoController.GetBoards)
+                  ws.POST("/boards", todoController.CreateBoard)
+                  ws.GET("/boards/:id", todoController.GetBoard)
+                  ws.DELETE("/boards/:id", todoController.DeleteBoard)
+
+                  ws.GET("/projects", todoController.GetProjects)
+                  ws.POST("/projects", todoController.CreateProject)
+                  ws.GET("/projects/:id", todoController.GetProject)
+                  ws.PUT("/projects/:id", todoController.UpdateProject)
+                  ws.DELETE("/projects/:id", todoController.DeleteProject)
+                  ws.POST("/projects/:id/archive", todoController.ArchiveProject)
+                  ws.POST("/projects/:id/unarchive", todoController.UnarchiveProject)
+                  ws.GET("/projects/:id/stats", todoController.GetProjectStats)
+                  ws.GET("/projects/:id/critical-path", todoController.GetProjectCriticalPath)
+                  ws.GET("/projects/:id/members", todoController.GetProjectMembers)
+                  ws.POST("/projects/:id/members", todoController.AddProjectMember)
+                  ws.PUT("/projects/:id/members/:user_id", todoController.UpdateProjectMember)
+                  ws.DELETE("/projects/:id/members/:user_id", todoController.RemoveProjectMember)
+            }
       
             // api.GET("/users", todoController.GetUsers)
             // api.GET("/users/:email", todoController.GetUser)
@@ -0,0 +1,25 @@
+package migrate
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"app/models"
+)
+
+// MigrateTodoOwners は役割が無かったころの user_todos（作成したユーザーだけが入っている）を持ち主にします。
+// 持ち主がいない Todo は、ID の最も小さいユーザーを持ち主にします。持ち主がいない Todo が無ければ何もしません。
+func MigrateTodoOwners(db *gorm.DB) error {
+	result := db.Exec(`UPDATE user_todos ut SET role = ?
+		WHERE ut.user_id = (SELECT MIN(m.user_id) FROM user_todos m WHERE m.todo_id = ut.todo_id)
+			AND NOT EXISTS (SELECT 1 FROM user_todos o WHERE o.todo_id = ut.todo_id AND o.role = ?)`,
+		models.AssigneeRoleOwner, models.AssigneeRoleOwner)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		fmt.Printf("Set owners of %d todos\n", result.RowsAffected)
+	}
+	return nil
+}
@@ -11,14 +11,21 @@ import (
 
 // 使ってない
 func Migrate(db *gorm.DB) error {
-	if err := db.AutoMigrate(&models.Todo{},&models.User{}); err != nil {
+	if err := db.AutoMigrate(&models.Status{}, &models.Tag{}, &models.Todo{}, &models.User{}, &models.TodoStatusHistory{}, &models.ChecklistItem{}); err != nil {
+		return err
+	}
+	if err := models.EnsureDefaultStatuses(db, 0); err != nil {
+		return err
+	}
+	status, err := models.GetDefaultStatus(db)
+	if err != nil {
 		return err
 	}
 
 	todos := []models.Todo{
-		{ID: 1, Title: "title1", Description: "description1", Category: "category1", Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), State: false},
-		{ID: 2, Title: "title2", Description: "description2", Category: "category2", Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), State: false},
-		{ID: 3, Title: "title3", Description: "description3", Category: "category3", Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), State: false},
+		{BaseModel: models.BaseModel{ID: 1}, Title: "title1", Description: "description1", Tags: []*models.Tag{{Name: "category1"}}, Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), StatusID: status.ID},
+		{BaseModel: models.BaseModel{ID: 2}, Title: "title2", Description: "description2", Tags: []*models.Tag{{Name: "category2"}}, Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), StatusID: status.ID},
+		{BaseModel: models.BaseModel{ID: 3}, Title: "title3", Description: "description3", Tags: []*models.Tag{{Name: "category3"}}, Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), StatusID: status.ID},
 	}
 
 	users := []models.User{
@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"app/models"
+)
+
+// MigrateTodoState は旧来の todos.state（bool）をステータスに置き換えます。
+// state = true の Todo は完了扱いのステータス（done）に、false の Todo はデフォルトのステータスに移行し、
+// 移行後に state カラムを削除します。state カラムが無ければ何もしません。
+func MigrateTodoState(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Todo{}, "state") {
+		return nil
+	}
+	// ワークスペースが無かったころのデータなので、ワークスペースを決めずに作成し、MigrateWorkspaces で移す
+	if err := models.EnsureDefaultStatuses(db, 0); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		openStatus, err := models.GetDefaultStatus(tx)
+		if err != nil {
+			return err
+		}
+		var doneStatus models.Status
+		if err := tx.Where("is_done = ?", true).Order("position").First(&doneStatus).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec("UPDATE todos SET status_id = ? WHERE state = true", doneStatus.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE todos SET status_id = ? WHERE state = false OR status_id IS NULL", openStatus.ID).Error; err != nil {
+			return err
+		}
+		// 移行した時点を、現在のステータスに入った日時として記録する
+		if err := tx.Exec("UPDATE todos SET status_changed_at = NOW() WHERE status_changed_at IS NULL").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO todo_status_histories (todo_id, status_id, entered_at)
+			SELECT id, status_id, status_changed_at FROM todos
+			WHERE NOT EXISTS (SELECT 1 FROM todo_status_histories h WHERE h.todo_id = todos.id)`).Error; err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn(&models.Todo{}, "state"); err != nil {
+			return err
+		}
+
+		fmt.Println("Migrated todos.state to statuses")
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"app/models"
+)
+
+// MigrateCategoriesToTags は旧来の todos.category（自由入力の文字列）をタグに置き換えます。
+// 大文字・小文字だけが違うカテゴリは 1 つのタグにまとめ、移行後に category カラムを削除します。
+// category カラムが無ければ何もしません。
+func MigrateCategoriesToTags(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Todo{}, "category") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var categories []string
+		if err := tx.Raw("SELECT DISTINCT TRIM(category) FROM todos WHERE TRIM(COALESCE(category, '')) <> '' ORDER BY 1").Scan(&categories).Error; err != nil {
+			return err
+		}
+
+		for _, category := range categories {
+			tag, err := models.FindOrCreateTag(tx, category)
+			if err != nil {
+				return err
+			}
+			if err := tx.Exec(`INSERT INTO todo_tags (todo_id, tag_id)
+				SELECT id, ? FROM todos WHERE LOWER(TRIM(category)) = ?
+				ON CONFLICT DO NOTHING`, tag.ID, strings.ToLower(category)).Error; err != nil {
+				return err
+			}
+		}
+
+		// カテゴリごとのボードはタグごとのボードにする
+		if err := tx.Exec("UPDATE boards SET group_by = ? WHERE group_by = 'category'", models.BoardGroupByTag).Error; err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn(&models.Todo{}, "category"); err != nil {
+			return err
+		}
+
+		fmt.Printf("Migrated %d categories to tags\n", len(categories))
+		return nil
+	})
+}
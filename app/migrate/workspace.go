@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"app/models"
+)
+
+// workspaceTables はワークスペースごとに分かれているテーブルです。
+var workspaceTables = []string{"todos", "statuses", "tags", "boards", "recurrence_series"}
+
+// MigrateWorkspaces はワークスペースが無かったころのデータ（workspace_id が 0）と、どのワークスペースにも参加していないユーザーを
+// 「Default」ワークスペースに移します。最も ID の小さいユーザーが持ち主になります。
+// workspace_id が 0 のデータが無ければ（移行済みなら）何もしません。
+// 移行後にワークスペースから外れたユーザーは、無関係なユーザーとワークスペースを共有しないように、ここでは移しません（RemoveWorkspaceMember を参照）。
+func MigrateWorkspaces(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var orphans int64
+		for _, table := range workspaceTables {
+			var count int64
+			if err := tx.Table(table).Where("workspace_id = 0").Count(&count).Error; err != nil {
+				return err
+			}
+			orphans += count
+		}
+		if orphans == 0 {
+			return nil
+		}
+		var users []models.User
+		if err := tx.Where("id NOT IN (SELECT user_id FROM workspace_members)").Order("id").Find(&users).Error; err != nil {
+			return err
+		}
+
+		workspace := models.Workspace{Name: "Default"}
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		for _, table := range workspaceTables {
+			if err := tx.Table(table).Where("workspace_id = 0").Update("workspace_id", workspace.ID).Error; err != nil {
+				return err
+			}
+		}
+		for i, user := range users {
+			role := models.WorkspaceRoleMember
+			if i == 0 {
+				role = models.WorkspaceRoleOwner
+			}
+			member := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: role}
+			if err := tx.Omit("Workspace", "User").Create(&member).Error; err != nil {
+				return err
+			}
+		}
+		if err := models.EnsureDefaultStatuses(tx, workspace.ID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Moved %d records and %d users to the Default workspace\n", orphans, len(users))
+		return nil
+	})
+}
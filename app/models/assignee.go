@@ -0,0 +1,271 @@
+package models
+
+import (
+	"app/requests"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+      // AssigneeRoleOwner は Todo の持ち主です。Todo を作成したユーザーで、1 つの Todo に必ず 1 人います。
+      AssigneeRoleOwner = "owner"
+      // AssigneeRoleAssignee は Todo の担当者です。
+      AssigneeRoleAssignee = "assignee"
+      // AssigneeRoleWatcher は Todo の進み具合を見ているだけのユーザーです。リマインダーやカレンダーの対象になりません。
+      AssigneeRoleWatcher = "watcher"
+)
+
+// TodoAssignee は Todo とユーザーの関係です。以前の many2many:user_todos の中間テーブルに役割（Role）を加えたものです。
+type TodoAssignee struct {
+      TodoID    uint      `gorm:"primaryKey;autoIncrement:false" json:"todo_id"`
+      UserID    uint      `gorm:"primaryKey;autoIncrement:false;index" json:"user_id"`
+      Role      string    `gorm:"not null;default:'assignee'" json:"role"`
+      User      User      `json:"user"`
+      CreatedAt time.Time `json:"created_at"`
+}
+
+func (TodoAssignee) TableName() string {
+      return "user_todos"
+}
+
+var (
+      // ErrInvalidAssigneeRole は対応していない役割が指定された場合に返されます。
+      ErrInvalidAssigneeRole = errors.New("role must be owner, assignee or watcher")
+      // ErrAssigneeUserRequired は user_id も email も指定されていない場合に返されます。
+      ErrAssigneeUserRequired = errors.New("user_id or email is required")
+      // ErrDuplicateAssignee は同じユーザーが 2 回指定された場合に返されます。
+      ErrDuplicateAssignee = errors.New("the same user is specified more than once")
+      // ErrOwnerRequired は持ち主がいなくなる、または複数になる変更をしようとした場合に返されます。
+      ErrOwnerRequired = errors.New("todo must have exactly one owner")
+)
+
+// preloadAssignees は役割（持ち主、担当者、ウォッチャー）の順に並べて読み込みます。
+func preloadAssignees(db *gorm.DB) *gorm.DB {
+      return db.Preload("User").Order("CASE role WHEN 'owner' THEN 0 WHEN 'assignee' THEN 1 ELSE 2 END, created_at, user_id")
+}
+
+// GetTodoAssignees は Todo に関わっているユーザーを返します。
+func (m *TodoModel) GetTodoAssignees(todoID uint) ([]TodoAssignee, error) {
+      if _, err := m.GetTodoByID(todoID); err != nil {
+            return nil, err
+      }
+      var assignees []TodoAssignee
+      if err := preloadAssignees(m.DB).Where("todo_id = ?", todoID).Find(&assignees).Error; err != nil {
+            return nil, err
+      }
+      return assignees, nil
+}
+
+// AddTodoAssignee は version が一致する場合のみ、Todo にユーザーを追加します。既に関わっているユーザーの場合は役割を変更します。
+// 持ち主を指定した場合は、それまでの持ち主は担当者になります。
+func (m *TodoModel) AddTodoAssignee(todoID uint, version uint, input requests.AssigneeInput) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            todo, err := lockTodoVersion(tx, todoID, version)
+            if err != nil {
+                  return err
+            }
+            audit, err := auditTodos(tx, TodoEventAssigneesChanged, todoID)
+            if err != nil {
+                  return err
+            }
+            assignee, err := resolveAssignee(tx, todo.WorkspaceID, input)
+            if err != nil {
+                  return err
+            }
+
+            var current TodoAssignee
+            err = tx.Where("todo_id = ? AND user_id = ?", todoID, assignee.UserID).First(&current).Error
+            switch {
+            case err == nil:
+                  if current.Role == AssigneeRoleOwner && assignee.Role != AssigneeRoleOwner {
+                        return ErrOwnerRequired
+                  }
+            case !errors.Is(err, gorm.ErrRecordNotFound):
+                  return err
+            }
+            if assignee.Role == AssigneeRoleOwner {
+                  if err := tx.Model(&TodoAssignee{}).Where("todo_id = ? AND role = ?", todoID, AssigneeRoleOwner).
+                        Update("role", AssigneeRoleAssignee).Error; err != nil {
+                        return err
+                  }
+            }
+            if current.TodoID != 0 {
+                  if err := tx.Model(&TodoAssignee{}).Where("todo_id = ? AND user_id = ?", todoID, assignee.UserID).
+                        Update("role", assignee.Role).Error; err != nil {
+                        return err
+                  }
+            } else {
+                  assignee.TodoID = todoID
+                  if err := tx.Omit("User").Create(&assignee).Error; err != nil {
+                        return err
+                  }
+            }
+            if err := touchTodo(tx, todoID); err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(todoID)
+}
+
+// RemoveTodoAssignee は version が一致する場合のみ、Todo からユーザーを外します。持ち主は外せません。
+func (m *TodoModel) RemoveTodoAssignee(todoID uint, version uint, userID uint) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if _, err := lockTodoVersion(tx, todoID, version); err != nil {
+                  return err
+            }
+            audit, err := auditTodos(tx, TodoEventAssigneesChanged, todoID)
+            if err != nil {
+                  return err
+            }
+            var assignee TodoAssignee
+            if err := tx.Where("todo_id = ? AND user_id = ?", todoID, userID).First(&assignee).Error; err != nil {
+                  return err
+            }
+            if assignee.Role == AssigneeRoleOwner {
+                  return ErrOwnerRequired
+            }
+            if err := tx.Where("todo_id = ? AND user_id = ?", todoID, userID).Delete(&TodoAssignee{}).Error; err != nil {
+                  return err
+            }
+            if err := touchTodo(tx, todoID); err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(todoID)
+}
+
+// ReplaceTodoAssignees は version が一致する場合のみ、Todo に関わるユーザーを inputs に置き換えます。
+// inputs には持ち主がちょうど 1 人含まれている必要があります。
+func (m *TodoModel) ReplaceTodoAssignees(todoID uint, version uint, inputs []requests.AssigneeInput) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            todo, err := lockTodoVersion(tx, todoID, version)
+            if err != nil {
+                  return err
+            }
+            audit, err := auditTodos(tx, TodoEventAssigneesChanged, todoID)
+            if err != nil {
+                  return err
+            }
+            assignees := make([]TodoAssignee, 0, len(inputs))
+            seen := map[uint]bool{}
+            owners := 0
+            for _, input := range inputs {
+                  assignee, err := resolveAssignee(tx, todo.WorkspaceID, input)
+                  if err != nil {
+                        return err
+                  }
+                  if seen[assignee.UserID] {
+                        return ErrDuplicateAssignee
+                  }
+                  seen[assignee.UserID] = true
+                  if assignee.Role == AssigneeRoleOwner {
+                        owners++
+                  }
+                  assignee.TodoID = todoID
+                  assignees = append(assignees, assignee)
+            }
+            if owners != 1 {
+                  return ErrOwnerRequired
+            }
+
+            if err := tx.Where("todo_id = ?", todoID).Delete(&TodoAssignee{}).Error; err != nil {
+                  return err
+            }
+            if err := tx.Omit("User").Create(&assignees).Error; err != nil {
+                  return err
+            }
+            if err := touchTodo(tx, todoID); err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(todoID)
+}
+
+// resolveAssignee は user_id または email でユーザーを探し、役割を検証します。役割を省略した場合は担当者になります。
+// Todo のワークスペースのメンバーでないユーザーは ErrAssigneeNotMember になります。
+func resolveAssignee(tx *gorm.DB, workspaceID uint, input requests.AssigneeInput) (TodoAssignee, error) {
+      role := input.Role
+      if role == "" {
+            role = AssigneeRoleAssignee
+      }
+      if err := validateAssigneeRole(role); err != nil {
+            return TodoAssignee{}, err
+      }
+
+      var user User
+      switch {
+      case input.UserID != 0:
+            if err := tx.Where("id = ?", input.UserID).First(&user).Error; err != nil {
+                  return TodoAssignee{}, err
+            }
+      case input.Email != "":
+            if err := tx.Where("email = ?", input.Email).First(&user).Error; err != nil {
+                  return TodoAssignee{}, err
+            }
+      default:
+            return TodoAssignee{}, ErrAssigneeUserRequired
+      }
+      if err := checkWorkspaceMembers(tx, workspaceID, []uint{user.ID}); err != nil {
+            return TodoAssignee{}, err
+      }
+      return TodoAssignee{UserID: user.ID, Role: role}, nil
+}
+
+func validateAssigneeRole(role string) error {
+      switch role {
+      case AssigneeRoleOwner, AssigneeRoleAssignee, AssigneeRoleWatcher:
+            return nil
+      default:
+            return ErrInvalidAssigneeRole
+      }
+}
+
+// createAssignees は新しく作成した Todo に assignees と同じユーザーと役割を設定します。
+func createAssignees(tx *gorm.DB, todo *Todo, assignees []TodoAssignee) error {
+      if len(assignees) == 0 {
+            todo.Assignees = []TodoAssignee{}
+            return nil
+      }
+      rows := make([]TodoAssignee, len(assignees))
+      for i, assignee := range assignees {
+            rows[i] = TodoAssignee{TodoID: todo.ID, UserID: assignee.UserID, Role: assignee.Role}
+      }
+      if err := tx.Omit("User").Create(&rows).Error; err != nil {
+            return err
+      }
+      return preloadAssignees(tx).Where("todo_id = ?", todo.ID).Find(&todo.Assignees).Error
+}
+
+func (m *TodoModel) ConvertAssigneeToOutput(assignee TodoAssignee) requests.AssigneeOutput {
+      return requests.AssigneeOutput{
+            User: requests.AuthOutput{
+                  ID:       assignee.User.ID,
+                  Name:     assignee.User.Name,
+                  Email:    assignee.User.Email,
+                  TimeZone: assignee.User.TimeZone,
+            },
+            Role: assignee.Role,
+      }
+}
+
+func (m *TodoModel) ConvertAssigneesToOutput(assignees []TodoAssignee) []requests.AssigneeOutput {
+      output := []requests.AssigneeOutput{}
+      for _, assignee := range assignees {
+            output = append(output, m.ConvertAssigneeToOutput(assignee))
+      }
+      return output
+}
@@ -0,0 +1,339 @@
+package models
+
+import (
+	"app/pkg/utils"
+	"app/requests"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Attachment は Todo に添付したファイルです。中身（blob）は TodoModel.Storage に保存し、DB にはその情報だけを保存します。
+type Attachment struct {
+      ID         uint `gorm:"primary_key" json:"id"`
+      TodoID     uint `gorm:"not null;index" json:"todo_id"`
+      UploaderID uint `gorm:"not null" json:"uploader_id"`
+      Uploader   User `json:"uploader"`
+      // アップロードされたときのファイル名（ディレクトリ部分は除く）
+      Filename string `gorm:"not null" json:"filename"`
+      // ファイルの中身から判定した MIME タイプ。拡張子やリクエストの Content-Type は信用しない
+      ContentType string `gorm:"not null" json:"content_type"`
+      Size        int64  `gorm:"not null" json:"size"`
+      // Storage 上のキー（attachments/<ワークスペース>/<Todo>/<ランダムな文字列>）
+      StorageKey string    `gorm:"not null;uniqueIndex" json:"-"`
+      CreatedAt  time.Time `json:"created_at"`
+}
+
+// BlobDeletion は削除待ちの blob です。添付ファイルの行を削除するトランザクションの中で追加し、
+// コミットされた後にジョブ（jobs/attachment.go）が Storage から削除します。
+// トランザクションがロールバックされても blob だけが消えることはなく、Storage の削除に失敗した場合は次回やり直します。
+type BlobDeletion struct {
+      ID         uint      `gorm:"primary_key" json:"id"`
+      StorageKey string    `gorm:"not null" json:"storage_key"`
+      CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+      // sniffLength は MIME タイプの判定に使う先頭のバイト数です（http.DetectContentType と同じ）。
+      sniffLength = 512
+      // maxFilenameLength はファイル名の最大の文字数です。超えた部分は拡張子を残して切り詰めます。
+      maxFilenameLength = 255
+)
+
+// defaultAttachmentTypes は ATTACHMENT_ALLOWED_TYPES を設定していない場合に添付できる MIME タイプです。
+var defaultAttachmentTypes = []string{"application/pdf", "image/png", "image/jpeg", "image/gif", "image/webp", "text/plain"}
+
+var (
+      // ErrAttachmentTooLarge はファイルが AttachmentMaxBytes より大きい場合に返されます。
+      ErrAttachmentTooLarge = errors.New("attachment is too large")
+      // ErrEmptyAttachment は空のファイルをアップロードしようとした場合に返されます。
+      ErrEmptyAttachment = errors.New("attachment must not be empty")
+      // ErrAttachmentTypeNotAllowed はファイルの中身が添付できない種類の場合に返されます。
+      ErrAttachmentTypeNotAllowed = errors.New("attachment type is not allowed")
+      // ErrAttachmentForbidden は他のユーザーの添付ファイルを削除しようとした場合に返されます。
+      ErrAttachmentForbidden = errors.New("you cannot delete another user's attachment")
+)
+
+// AttachmentMaxBytes は添付できるファイルの最大サイズです。ATTACHMENT_MAX_BYTES で設定します（デフォルトは 20MB）。
+func AttachmentMaxBytes() int64 {
+      return int64(utils.GetEnvInt("ATTACHMENT_MAX_BYTES", 20<<20))
+}
+
+// AttachmentURLTTL はダウンロード用の署名付き URL の有効期間です。ATTACHMENT_URL_TTL_MINUTES で設定します（デフォルトは15分）。
+func AttachmentURLTTL() time.Duration {
+      return time.Duration(utils.GetEnvInt("ATTACHMENT_URL_TTL_MINUTES", 15)) * time.Minute
+}
+
+// AttachmentAllowedTypes は添付できる MIME タイプです。ATTACHMENT_ALLOWED_TYPES にカンマ区切りで設定します。
+func AttachmentAllowedTypes() []string {
+      value := os.Getenv("ATTACHMENT_ALLOWED_TYPES")
+      if value == "" {
+            return defaultAttachmentTypes
+      }
+      types := []string{}
+      for _, t := range strings.Split(value, ",") {
+            if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+                  types = append(types, t)
+            }
+      }
+      return types
+}
+
+// GetAttachments は Todo の添付ファイルを古い順に返します。
+func (m *TodoModel) GetAttachments(todoID uint) ([]Attachment, error) {
+      if _, err := findScopedTodo(m.DB, todoID); err != nil {
+            return nil, err
+      }
+      var attachments []Attachment
+      if err := m.DB.Preload("Uploader").Where("todo_id = ?", todoID).Order("created_at, id").Find(&attachments).Error; err != nil {
+            return nil, err
+      }
+      return attachments, nil
+}
+
+// GetAttachment は Todo の添付ファイルを 1 件返します。
+func (m *TodoModel) GetAttachment(todoID uint, attachmentID uint) (Attachment, error) {
+      if _, err := findScopedTodo(m.DB, todoID); err != nil {
+            return Attachment{}, err
+      }
+      var attachment Attachment
+      if err := m.DB.Preload("Uploader").Where("id = ? AND todo_id = ?", attachmentID, todoID).First(&attachment).Error; err != nil {
+            return Attachment{}, err
+      }
+      return attachment, nil
+}
+
+// CreateAttachment は r から size バイトのファイルを読み込み、Todo に添付します。
+// ファイルの種類は先頭のバイト列から判定し、AttachmentAllowedTypes に含まれないものは拒否します。
+// blob を保存してから DB に登録し、登録に失敗した場合は保存した blob を削除します。
+func (m *TodoModel) CreateAttachment(todoID uint, filename string, r io.Reader, size int64) (Attachment, error) {
+      if size > AttachmentMaxBytes() {
+            return Attachment{}, ErrAttachmentTooLarge
+      }
+      if size <= 0 {
+            return Attachment{}, ErrEmptyAttachment
+      }
+      todo, err := findScopedTodo(m.DB, todoID)
+      if err != nil {
+            return Attachment{}, err
+      }
+
+      head := make([]byte, sniffLength)
+      n, err := io.ReadFull(r, head)
+      if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+            return Attachment{}, err
+      }
+      head = head[:n]
+      contentType, err := detectAttachmentType(head)
+      if err != nil {
+            return Attachment{}, err
+      }
+
+      key, err := newStorageKey(todo)
+      if err != nil {
+            return Attachment{}, err
+      }
+      ctx := m.DB.Statement.Context
+      if err := m.Storage.Put(ctx, key, io.MultiReader(bytes.NewReader(head), r), size, contentType); err != nil {
+            return Attachment{}, err
+      }
+
+      attachment := Attachment{
+            TodoID:      todo.ID,
+            UploaderID:  m.UserID,
+            Filename:    sanitizeFilename(filename),
+            ContentType: contentType,
+            Size:        size,
+            StorageKey:  key,
+      }
+      err = m.DB.Transaction(func(tx *gorm.DB) error {
+            // アップロード中に Todo がゴミ箱へ移動されていないか確認する
+            if _, err := findScopedTodo(tx.Clauses(clause.Locking{Strength: "SHARE"}), todo.ID); err != nil {
+                  return err
+            }
+            if err := tx.Omit("Uploader").Create(&attachment).Error; err != nil {
+                  return err
+            }
+            return tx.Preload("Uploader").Where("id = ?", attachment.ID).First(&attachment).Error
+      })
+      if err != nil {
+            if deleteErr := m.Storage.Delete(ctx, key); deleteErr != nil {
+                  // 削除できなかった blob は、ジョブに削除してもらう
+                  log.Printf("failed to delete blob %s: %v", key, deleteErr)
+                  m.DB.Create(&BlobDeletion{StorageKey: key})
+            }
+            return Attachment{}, err
+      }
+      return attachment, nil
+}
+
+// DeleteAttachment は添付ファイルを削除します。削除できるのはアップロードした本人と、ワークスペースの持ち主・管理者です。
+// blob は同じトランザクションで削除待ちに追加し、後でジョブが削除します。
+func (m *TodoModel) DeleteAttachment(todoID uint, attachmentID uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            todo, err := findScopedTodo(tx, todoID)
+            if err != nil {
+                  return err
+            }
+            var attachment Attachment
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND todo_id = ?", attachmentID, todo.ID).First(&attachment).Error; err != nil {
+                  return err
+            }
+            if attachment.UploaderID != m.UserID {
+                  err := m.requireWorkspaceRole(tx, todo.WorkspaceID, m.UserID, WorkspaceRoleOwner, WorkspaceRoleAdmin)
+                  if errors.Is(err, ErrWorkspaceForbidden) {
+                        return ErrAttachmentForbidden
+                  }
+                  if err != nil {
+                        return err
+                  }
+            }
+            if err := tx.Delete(&attachment).Error; err != nil {
+                  return err
+            }
+            return tx.Create(&BlobDeletion{StorageKey: attachment.StorageKey}).Error
+      })
+}
+
+// AttachmentURL は添付ファイルをダウンロードするための署名付き URL と、その有効期限を返します。
+func (m *TodoModel) AttachmentURL(attachment Attachment) (string, time.Time, error) {
+      ttl := AttachmentURLTTL()
+      expiresAt := time.Now().Add(ttl)
+      url, err := m.Storage.SignedURL(m.DB.Statement.Context, attachment.StorageKey, attachment.Filename, ttl)
+      if err != nil {
+            return "", time.Time{}, err
+      }
+      return url, expiresAt, nil
+}
+
+// DeleteQueuedBlobs は削除待ちの blob を古い順に最大 limit 件削除し、削除した件数を返します。
+// Storage から削除できなかったものは削除待ちに残し、次回やり直します。
+func (m *TodoModel) DeleteQueuedBlobs(limit int) (int, error) {
+      var deletions []BlobDeletion
+      if err := m.DB.Order("id").Limit(limit).Find(&deletions).Error; err != nil {
+            return 0, err
+      }
+      deleted := 0
+      var errs []error
+      for _, deletion := range deletions {
+            if err := m.Storage.Delete(m.DB.Statement.Context, deletion.StorageKey); err != nil {
+                  errs = append(errs, fmt.Errorf("delete blob %s: %w", deletion.StorageKey, err))
+                  continue
+            }
+            if err := m.DB.Delete(&deletion).Error; err != nil {
+                  return deleted, err
+            }
+            deleted++
+      }
+      return deleted, errors.Join(errs...)
+}
+
+// findScopedTodo はコメントや添付ファイルを付ける Todo を、ワークスペースで絞り込んで取得します。
+func findScopedTodo(db *gorm.DB, todoID uint) (Todo, error) {
+      var todo Todo
+      if err := db.Select("id", "workspace_id").Where("id = ?", todoID).First(&todo).Error; err != nil {
+            return Todo{}, err
+      }
+      return todo, nil
+}
+
+// detectAttachmentType はファイルの先頭のバイト列から MIME タイプを判定し、添付できる種類か確認します。
+func detectAttachmentType(head []byte) (string, error) {
+      contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+      if err != nil {
+            return "", ErrAttachmentTypeNotAllowed
+      }
+      for _, allowed := range AttachmentAllowedTypes() {
+            if contentType == allowed {
+                  return contentType, nil
+            }
+      }
+      return "", fmt.Errorf("%w: %s", ErrAttachmentTypeNotAllowed, contentType)
+}
+
+// newStorageKey は添付ファイルの blob を保存するキーを作ります。同じファイル名でも衝突しないよう、ランダムな文字列にします。
+func newStorageKey(todo Todo) (string, error) {
+      buf := make([]byte, 16)
+      if _, err := rand.Read(buf); err != nil {
+            return "", err
+      }
+      return fmt.Sprintf("attachments/%d/%d/%s", todo.WorkspaceID, todo.ID, hex.EncodeToString(buf)), nil
+}
+
+// sanitizeFilename はファイル名からディレクトリ部分と制御文字を取り除き、長すぎる場合は拡張子を残して切り詰めます。
+func sanitizeFilename(filename string) string {
+      filename = strings.ToValidUTF8(filename, "")
+      filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+      filename = strings.Map(func(r rune) rune {
+            if r < 0x20 || r == 0x7f {
+                  return -1
+            }
+            return r
+      }, filename)
+      filename = strings.TrimSpace(filename)
+      if filename == "" || filename == "." || filename == "/" {
+            return "file"
+      }
+      if utf8.RuneCountInString(filename) > maxFilenameLength {
+            ext := filepath.Ext(filename)
+            if utf8.RuneCountInString(ext) > maxFilenameLength/2 {
+                  ext = ""
+            }
+            runes := []rune(strings.TrimSuffix(filename, ext))
+            filename = string(runes[:maxFilenameLength-utf8.RuneCountInString(ext)]) + ext
+      }
+      return filename
+}
+
+func (m *TodoModel) ConvertAttachmentToOutput(attachment Attachment) (requests.AttachmentOutput, error) {
+      url, expiresAt, err := m.AttachmentURL(attachment)
+      if err != nil {
+            return requests.AttachmentOutput{}, err
+      }
+      return requests.AttachmentOutput{
+            ID:           attachment.ID,
+            TodoID:       attachment.TodoID,
+            Uploader:     convertUserToOutput(attachment.Uploader),
+            Filename:     attachment.Filename,
+            ContentType:  attachment.ContentType,
+            Size:         attachment.Size,
+            URL:          url,
+            URLExpiresAt: expiresAt,
+            CreatedAt:    attachment.CreatedAt,
+      }, nil
+}
+
+func (m *TodoModel) ConvertAttachmentsToOutput(attachments []Attachment) ([]requests.AttachmentOutput, error) {
+      output := []requests.AttachmentOutput{}
+      for _, attachment := range attachments {
+            item, err := m.ConvertAttachmentToOutput(attachment)
+            if err != nil {
+                  return nil, err
+            }
+            output = append(output, item)
+      }
+      return output, nil
+}
+
+// purgeAttachments は完全に削除する Todo の添付ファイルを削除し、blob を削除待ちに追加します。
+func purgeAttachments(tx *gorm.DB, todoIDs []uint) error {
+      err := tx.Exec("INSERT INTO blob_deletions (storage_key, created_at) SELECT storage_key, ? FROM attachments WHERE todo_id IN ?", time.Now(), todoIDs).Error
+      if err != nil {
+            return err
+      }
+      return tx.Where("todo_id IN ?", todoIDs).Delete(&Attachment{}).Error
+}
@@ -0,0 +1,429 @@
+package models
+
+import (
+	"app/pkg/utils"
+	"app/requests"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // BoardGroupByStatus はステータスごとに列を作るボードです。
+      BoardGroupByStatus = "status"
+      // BoardGroupByTag はタグごとに列を作るボードです。複数のタグが付いた Todo は複数の列に表示されます。
+      BoardGroupByTag = "tag"
+)
+
+// rankOrder は rank で並び替えるための ORDER BY 句です。
+// DB の照合順序によっては大文字と小文字が混ざって並ぶため、バイト順で比較させます。
+const rankOrder = `rank COLLATE "C"`
+
+// Board はカンバンボードです。列は GroupBy に応じてステータスまたはタグから作られます。
+type Board struct {
+      ID        uint      `gorm:"primary_key" json:"id"`
+      // 属するワークスペース（workspace.go を参照）
+      WorkspaceID uint `gorm:"not null;default:0;index" json:"workspace_id"`
+      Name      string    `gorm:"not null" json:"name"`
+      GroupBy   string    `gorm:"not null;default:'status'" json:"group_by"`
+      CreatedAt time.Time `json:"created_at"`
+}
+
+// BoardColumn はボードの 1 列分です。DB には保存されません。
+type BoardColumn struct {
+      Key      string
+      Name     string
+      StatusID *uint
+      TagID    *uint
+      Todos    []Todo
+}
+
+// ErrInvalidGroupBy は対応していない GroupBy が指定された場合に返されます。
+var ErrInvalidGroupBy = errors.New("group_by must be status or tag")
+
+// ErrNotInColumn は並び替えの基準に指定した Todo が移動先の列に無い場合に返されます。
+var ErrNotInColumn = errors.New("neighbor todo is not in the target column")
+
+func (m *TodoModel) GetBoards() ([]Board, error) {
+      var boards []Board
+      if err := m.DB.Order("id").Find(&boards).Error; err != nil {
+            return nil, err
+      }
+      return boards, nil
+}
+
+func (m *TodoModel) CreateBoard(input requests.CreateBoardInput) (Board, error) {
+      newBoard := Board{
+            Name:    input.Name,
+            GroupBy: input.GroupBy,
+      }
+      if newBoard.GroupBy == "" {
+            newBoard.GroupBy = BoardGroupByStatus
+      }
+      if newBoard.GroupBy != BoardGroupByStatus && newBoard.GroupBy != BoardGroupByTag {
+            return Board{}, ErrInvalidGroupBy
+      }
+
+      if err := m.DB.Create(&newBoard).Error; err != nil {
+            return Board{}, err
+      }
+      return newBoard, nil
+}
+
+func (m *TodoModel) DeleteBoard(id uint) error {
+      result := m.DB.Where("id = ?", id).Delete(&Board{})
+      if result.Error != nil {
+            return result.Error
+      }
+      if result.RowsAffected == 0 {
+            return gorm.ErrRecordNotFound
+      }
+      return nil
+}
+
+// GetBoard はボードと、並び順どおりに Todo が入った列を返します。
+// Todo は列の数に関係なく 1 回のクエリでまとめて取得します。
+func (m *TodoModel) GetBoard(id uint) (Board, []BoardColumn, error) {
+      var board Board
+      if err := m.DB.Where("id = ?", id).First(&board).Error; err != nil {
+            return Board{}, nil, err
+      }
+
+      var todos []Todo
+      switch board.GroupBy {
+      case BoardGroupByTag:
+            tags, err := m.GetTags()
+            if err != nil {
+                  return Board{}, nil, err
+            }
+            if err := preloadTodo(excludeArchivedProjects(m.DB)).Order(rankOrder).Order("id").Find(&todos).Error; err != nil {
+                  return Board{}, nil, err
+            }
+            // タグの列の並び順は todo_tags の rank で決まる
+            ids := make([]uint, len(todos))
+            for i, todo := range todos {
+                  ids[i] = todo.ID
+            }
+            var positions []TodoTag
+            if len(ids) > 0 {
+                  if err := m.DB.Where("todo_id IN ?", ids).Order("tag_id").Order("rank = ''").Order(rankOrder).Order("todo_id").Find(&positions).Error; err != nil {
+                        return Board{}, nil, err
+                  }
+            }
+            return board, groupTodosByTag(tags, todos, positions), nil
+      default:
+            statuses, err := m.GetStatuses()
+            if err != nil {
+                  return Board{}, nil, err
+            }
+            if err := preloadTodo(excludeArchivedProjects(m.DB)).Order("status_id").Order(rankOrder).Order("id").Find(&todos).Error; err != nil {
+                  return Board{}, nil, err
+            }
+            return board, groupTodosByStatus(statuses, todos), nil
+      }
+}
+
+// MoveTodo は Todo を別の列・位置へ移動します。
+// AfterID の Todo の直後、BeforeID の Todo の直前に入るよう rank を決めます。どちらも無い場合は列の末尾に移動します。
+// ステータスの変更と並び順の変更は 1 つのトランザクションで行います。
+func (m *TodoModel) MoveTodo(id uint, input requests.MoveTodoInput) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var todo Todo
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&todo).Error; err != nil {
+                  return err
+            }
+            audit, err := auditTodos(tx, TodoEventMoved, id)
+            if err != nil {
+                  return err
+            }
+
+            if input.StatusID != nil {
+                  if err := changeTodoStatus(tx, &todo, *input.StatusID, time.Now()); err != nil {
+                        return err
+                  }
+            }
+            if input.TagID != nil {
+                  if err := moveTodoTag(tx, id, input.FromTagID, *input.TagID); err != nil {
+                        return err
+                  }
+            }
+
+            // タグだけが指定された場合はタグの列、それ以外はステータスの列の中で並べる
+            column := statusColumn(todo.StatusID)
+            if input.TagID != nil && input.StatusID == nil {
+                  column = tagColumn(*input.TagID)
+            }
+
+            rank, err := column.rankBetween(tx, id, input.AfterID, input.BeforeID)
+            if errors.Is(err, utils.ErrInvalidRankRange) || errors.Is(err, errUnrankedColumn) {
+                  // 同じ rank の Todo があって間に入れられない場合や、rank が未設定の Todo がある場合は、列の rank を振り直してからもう一度求める
+                  if err := column.rebalance(tx); err != nil {
+                        return err
+                  }
+                  rank, err = column.rankBetween(tx, id, input.AfterID, input.BeforeID)
+            }
+            if err != nil {
+                  return err
+            }
+
+            if err := column.setRank(tx, id, rank); err != nil {
+                  return err
+            }
+            if err := tx.Model(&Todo{}).Where("id = ?", id).Update("version", gorm.Expr("version + 1")).Error; err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(id)
+}
+
+// RebalanceStatusRanks は rank が未設定の Todo がある列について、列全体の rank を振り直します。
+// rank を導入する前からある Todo に rank を設定するために使います。
+func RebalanceStatusRanks(db *gorm.DB) error {
+      var statusIDs []uint
+      if err := db.Unscoped().Model(&Todo{}).Where("rank = ''").Distinct().Pluck("status_id", &statusIDs).Error; err != nil {
+            return err
+      }
+      return db.Transaction(func(tx *gorm.DB) error {
+            for _, statusID := range statusIDs {
+                  if err := statusColumn(statusID).rebalance(tx); err != nil {
+                        return err
+                  }
+            }
+            return nil
+      })
+}
+
+// errUnrankedColumn は rank が未設定の Todo がある列で、rank を求めようとした場合に返されます。列の rank を振り直してください。
+var errUnrankedColumn = errors.New("column has todos without rank")
+
+// rankColumn はボードの 1 列分の並び順です。並び順は列ごとに持ちます。
+// ステータスの列は todos.rank、タグの列は todo_tags.rank を使うので、タグの列で並び替えてもステータスの列の並び順は変わらず、
+// 複数のタグが付いた Todo はタグの列ごとに別の位置に並べられます。
+type rankColumn struct {
+      // rows は列の Todo を返すクエリです。idColumn と rankColumn で Todo の ID と rank を参照します。
+      rows       func(tx *gorm.DB) *gorm.DB
+      idColumn   string
+      rankColumn string
+      // update は列での Todo の rank を更新します。
+      update func(tx *gorm.DB, todoID uint, rank string) error
+}
+
+// statusColumn はステータスの列です。ゴミ箱の Todo は列に含めません。
+func statusColumn(statusID uint) rankColumn {
+      return rankColumn{
+            rows: func(tx *gorm.DB) *gorm.DB {
+                  return tx.Model(&Todo{}).Where("status_id = ?", statusID)
+            },
+            idColumn:   "todos.id",
+            rankColumn: "todos.rank",
+            update: func(tx *gorm.DB, todoID uint, rank string) error {
+                  return tx.Unscoped().Model(&Todo{}).Where("id = ?", todoID).Update("rank", rank).Error
+            },
+      }
+}
+
+// tagColumn はタグの列です。タグは呼び出す側でワークスペースのものか確認してください。
+func tagColumn(tagID uint) rankColumn {
+      return rankColumn{
+            rows: func(tx *gorm.DB) *gorm.DB {
+                  return tx.Model(&TodoTag{}).
+                        Joins("JOIN todos ON todos.id = todo_tags.todo_id AND todos.deleted_at IS NULL").
+                        Where("todo_tags.tag_id = ?", tagID)
+            },
+            idColumn:   "todo_tags.todo_id",
+            rankColumn: "todo_tags.rank",
+            update: func(tx *gorm.DB, todoID uint, rank string) error {
+                  return tx.Model(&TodoTag{}).Where("todo_id = ? AND tag_id = ?", todoID, tagID).Update("rank", rank).Error
+            },
+      }
+}
+
+func (c rankColumn) order() string {
+      return c.rankColumn + ` COLLATE "C"`
+}
+
+// setRank は列での Todo の rank を rank にします。
+func (c rankColumn) setRank(tx *gorm.DB, todoID uint, rank string) error {
+      return c.update(tx, todoID, rank)
+}
+
+// rankBetween は列の中で afterID と beforeID の間に入る rank を求めます。id の Todo 自身は除きます。
+func (c rankColumn) rankBetween(tx *gorm.DB, id uint, afterID *uint, beforeID *uint) (string, error) {
+      var unranked int64
+      if err := c.rows(tx).Where(c.rankColumn+" = ''").Where(c.idColumn+" <> ?", id).Count(&unranked).Error; err != nil {
+            return "", err
+      }
+      if unranked > 0 {
+            return "", errUnrankedColumn
+      }
+
+      var after, before string
+      if afterID != nil {
+            rank, err := c.neighborRank(tx, *afterID)
+            if err != nil {
+                  return "", err
+            }
+            after = rank
+      }
+      if beforeID != nil {
+            rank, err := c.neighborRank(tx, *beforeID)
+            if err != nil {
+                  return "", err
+            }
+            before = rank
+      }
+
+      var ranks []string
+      switch {
+      case afterID != nil && beforeID == nil:
+            // after の次の Todo との間に入れる
+            if err := c.rows(tx).Where(c.idColumn+" <> ? AND "+c.idColumn+" <> ?", id, *afterID).Where(c.order()+" > ?", after).Order(c.order()).Limit(1).Pluck(c.rankColumn, &ranks).Error; err != nil {
+                  return "", err
+            }
+            if len(ranks) > 0 {
+                  before = ranks[0]
+            }
+      case beforeID != nil && afterID == nil:
+            // before の前の Todo との間に入れる
+            if err := c.rows(tx).Where(c.idColumn+" <> ? AND "+c.idColumn+" <> ?", id, *beforeID).Where(c.order()+" < ?", before).Order(c.order()+" DESC").Limit(1).Pluck(c.rankColumn, &ranks).Error; err != nil {
+                  return "", err
+            }
+            if len(ranks) > 0 {
+                  after = ranks[0]
+            }
+      case afterID == nil && beforeID == nil:
+            // 列の末尾に入れる
+            last, err := c.lastRank(tx, id)
+            if err != nil {
+                  return "", err
+            }
+            after = last
+      }
+      return utils.RankBetween(after, before)
+}
+
+func (c rankColumn) neighborRank(tx *gorm.DB, id uint) (string, error) {
+      var ranks []string
+      if err := c.rows(tx).Where(c.idColumn+" = ?", id).Limit(1).Pluck(c.rankColumn, &ranks).Error; err != nil {
+            return "", err
+      }
+      if len(ranks) == 0 {
+            return "", ErrNotInColumn
+      }
+      return ranks[0], nil
+}
+
+// lastRank は列の最後の rank を返します。列が空なら空文字を返します。
+func (c rankColumn) lastRank(tx *gorm.DB, excludeID uint) (string, error) {
+      var ranks []string
+      if err := c.rows(tx).Where(c.idColumn+" <> ?", excludeID).Order(c.order()+" DESC").Limit(1).Pluck(c.rankColumn, &ranks).Error; err != nil {
+            return "", err
+      }
+      if len(ranks) == 0 {
+            return "", nil
+      }
+      return ranks[0], nil
+}
+
+// rebalance は列の Todo に、現在の並び順のまま間隔の空いた rank を振り直します。
+// rank が未設定の Todo は列の末尾に並べます。ステータスの列ではゴミ箱の Todo にも振り直します。
+func (c rankColumn) rebalance(tx *gorm.DB) error {
+      var ids []uint
+      if err := c.rows(tx.Unscoped()).Order(c.rankColumn + " = ''").Order(c.order()).Order(c.idColumn).Pluck(c.idColumn, &ids).Error; err != nil {
+            return err
+      }
+      for i, rank := range utils.RankSequence(len(ids)) {
+            if err := c.update(tx, ids[i], rank); err != nil {
+                  return err
+            }
+      }
+      return nil
+}
+
+func groupTodosByStatus(statuses []Status, todos []Todo) []BoardColumn {
+      columns := make([]BoardColumn, len(statuses))
+      index := map[uint]int{}
+      for i, status := range statuses {
+            statusID := status.ID
+            columns[i] = BoardColumn{Key: "status-" + status.Name, Name: status.Name, StatusID: &statusID}
+            index[status.ID] = i
+      }
+      for _, todo := range todos {
+            if i, ok := index[todo.StatusID]; ok {
+                  columns[i].Todos = append(columns[i].Todos, todo)
+            }
+      }
+      return columns
+}
+
+// groupTodosByTag はタグごとの列を作ります。列の中は positions（タグの列での並び順）の順に並べます。
+// タグの付いていない Todo は最後の列にまとめます。
+func groupTodosByTag(tags []Tag, todos []Todo, positions []TodoTag) []BoardColumn {
+      columns := make([]BoardColumn, len(tags), len(tags)+1)
+      index := map[uint]int{}
+      for i, tag := range tags {
+            tagID := tag.ID
+            columns[i] = BoardColumn{Key: "tag-" + tag.Name, Name: tag.Name, TagID: &tagID}
+            index[tag.ID] = i
+      }
+      byID := map[uint]Todo{}
+      untagged := BoardColumn{Key: "untagged", Name: ""}
+      for _, todo := range todos {
+            byID[todo.ID] = todo
+            if len(todo.Tags) == 0 {
+                  untagged.Todos = append(untagged.Todos, todo)
+            }
+      }
+      for _, position := range positions {
+            todo, ok := byID[position.TodoID]
+            if !ok {
+                  continue
+            }
+            if i, ok := index[position.TagID]; ok {
+                  columns[i].Todos = append(columns[i].Todos, todo)
+            }
+      }
+      return append(columns, untagged)
+}
+
+// moveTodoTag はタグの列の間で Todo を移動したときに、移動元のタグを外して移動先のタグを付けます。
+func moveTodoTag(tx *gorm.DB, todoID uint, fromTagID *uint, toTagID uint) error {
+      if _, err := findTags(tx, []uint{toTagID}); err != nil {
+            return err
+      }
+      if fromTagID != nil && *fromTagID != toTagID {
+            if err := tx.Exec("DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?", todoID, *fromTagID).Error; err != nil {
+                  return err
+            }
+      }
+      return tx.Exec("INSERT INTO todo_tags (todo_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING", todoID, toTagID).Error
+}
+
+func (m *TodoModel) ConvertBoardToOutput(board Board, columns []BoardColumn) requests.BoardOutput {
+      var columnOutputs []requests.BoardColumnOutput
+      for _, column := range columns {
+            todos := m.ConvertTodosToOutput(column.Todos)
+            if todos == nil {
+                  todos = []requests.GetTodoOutput{}
+            }
+            columnOutputs = append(columnOutputs, requests.BoardColumnOutput{
+                  Key:      column.Key,
+                  Name:     column.Name,
+                  StatusID: column.StatusID,
+                  TagID:    column.TagID,
+                  Todos:    todos,
+            })
+      }
+      return requests.BoardOutput{
+            ID:      board.ID,
+            Name:    board.Name,
+            GroupBy: board.GroupBy,
+            Columns: columnOutputs,
+      }
+}
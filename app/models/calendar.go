@@ -0,0 +1,163 @@
+package models
+
+import (
+	"app/pkg/ical"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+      // CalendarComponentTodo は Todo を VTODO として出力します。
+      CalendarComponentTodo = "vtodo"
+      // CalendarComponentEvent は Todo を期限の日時の VEVENT として出力します。VTODO に対応していないカレンダーアプリ向けです。
+      CalendarComponentEvent = "vevent"
+)
+
+// ErrInvalidCalendarComponent は対応していない component が指定された場合に返されます。
+var ErrInvalidCalendarComponent = errors.New("component must be vtodo or vevent")
+
+// RegenerateCalendarToken はカレンダーのフィード用のトークンを作り直します。以前のトークンは使えなくなります。
+// DB にはトークンのハッシュだけを保存するので、トークンはこのときにしか取得できません。
+func (m *TodoModel) RegenerateCalendarToken(userID uint) (string, error) {
+      buf := make([]byte, 32)
+      if _, err := rand.Read(buf); err != nil {
+            return "", err
+      }
+      token := base64.RawURLEncoding.EncodeToString(buf)
+      hash := hashCalendarToken(token)
+
+      result := m.DB.Model(&User{}).Where("id = ?", userID).Update("calendar_token_hash", hash)
+      if result.Error != nil {
+            return "", result.Error
+      }
+      if result.RowsAffected == 0 {
+            return "", gorm.ErrRecordNotFound
+      }
+      return token, nil
+}
+
+// RevokeCalendarToken はカレンダーのフィード用のトークンを無効にします。
+func (m *TodoModel) RevokeCalendarToken(userID uint) error {
+      return m.DB.Model(&User{}).Where("id = ?", userID).Update("calendar_token_hash", nil).Error
+}
+
+// GetUserByCalendarToken はフィード用のトークンの持ち主を返します。
+func (m *TodoModel) GetUserByCalendarToken(token string) (User, error) {
+      var user User
+      if token == "" {
+            return User{}, gorm.ErrRecordNotFound
+      }
+      if err := m.DB.Where("calendar_token_hash = ?", hashCalendarToken(token)).First(&user).Error; err != nil {
+            return User{}, err
+      }
+      return user, nil
+}
+
+// GetCalendarTodos はユーザーが担当している（ウォッチャーとして見ているだけのものは除く）、期限のある Todo を返します。
+// 参加しているすべてのワークスペースの Todo が対象です。
+func (m *TodoModel) GetCalendarTodos(userID uint) ([]Todo, error) {
+      var todos []Todo
+      err := preloadTodo(m.AcrossWorkspaces().DB).
+            Where("todos.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)", userID).
+            Where("todos.id IN (SELECT todo_id FROM user_todos WHERE user_id = ? AND role <> ?)", userID, AssigneeRoleWatcher).
+            Where("todos.deadline > ?", time.Time{}).
+            Scopes(excludeArchivedProjects).
+            Order("todos.deadline").Order("todos.id").
+            Find(&todos).Error
+      if err != nil {
+            return nil, err
+      }
+      return todos, nil
+}
+
+func hashCalendarToken(token string) string {
+      sum := sha256.Sum256([]byte(token))
+      return hex.EncodeToString(sum[:])
+}
+
+// ConvertTodosToICal は Todo を iCalendar 形式に変換します。component は vtodo または vevent です。
+// uidDomain は UID の @ 以降に使います（例: todo-1@example.com）。
+func (m *TodoModel) ConvertTodosToICal(todos []Todo, component string, uidDomain string) (string, error) {
+      if component != CalendarComponentTodo && component != CalendarComponentEvent {
+            return "", ErrInvalidCalendarComponent
+      }
+      calendar := ical.NewComponent("VCALENDAR")
+      calendar.Add("VERSION", "2.0")
+      calendar.Add("PRODID", "-//go-todo-app//ical feed//JA")
+      calendar.Add("CALSCALE", "GREGORIAN")
+      calendar.AddText("X-WR-CALNAME", "Todo")
+
+      now := time.Now()
+      for _, todo := range todos {
+            var entry *ical.Component
+            if component == CalendarComponentEvent {
+                  entry = todoToVEvent(todo)
+            } else {
+                  entry = todoToVTodo(todo)
+            }
+            entry.Add("UID", fmt.Sprintf("todo-%d@%s", todo.ID, uidDomain))
+            entry.AddTime("DTSTAMP", now)
+            entry.AddTime("LAST-MODIFIED", todo.UpdatedAt)
+            entry.Add("SEQUENCE", fmt.Sprint(todo.Version))
+            entry.AddText("SUMMARY", todo.Title)
+            if todo.Description != "" {
+                  entry.AddText("DESCRIPTION", todo.Description)
+            }
+            if len(todo.Tags) > 0 {
+                  names := make([]string, len(todo.Tags))
+                  for i, tag := range todo.Tags {
+                        names[i] = ical.EscapeText(tag.Name)
+                  }
+                  entry.Add("CATEGORIES", strings.Join(names, ","))
+            }
+            // P0〜P4 を iCalendar の 1（高い）〜 9（低い）にする
+            entry.Add("PRIORITY", fmt.Sprint(todo.Priority*2+1))
+            calendar.Components = append(calendar.Components, entry)
+      }
+      return calendar.Encode(), nil
+}
+
+func todoToVTodo(todo Todo) *ical.Component {
+      entry := ical.NewComponent("VTODO")
+      if todo.DeadlineAllDay {
+            entry.AddDate("DUE", todo.Deadline.UTC())
+      } else {
+            entry.AddTime("DUE", todo.Deadline)
+      }
+      switch {
+      case todo.Status.IsDone:
+            entry.Add("STATUS", "COMPLETED")
+            if todo.StatusChangedAt != nil {
+                  entry.AddTime("COMPLETED", *todo.StatusChangedAt)
+            }
+      case todo.Status.IsDefault:
+            entry.Add("STATUS", "NEEDS-ACTION")
+      default:
+            entry.Add("STATUS", "IN-PROCESS")
+      }
+      return entry
+}
+
+func todoToVEvent(todo Todo) *ical.Component {
+      entry := ical.NewComponent("VEVENT")
+      if todo.DeadlineAllDay {
+            entry.AddDate("DTSTART", todo.Deadline.UTC())
+            entry.AddDate("DTEND", todo.Deadline.UTC().AddDate(0, 0, 1))
+      } else {
+            entry.AddTime("DTSTART", todo.Deadline)
+            entry.AddTime("DTEND", todo.Deadline)
+      }
+      // VEVENT には完了の状態が無いので、ステータス名を付けておく
+      entry.Add("STATUS", "CONFIRMED")
+      entry.AddText("X-TODO-STATUS", todo.Status.Name)
+      entry.Add("TRANSP", "TRANSPARENT")
+      return entry
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"app/requests"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChecklistItem は Todo の中のチェックリストの 1 項目です。サブタスクにするほどではない手順に使います。
+type ChecklistItem struct {
+      ID        uint      `gorm:"primary_key" json:"id"`
+      TodoID    uint      `gorm:"not null;index" json:"todo_id"`
+      Text      string    `gorm:"not null" json:"text"`
+      Done      bool      `gorm:"not null;default:false" json:"done"`
+      Position  int       `gorm:"not null;default:0" json:"position"`
+      CreatedAt time.Time `json:"created_at"`
+      UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrChecklistOrderMismatch は並び替えで指定された項目が、Todo のチェックリストと一致しない場合に返されます。
+var ErrChecklistOrderMismatch = errors.New("item_ids must contain every checklist item exactly once")
+
+func (m *TodoModel) GetChecklistItems(todoID uint) ([]ChecklistItem, error) {
+      if _, err := m.GetTodoByID(todoID); err != nil {
+            return nil, err
+      }
+      var items []ChecklistItem
+      if err := m.DB.Where("todo_id = ?", todoID).Order("position, id").Find(&items).Error; err != nil {
+            return nil, err
+      }
+      return items, nil
+}
+
+// CreateChecklistItem はチェックリストの末尾に項目を追加します。
+func (m *TodoModel) CreateChecklistItem(todoID uint, input requests.CreateChecklistItemInput) (ChecklistItem, error) {
+      newItem := ChecklistItem{
+            TodoID: todoID,
+            Text:   input.Text,
+            Done:   input.Done,
+      }
+
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := lockTodo(tx, todoID); err != nil {
+                  return err
+            }
+            var last struct{ Position *int }
+            if err := tx.Model(&ChecklistItem{}).Select("MAX(position) AS position").Where("todo_id = ?", todoID).Scan(&last).Error; err != nil {
+                  return err
+            }
+            if last.Position != nil {
+                  newItem.Position = *last.Position + 1
+            }
+            if err := tx.Create(&newItem).Error; err != nil {
+                  return err
+            }
+            return touchTodo(tx, todoID)
+      })
+      if err != nil {
+            return ChecklistItem{}, err
+      }
+      return newItem, nil
+}
+
+func (m *TodoModel) UpdateChecklistItem(todoID uint, itemID uint, input requests.UpdateChecklistItemInput) (ChecklistItem, error) {
+      var item ChecklistItem
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := lockTodo(tx, todoID); err != nil {
+                  return err
+            }
+            if err := tx.Where("id = ? AND todo_id = ?", itemID, todoID).First(&item).Error; err != nil {
+                  return err
+            }
+
+            updates := map[string]interface{}{}
+            if input.Text != "" {
+                  updates["text"] = input.Text
+            }
+            if input.Done != nil {
+                  updates["done"] = *input.Done
+            }
+            if len(updates) == 0 {
+                  return nil
+            }
+            if err := tx.Model(&item).Updates(updates).Error; err != nil {
+                  return err
+            }
+            return touchTodo(tx, todoID)
+      })
+      if err != nil {
+            return ChecklistItem{}, err
+      }
+      return item, nil
+}
+
+func (m *TodoModel) DeleteChecklistItem(todoID uint, itemID uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := lockTodo(tx, todoID); err != nil {
+                  return err
+            }
+            result := tx.Where("id = ? AND todo_id = ?", itemID, todoID).Delete(&ChecklistItem{})
+            if result.Error != nil {
+                  return result.Error
+            }
+            if result.RowsAffected == 0 {
+                  return gorm.ErrRecordNotFound
+            }
+            return touchTodo(tx, todoID)
+      })
+}
+
+// ReorderChecklist はチェックリストを itemIDs の順番に並び替えます。itemIDs にはすべての項目を含める必要があります。
+func (m *TodoModel) ReorderChecklist(todoID uint, itemIDs []uint) ([]ChecklistItem, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := lockTodo(tx, todoID); err != nil {
+                  return err
+            }
+            var ids []uint
+            if err := tx.Model(&ChecklistItem{}).Where("todo_id = ?", todoID).Pluck("id", &ids).Error; err != nil {
+                  return err
+            }
+            if len(ids) != len(itemIDs) || len(uniqueIDs(itemIDs)) != len(itemIDs) {
+                  return ErrChecklistOrderMismatch
+            }
+            existing := map[uint]bool{}
+            for _, id := range ids {
+                  existing[id] = true
+            }
+            for position, id := range itemIDs {
+                  if !existing[id] {
+                        return ErrChecklistOrderMismatch
+                  }
+                  if err := tx.Model(&ChecklistItem{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+                        return err
+                  }
+            }
+            return touchTodo(tx, todoID)
+      })
+      if err != nil {
+            return nil, err
+      }
+      return m.GetChecklistItems(todoID)
+}
+
+// PromoteChecklistItem はチェックリストの項目をサブタスクに変換します。
+// サブタスクの担当者は親の Todo と同じになり、元の項目は削除されます。
+func (m *TodoModel) PromoteChecklistItem(todoID uint, itemID uint) (Todo, error) {
+      var subtask Todo
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := lockTodo(tx, todoID); err != nil {
+                  return err
+            }
+            var parent Todo
+            if err := tx.Preload("Assignees").Preload("Tags").Where("id = ?", todoID).First(&parent).Error; err != nil {
+                  return err
+            }
+            var item ChecklistItem
+            if err := tx.Where("id = ? AND todo_id = ?", itemID, todoID).First(&item).Error; err != nil {
+                  return err
+            }
+
+            parentID := parent.ID
+            subtask = Todo{
+                  WorkspaceID: parent.WorkspaceID,
+                  ProjectID: parent.ProjectID,
+                  Title:    item.Text,
+                  Tags:     parent.Tags,
+                  Deadline: parent.Deadline,
+                  DeadlineAllDay: parent.DeadlineAllDay,
+                  ParentID: &parentID,
+            }
+            // 完了済みの項目は完了扱いのステータスで作成する
+            if item.Done {
+                  var doneStatus Status
+                  if err := tx.Where("is_done = ?", true).Order("position").First(&doneStatus).Error; err != nil {
+                        return err
+                  }
+                  subtask.StatusID = doneStatus.ID
+            }
+            if err := createTodo(tx, &subtask, parent.Assignees); err != nil {
+                  return err
+            }
+
+            if err := tx.Delete(&item).Error; err != nil {
+                  return err
+            }
+            return touchTodo(tx, todoID)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(subtask.ID)
+}
+
+// lockTodo はチェックリストなど Todo に属するデータを変更する間、Todo の行をロックします。
+func lockTodo(tx *gorm.DB, todoID uint) error {
+      return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", todoID).First(&Todo{}).Error
+}
+
+// touchTodo は Todo に属するデータが変わったときに、Todo の version を進めて ETag を変えます。
+func touchTodo(tx *gorm.DB, todoID uint) error {
+      return tx.Model(&Todo{}).Where("id = ?", todoID).Updates(map[string]interface{}{
+            "version":    gorm.Expr("version + 1"),
+            "updated_at": time.Now(),
+      }).Error
+}
+
+func (m *TodoModel) ConvertChecklistItemToOutput(item ChecklistItem) requests.ChecklistItemOutput {
+      return requests.ChecklistItemOutput{
+            ID:       item.ID,
+            Text:     item.Text,
+            Done:     item.Done,
+            Position: item.Position,
+      }
+}
+
+func (m *TodoModel) ConvertChecklistToOutput(items []ChecklistItem) []requests.ChecklistItemOutput {
+      output := []requests.ChecklistItemOutput{}
+      for _, item := range items {
+            output = append(output, m.ConvertChecklistItemToOutput(item))
+      }
+      return output
+}
+
+func convertChecklistSummaryToOutput(items []ChecklistItem) requests.ChecklistSummaryOutput {
+      summary := requests.ChecklistSummaryOutput{Total: len(items)}
+      for _, item := range items {
+            if item.Done {
+                  summary.Done++
+            }
+      }
+      return summary
+}
@@ -0,0 +1,346 @@
+package models
+
+import (
+	"app/pkg/utils"
+	"app/requests"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Comment は Todo に付けるコメントです。本文は Markdown で、クライアントで表示します。
+// 返信は 1 段だけで、返信への返信は同じスレッド（最初のコメント）への返信として保存します。
+type Comment struct {
+      ID     uint `gorm:"primary_key" json:"id"`
+      TodoID uint `gorm:"not null;index" json:"todo_id"`
+      // 返信先のコメント。スレッドの最初のコメントの場合は nil です。
+      ParentID *uint     `gorm:"index" json:"parent_id"`
+      AuthorID uint      `gorm:"not null" json:"author_id"`
+      Author   User      `json:"author"`
+      Body     string    `gorm:"type:text;not null" json:"body"`
+      Replies  []Comment `gorm:"foreignKey:ParentID" json:"replies"`
+      // 本文の @ で指定されたユーザー（ワークスペースのメンバーのみ）
+      Mentions  []User     `gorm:"many2many:comment_mentions;" json:"mentions"`
+      EditedAt  *time.Time `json:"edited_at"`
+      CreatedAt time.Time  `json:"created_at"`
+      UpdatedAt time.Time  `json:"updated_at"`
+      // 論理削除。返信が残っている場合は、本文を隠して一覧に残します。
+      DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// CommentRevision はコメントの本文の履歴です。作成時と編集のたびに 1 件ずつ増えます。
+type CommentRevision struct {
+      ID         uint      `gorm:"primary_key" json:"id"`
+      CommentID  uint      `gorm:"not null;index" json:"comment_id"`
+      Body       string    `gorm:"type:text;not null" json:"body"`
+      EditedByID uint      `gorm:"not null" json:"edited_by_id"`
+      EditedBy   User      `json:"edited_by"`
+      CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+      // defaultPerPage と maxPerPage はページ分けして返す一覧の件数です。
+      defaultPerPage = 20
+      maxPerPage     = 100
+)
+
+var (
+      // ErrCommentForbidden は他のユーザーのコメントを編集・削除しようとした場合に返されます。
+      ErrCommentForbidden = errors.New("you cannot change another user's comment")
+      // ErrEmptyComment は本文が空白だけのコメントを保存しようとした場合に返されます。
+      ErrEmptyComment = errors.New("comment body must not be empty")
+)
+
+// GetComments は Todo のスレッドを古い順に、返信と一緒に返します。
+// 削除されたコメントは、返信が残っている場合だけ本文を隠して返します。
+func (m *TodoModel) GetComments(todoID uint, query requests.PageQuery) ([]Comment, requests.PageOutput, error) {
+      if _, err := findScopedTodo(m.DB, todoID); err != nil {
+            return nil, requests.PageOutput{}, err
+      }
+      page := normalizePage(query)
+
+      threads := m.DB.Unscoped().Model(&Comment{}).
+            Where("todo_id = ? AND parent_id IS NULL", todoID).
+            Where("deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments replies WHERE replies.parent_id = comments.id AND replies.deleted_at IS NULL)")
+      if err := threads.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+            return nil, requests.PageOutput{}, err
+      }
+      page.TotalPages = int((page.Total + int64(page.PerPage) - 1) / int64(page.PerPage))
+
+      var comments []Comment
+      err := preloadComment(threads).
+            Order("created_at, id").
+            Offset((page.Page - 1) * page.PerPage).Limit(page.PerPage).
+            Find(&comments).Error
+      if err != nil {
+            return nil, requests.PageOutput{}, err
+      }
+      return comments, page, nil
+}
+
+// GetComment はコメントを返信と一緒に返します。
+func (m *TodoModel) GetComment(todoID uint, commentID uint) (Comment, error) {
+      if _, err := findScopedTodo(m.DB, todoID); err != nil {
+            return Comment{}, err
+      }
+      var comment Comment
+      if err := preloadComment(m.DB).Where("id = ? AND todo_id = ?", commentID, todoID).First(&comment).Error; err != nil {
+            return Comment{}, err
+      }
+      return comment, nil
+}
+
+// CreateComment はコメントを作成します。parent_id を指定した場合は、そのコメントのスレッドへの返信になります。
+func (m *TodoModel) CreateComment(todoID uint, input requests.CreateCommentInput) (Comment, error) {
+      body := strings.TrimSpace(input.Body)
+      if body == "" {
+            return Comment{}, ErrEmptyComment
+      }
+      var comment Comment
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            todo, err := findScopedTodo(tx, todoID)
+            if err != nil {
+                  return err
+            }
+            comment = Comment{TodoID: todo.ID, AuthorID: m.UserID, Body: body}
+            if input.ParentID != nil {
+                  var parent Comment
+                  if err := tx.Where("id = ? AND todo_id = ?", *input.ParentID, todo.ID).First(&parent).Error; err != nil {
+                        return err
+                  }
+                  // 返信への返信は、スレッドの最初のコメントへの返信にする
+                  threadID := parent.ID
+                  if parent.ParentID != nil {
+                        threadID = *parent.ParentID
+                  }
+                  comment.ParentID = &threadID
+            }
+            if comment.Mentions, err = resolveMentions(tx, todo.WorkspaceID, body); err != nil {
+                  return err
+            }
+            if err := tx.Omit("Author", "Replies", "Mentions.*").Create(&comment).Error; err != nil {
+                  return err
+            }
+            if err := tx.Create(&CommentRevision{CommentID: comment.ID, Body: body, EditedByID: m.UserID}).Error; err != nil {
+                  return err
+            }
+            return preloadComment(tx).Where("id = ?", comment.ID).First(&comment).Error
+      })
+      if err != nil {
+            return Comment{}, err
+      }
+      return comment, nil
+}
+
+// UpdateComment はコメントの本文を変更します。変更できるのは書いた本人だけです。以前の本文は履歴に残ります。
+func (m *TodoModel) UpdateComment(todoID uint, commentID uint, input requests.UpdateCommentInput) (Comment, error) {
+      body := strings.TrimSpace(input.Body)
+      if body == "" {
+            return Comment{}, ErrEmptyComment
+      }
+      var comment Comment
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            todo, err := findScopedTodo(tx, todoID)
+            if err != nil {
+                  return err
+            }
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND todo_id = ?", commentID, todo.ID).First(&comment).Error; err != nil {
+                  return err
+            }
+            if comment.AuthorID != m.UserID {
+                  return ErrCommentForbidden
+            }
+            if comment.Body != body {
+                  mentions, err := resolveMentions(tx, todo.WorkspaceID, body)
+                  if err != nil {
+                        return err
+                  }
+                  now := time.Now()
+                  if err := tx.Model(&comment).Updates(map[string]interface{}{"body": body, "edited_at": now}).Error; err != nil {
+                        return err
+                  }
+                  if err := tx.Model(&comment).Association("Mentions").Replace(mentions); err != nil {
+                        return err
+                  }
+                  if err := tx.Create(&CommentRevision{CommentID: comment.ID, Body: body, EditedByID: m.UserID}).Error; err != nil {
+                        return err
+                  }
+            }
+            return preloadComment(tx).Where("id = ?", comment.ID).First(&comment).Error
+      })
+      if err != nil {
+            return Comment{}, err
+      }
+      return comment, nil
+}
+
+// DeleteComment はコメントを削除（論理削除）します。削除できるのは書いた本人と、ワークスペースの持ち主・管理者です。
+// 返信は削除しないので、スレッドの最初のコメントを削除しても返信は読めます。
+func (m *TodoModel) DeleteComment(todoID uint, commentID uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            todo, err := findScopedTodo(tx, todoID)
+            if err != nil {
+                  return err
+            }
+            var comment Comment
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND todo_id = ?", commentID, todo.ID).First(&comment).Error; err != nil {
+                  return err
+            }
+            if comment.AuthorID != m.UserID {
+                  err := m.requireWorkspaceRole(tx, todo.WorkspaceID, m.UserID, WorkspaceRoleOwner, WorkspaceRoleAdmin)
+                  if errors.Is(err, ErrWorkspaceForbidden) {
+                        return ErrCommentForbidden
+                  }
+                  if err != nil {
+                        return err
+                  }
+            }
+            return tx.Delete(&comment).Error
+      })
+}
+
+// GetCommentHistory はコメントの本文の履歴を古い順に返します。最初のものが作成時の本文です。
+func (m *TodoModel) GetCommentHistory(todoID uint, commentID uint) ([]CommentRevision, error) {
+      if _, err := m.GetComment(todoID, commentID); err != nil {
+            return nil, err
+      }
+      var revisions []CommentRevision
+      if err := m.DB.Preload("EditedBy").Where("comment_id = ?", commentID).Order("created_at, id").Find(&revisions).Error; err != nil {
+            return nil, err
+      }
+      return revisions, nil
+}
+
+func preloadComment(db *gorm.DB) *gorm.DB {
+      return db.Preload("Author").Preload("Mentions").
+            // 一覧ではスレッドの最初のコメントを Unscoped で取得するが、削除した返信は含めない
+            Preload("Replies", func(db *gorm.DB) *gorm.DB {
+                  return db.Where("deleted_at IS NULL").Order("created_at, id")
+            }).
+            Preload("Replies.Author").Preload("Replies.Mentions")
+}
+
+// resolveMentions は本文のメンションを、ワークスペースのメンバーに解決します。
+// @user@example.com はメールアドレス、@name は名前かメールアドレスの @ より前の部分が一致するユーザーです（大文字・小文字は区別しない）。
+// 該当するユーザーがいない、または複数いる場合は無視します。
+func resolveMentions(tx *gorm.DB, workspaceID uint, body string) ([]User, error) {
+      mentions := utils.ParseMentions(body)
+      if len(mentions) == 0 {
+            return []User{}, nil
+      }
+      var members []User
+      err := tx.Where("id IN (SELECT user_id FROM workspace_members WHERE workspace_id = ?)", workspaceID).
+            Order("id").Find(&members).Error
+      if err != nil {
+            return nil, err
+      }
+
+      users := []User{}
+      added := map[uint]bool{}
+      for _, mention := range mentions {
+            var matched []User
+            for _, member := range members {
+                  local, _, _ := strings.Cut(member.Email, "@")
+                  switch {
+                  case strings.Contains(mention, "@"):
+                        if strings.EqualFold(member.Email, mention) {
+                              matched = append(matched, member)
+                        }
+                  case strings.EqualFold(member.Name, mention), strings.EqualFold(local, mention):
+                        matched = append(matched, member)
+                  }
+            }
+            if len(matched) == 1 && !added[matched[0].ID] {
+                  added[matched[0].ID] = true
+                  users = append(users, matched[0])
+            }
+      }
+      return users, nil
+}
+
+func normalizePage(query requests.PageQuery) requests.PageOutput {
+      page := requests.PageOutput{Page: query.Page, PerPage: query.PerPage}
+      if page.Page < 1 {
+            page.Page = 1
+      }
+      if page.PerPage < 1 {
+            page.PerPage = defaultPerPage
+      }
+      if page.PerPage > maxPerPage {
+            page.PerPage = maxPerPage
+      }
+      return page
+}
+
+func convertUserToOutput(user User) requests.AuthOutput {
+      return requests.AuthOutput{
+            ID:       user.ID,
+            Name:     user.Name,
+            Email:    user.Email,
+            TimeZone: user.TimeZone,
+      }
+}
+
+func (m *TodoModel) ConvertCommentToOutput(comment Comment) requests.CommentOutput {
+      output := requests.CommentOutput{
+            ID:        comment.ID,
+            TodoID:    comment.TodoID,
+            ParentID:  comment.ParentID,
+            Author:    convertUserToOutput(comment.Author),
+            Body:      comment.Body,
+            Mentions:  []requests.AuthOutput{},
+            Edited:    comment.EditedAt != nil,
+            EditedAt:  comment.EditedAt,
+            Deleted:   comment.DeletedAt.Valid,
+            CreatedAt: comment.CreatedAt,
+      }
+      if output.Deleted {
+            output.Body = ""
+      } else {
+            for _, user := range comment.Mentions {
+                  output.Mentions = append(output.Mentions, convertUserToOutput(user))
+            }
+      }
+      if comment.ParentID == nil {
+            output.Replies = []requests.CommentOutput{}
+            for _, reply := range comment.Replies {
+                  output.Replies = append(output.Replies, m.ConvertCommentToOutput(reply))
+            }
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertCommentsToOutput(comments []Comment) []requests.CommentOutput {
+      output := []requests.CommentOutput{}
+      for _, comment := range comments {
+            output = append(output, m.ConvertCommentToOutput(comment))
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertCommentRevisionsToOutput(revisions []CommentRevision) []requests.CommentRevisionOutput {
+      output := []requests.CommentRevisionOutput{}
+      for _, revision := range revisions {
+            output = append(output, requests.CommentRevisionOutput{
+                  Body:     revision.Body,
+                  EditedBy: convertUserToOutput(revision.EditedBy),
+                  EditedAt: revision.CreatedAt,
+            })
+      }
+      return output
+}
+
+// purgeComments は完全に削除する Todo のコメント（論理削除したものを含む）と履歴・メンションを削除します。
+func purgeComments(tx *gorm.DB, todoIDs []uint) error {
+      comments := tx.Unscoped().Model(&Comment{}).Select("id").Where("todo_id IN ?", todoIDs)
+      if err := tx.Exec("DELETE FROM comment_mentions WHERE comment_id IN (?)", comments).Error; err != nil {
+            return err
+      }
+      if err := tx.Where("comment_id IN (?)", comments).Delete(&CommentRevision{}).Error; err != nil {
+            return err
+      }
+      return tx.Unscoped().Where("todo_id IN ?", todoIDs).Delete(&Comment{}).Error
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+      testDBOnce sync.Once
+      testDB     *gorm.DB
+      testDBErr  error
+)
+
+// openTestDB は環境変数 TEST_DATABASE_DSN の Postgres に接続して、テーブルを作成します。
+// 設定されていない場合はテストをスキップします。
+func openTestDB(t *testing.T) *gorm.DB {
+      t.Helper()
+      dsn := os.Getenv("TEST_DATABASE_DSN")
+      if dsn == "" {
+            t.Skip("TEST_DATABASE_DSN is not set")
+      }
+      testDBOnce.Do(func() {
+            db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+            if err != nil {
+                  testDBErr = err
+                  return
+            }
+            if err := db.AutoMigrate(&Status{}, &Todo{}, &User{}, &TodoAssignee{}, &TodoStatusHistory{}, &Board{}, &ChecklistItem{}, &Tag{}, &RecurrenceSeries{}, &ReminderRule{}, &ReminderDelivery{}, &ImportJob{}, &Workspace{}, &WorkspaceMember{}, &WorkspaceInvitation{}, &Project{}, &ProjectMember{}, &Comment{}, &CommentRevision{}, &Attachment{}, &BlobDeletion{}, &TodoEvent{}, &TodoOperation{}, &TodoDependency{}, &TimeEntry{}, &TodoTag{}); err != nil {
+                  testDBErr = err
+                  return
+            }
+            testDB, testDBErr = db, RegisterWorkspaceScope(db)
+      })
+      if testDBErr != nil {
+            t.Fatal(testDBErr)
+      }
+      return testDB
+}
+
+// newTestModel はテストごとに新しいユーザーとワークスペースを作り、そのワークスペースを扱う TodoModel を返します。
+func newTestModel(t *testing.T) (*TodoModel, User) {
+      t.Helper()
+      db := openTestDB(t)
+      user := User{Name: "test", Email: fmt.Sprintf("test-%d@example.com", time.Now().UnixNano()), Password: "password"}
+      if err := db.Create(&user).Error; err != nil {
+            t.Fatal(err)
+      }
+      var member WorkspaceMember
+      err := db.Transaction(func(tx *gorm.DB) error {
+            var err error
+            member, err = createWorkspace(tx, user.ID, user.Name)
+            return err
+      })
+      if err != nil {
+            t.Fatal(err)
+      }
+      return NewTodoModel(db).WithWorkspace(member.WorkspaceID).WithUser(user.ID), user
+}
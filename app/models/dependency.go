@@ -0,0 +1,422 @@
+package models
+
+import (
+	"app/requests"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dependencyLockKey は依存関係を変更するときに取る advisory lock のキーです。
+// 同時に追加が行われて循環ができてしまうのを防ぎます。
+const dependencyLockKey = 30031
+
+// TodoDependency は TodoID の Todo が、DependsOnID の Todo（先行タスク）の完了を待っていることを表します。
+// 同じワークスペースの Todo の間にだけ作れます。Todo をゴミ箱へ移動しても依存関係は残り、復元すると元に戻ります。
+type TodoDependency struct {
+      TodoID      uint      `gorm:"primaryKey;autoIncrement:false" json:"todo_id"`
+      DependsOnID uint      `gorm:"primaryKey;autoIncrement:false;index" json:"depends_on_id"`
+      CreatedAt   time.Time `json:"created_at"`
+}
+
+// TodoDependencies は Todo の先行タスクと後続タスクです。ゴミ箱に入っているものは含めません。
+type TodoDependencies struct {
+      // 完了を待っている Todo
+      DependsOn []Todo
+      // この Todo の完了を待っている Todo
+      Blocking []Todo
+}
+
+// CriticalPath はプロジェクトの未完了の Todo を、依存関係と期限から並べた予定です（GetProjectCriticalPath を参照）。
+type CriticalPath struct {
+      Project Project
+      Start   time.Time
+      // すべての Todo が終わる見込みの日時
+      Finish time.Time
+      // 全体の終わりを決めている Todo の並び。先行タスクから順に並べます
+      Path []ScheduledTodo
+      // すべての Todo。先行タスクが後続タスクより前になるよう並べます
+      Todos []ScheduledTodo
+}
+
+// ScheduledTodo はクリティカルパスの計算で求めた Todo の予定です。
+type ScheduledTodo struct {
+      Todo           Todo
+      EarliestStart  time.Time
+      EarliestFinish time.Time
+      LatestFinish   time.Time
+      // 全体の終わりを遅らせずに遅れられる時間。0 の Todo がクリティカルパスに乗ります
+      Slack time.Duration
+}
+
+// ErrDependencyCycle は依存関係が循環してしまう追加を行おうとした場合に返されます。
+var ErrDependencyCycle = errors.New("todo cannot depend on itself, directly or indirectly")
+
+// GetTodoDependencies は Todo の先行タスクと後続タスクを返します。
+func (m *TodoModel) GetTodoDependencies(id uint) (TodoDependencies, error) {
+      if _, err := m.GetTodoByID(id); err != nil {
+            return TodoDependencies{}, err
+      }
+      dependencies := TodoDependencies{}
+      predecessors := m.DB.Model(&TodoDependency{}).Select("depends_on_id").Where("todo_id = ?", id)
+      if err := preloadTodo(m.DB).Where("id IN (?)", predecessors).Order("id").Find(&dependencies.DependsOn).Error; err != nil {
+            return TodoDependencies{}, err
+      }
+      successors := m.DB.Model(&TodoDependency{}).Select("todo_id").Where("depends_on_id = ?", id)
+      if err := preloadTodo(m.DB).Where("id IN (?)", successors).Order("id").Find(&dependencies.Blocking).Error; err != nil {
+            return TodoDependencies{}, err
+      }
+      if err := attachComputed(m.DB, dependencies.DependsOn); err != nil {
+            return TodoDependencies{}, err
+      }
+      if err := attachComputed(m.DB, dependencies.Blocking); err != nil {
+            return TodoDependencies{}, err
+      }
+      return dependencies, nil
+}
+
+// AddTodoDependency は version が一致する場合のみ、id の Todo が dependsOnID の Todo の完了を待つようにします。既にある場合は何もしません。
+func (m *TodoModel) AddTodoDependency(id uint, version uint, dependsOnID uint) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", dependencyLockKey).Error; err != nil {
+                  return err
+            }
+            if _, err := lockTodoVersion(tx, id, version); err != nil {
+                  return err
+            }
+            // 別のワークスペースの Todo は見つからない
+            if _, err := findScopedTodo(tx, dependsOnID); err != nil {
+                  return err
+            }
+            if err := checkDependency(tx, id, dependsOnID); err != nil {
+                  return err
+            }
+
+            audit, err := auditTodos(tx, TodoEventDependenciesChanged, id)
+            if err != nil {
+                  return err
+            }
+            result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&TodoDependency{TodoID: id, DependsOnID: dependsOnID})
+            if result.Error != nil {
+                  return result.Error
+            }
+            if result.RowsAffected == 0 {
+                  return nil
+            }
+            if err := touchTodo(tx, id); err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(id)
+}
+
+// RemoveTodoDependency は version が一致する場合のみ、id の Todo が dependsOnID の Todo を待たないようにします。
+func (m *TodoModel) RemoveTodoDependency(id uint, version uint, dependsOnID uint) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if _, err := lockTodoVersion(tx, id, version); err != nil {
+                  return err
+            }
+            audit, err := auditTodos(tx, TodoEventDependenciesChanged, id)
+            if err != nil {
+                  return err
+            }
+            result := tx.Where("todo_id = ? AND depends_on_id = ?", id, dependsOnID).Delete(&TodoDependency{})
+            if result.Error != nil {
+                  return result.Error
+            }
+            if result.RowsAffected == 0 {
+                  return gorm.ErrRecordNotFound
+            }
+            if err := touchTodo(tx, id); err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(id)
+}
+
+// checkDependency は id の Todo が dependsOnID の Todo を待つようにしても、循環しないことを確認します。
+func checkDependency(tx *gorm.DB, id uint, dependsOnID uint) error {
+      if id == dependsOnID {
+            return ErrDependencyCycle
+      }
+
+      // 新しい先行タスクの先行タスクをたどって、自分自身が含まれていないか確認する。
+      // ゴミ箱の Todo を経由するものも、復元したときに循環しないよう含める
+      var count int64
+      if err := tx.Raw(`WITH RECURSIVE predecessors AS (
+            SELECT depends_on_id AS id FROM todo_dependencies WHERE todo_id = ?
+            UNION
+            SELECT d.depends_on_id FROM todo_dependencies d JOIN predecessors p ON d.todo_id = p.id
+      ) SELECT COUNT(*) FROM predecessors WHERE id = ?`, dependsOnID, id).Scan(&count).Error; err != nil {
+            return err
+      }
+      if count > 0 {
+            return ErrDependencyCycle
+      }
+      return nil
+}
+
+// replaceTodoDependencies は取り消しのために Todo の先行タスクを dependsOnIDs に置き換えます。
+// 先行タスクが完全に削除されている場合や、戻すと循環する場合は ErrUndoConflict を返します。
+func replaceTodoDependencies(tx *gorm.DB, todoID uint, dependsOnIDs []uint) error {
+      if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", dependencyLockKey).Error; err != nil {
+            return err
+      }
+      if err := tx.Where("todo_id = ?", todoID).Delete(&TodoDependency{}).Error; err != nil {
+            return err
+      }
+      for _, dependsOnID := range dependsOnIDs {
+            _, err := findScopedTodo(tx.Unscoped(), dependsOnID)
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                  return fmt.Errorf("%w: todo %d has been deleted", ErrUndoConflict, dependsOnID)
+            }
+            if err != nil {
+                  return err
+            }
+            err = checkDependency(tx, todoID, dependsOnID)
+            if errors.Is(err, ErrDependencyCycle) {
+                  return fmt.Errorf("%w: todo %d cannot depend on todo %d again", ErrUndoConflict, todoID, dependsOnID)
+            }
+            if err != nil {
+                  return err
+            }
+            if err := tx.Create(&TodoDependency{TodoID: todoID, DependsOnID: dependsOnID}).Error; err != nil {
+                  return err
+            }
+      }
+      return nil
+}
+
+// attachBlocked は todos それぞれについて、完了していない先行タスクを求めて BlockedBy と Blocked に設定します。
+// ゴミ箱に入っている先行タスクは待ちません。
+func attachBlocked(db *gorm.DB, todos []Todo) error {
+      if len(todos) == 0 {
+            return nil
+      }
+      ids := make([]uint, len(todos))
+      for i, todo := range todos {
+            ids[i] = todo.ID
+      }
+
+      var rows []struct {
+            TodoID      uint
+            DependsOnID uint
+      }
+      if err := db.Raw(`SELECT d.todo_id, d.depends_on_id
+      FROM todo_dependencies d
+      JOIN todos t ON t.id = d.depends_on_id AND t.deleted_at IS NULL
+      JOIN statuses s ON s.id = t.status_id
+      WHERE d.todo_id IN ? AND NOT s.is_done
+      ORDER BY d.depends_on_id`, ids).Scan(&rows).Error; err != nil {
+            return err
+      }
+
+      blockedBy := map[uint][]uint{}
+      for _, row := range rows {
+            blockedBy[row.TodoID] = append(blockedBy[row.TodoID], row.DependsOnID)
+      }
+      for i := range todos {
+            todos[i].BlockedBy = blockedBy[todos[i].ID]
+            todos[i].Blocked = len(todos[i].BlockedBy) > 0
+      }
+      return nil
+}
+
+// GetProjectCriticalPath はプロジェクトの未完了の Todo について、依存関係と期限から予定を立て、クリティカルパスを求めます。
+//
+// 期限をその Todo が終わる予定の日時とみなし、先行タスクが終わってから（先行タスクが無ければ今日かプロジェクトの開始日から）
+// 期限までをその Todo にかかる時間とします。先行タスクの終わりが期限より後になる場合は、かかる時間を 0 とし、期限に遅れる見込みとします。
+// 期限の無い Todo は、先行タスクが終わったらすぐに終わるものとします。
+// プロジェクトの外の Todo と、完了した Todo への依存関係は予定に影響しません。
+func (m *TodoModel) GetProjectCriticalPath(projectID uint) (CriticalPath, error) {
+      project, err := m.GetProjectByID(projectID)
+      if err != nil {
+            return CriticalPath{}, err
+      }
+      var todos []Todo
+      err = preloadTodo(m.DB).
+            Joins("JOIN statuses ON statuses.id = todos.status_id AND NOT statuses.is_done").
+            Where("todos.project_id = ?", projectID).
+            Order("todos.id").
+            Find(&todos).Error
+      if err != nil {
+            return CriticalPath{}, err
+      }
+      if err := attachComputed(m.DB, todos); err != nil {
+            return CriticalPath{}, err
+      }
+
+      var edges []TodoDependency
+      if len(todos) > 0 {
+            ids := make([]uint, len(todos))
+            for i, todo := range todos {
+                  ids[i] = todo.ID
+            }
+            if err := m.DB.Where("todo_id IN ? AND depends_on_id IN ?", ids, ids).Find(&edges).Error; err != nil {
+                  return CriticalPath{}, err
+            }
+      }
+
+      start := time.Now()
+      if project.StartDate != nil && project.StartDate.After(start) {
+            start = *project.StartDate
+      }
+      return scheduleTodos(project, todos, edges, start), nil
+}
+
+// scheduleTodos は todos の予定を先行タスクから順に（フォワードパス）、全体の終わりから逆に（バックワードパス）求めます。
+func scheduleTodos(project Project, todos []Todo, edges []TodoDependency, start time.Time) CriticalPath {
+      predecessors := map[uint][]uint{}
+      successors := map[uint][]uint{}
+      for _, edge := range edges {
+            predecessors[edge.TodoID] = append(predecessors[edge.TodoID], edge.DependsOnID)
+            successors[edge.DependsOnID] = append(successors[edge.DependsOnID], edge.TodoID)
+      }
+
+      // 先行タスクが後続タスクより前になるよう並べる。並びが決まらないものは ID 順
+      waiting := map[uint]int{}
+      var ready []uint
+      for _, todo := range todos {
+            waiting[todo.ID] = len(predecessors[todo.ID])
+            if waiting[todo.ID] == 0 {
+                  ready = append(ready, todo.ID)
+            }
+      }
+      var order []uint
+      for len(ready) > 0 {
+            sort.Slice(ready, func(i, j int) bool { return ready[i] < ready[j] })
+            id := ready[0]
+            ready = ready[1:]
+            order = append(order, id)
+            for _, next := range successors[id] {
+                  waiting[next]--
+                  if waiting[next] == 0 {
+                        ready = append(ready, next)
+                  }
+            }
+      }
+
+      byID := map[uint]Todo{}
+      for _, todo := range todos {
+            byID[todo.ID] = todo
+      }
+      scheduled := map[uint]*ScheduledTodo{}
+      finish := start
+      for _, id := range order {
+            item := &ScheduledTodo{Todo: byID[id], EarliestStart: start}
+            for _, predecessor := range predecessors[id] {
+                  if scheduled[predecessor].EarliestFinish.After(item.EarliestStart) {
+                        item.EarliestStart = scheduled[predecessor].EarliestFinish
+                  }
+            }
+            item.EarliestFinish = item.EarliestStart
+            if deadline := item.Todo.Deadline; !deadline.IsZero() && deadline.After(item.EarliestFinish) {
+                  item.EarliestFinish = deadline
+            }
+            if item.EarliestFinish.After(finish) {
+                  finish = item.EarliestFinish
+            }
+            scheduled[id] = item
+      }
+      for i := len(order) - 1; i >= 0; i-- {
+            item := scheduled[order[i]]
+            item.LatestFinish = finish
+            for _, successor := range successors[order[i]] {
+                  next := scheduled[successor]
+                  latestStart := next.LatestFinish.Add(-next.EarliestFinish.Sub(next.EarliestStart))
+                  if latestStart.Before(item.LatestFinish) {
+                        item.LatestFinish = latestStart
+                  }
+            }
+            item.Slack = item.LatestFinish.Sub(item.EarliestFinish)
+      }
+
+      result := CriticalPath{Project: project, Start: start, Finish: finish, Path: []ScheduledTodo{}, Todos: []ScheduledTodo{}}
+      for _, id := range order {
+            result.Todos = append(result.Todos, *scheduled[id])
+      }
+
+      // 全体の終わりに終わる Todo から、開始を決めている先行タスクを順にさかのぼる
+      var current *ScheduledTodo
+      for _, id := range order {
+            item := scheduled[id]
+            if item.Slack == 0 && item.EarliestFinish.Equal(finish) && len(successors[id]) == 0 {
+                  current = item
+                  break
+            }
+      }
+      for current != nil {
+            result.Path = append([]ScheduledTodo{*current}, result.Path...)
+            var previous *ScheduledTodo
+            for _, predecessor := range predecessors[current.Todo.ID] {
+                  item := scheduled[predecessor]
+                  if item.Slack == 0 && item.EarliestFinish.Equal(current.EarliestStart) && (previous == nil || item.Todo.ID < previous.Todo.ID) {
+                        previous = item
+                  }
+            }
+            current = previous
+      }
+      return result
+}
+
+func (m *TodoModel) ConvertTodoDependenciesToOutput(dependencies TodoDependencies) requests.TodoDependenciesOutput {
+      output := requests.TodoDependenciesOutput{
+            DependsOn: []requests.GetTodoOutput{},
+            Blocking:  []requests.GetTodoOutput{},
+      }
+      for _, todo := range dependencies.DependsOn {
+            output.DependsOn = append(output.DependsOn, m.ConvertTodoToOutput(todo))
+      }
+      for _, todo := range dependencies.Blocking {
+            output.Blocking = append(output.Blocking, m.ConvertTodoToOutput(todo))
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertCriticalPathToOutput(path CriticalPath) requests.CriticalPathOutput {
+      loc := m.location()
+      output := requests.CriticalPathOutput{
+            ProjectID: path.Project.ID,
+            Start:     path.Start.In(loc),
+            Finish:    path.Finish.In(loc),
+            Path:      []requests.ScheduledTodoOutput{},
+            Todos:     []requests.ScheduledTodoOutput{},
+      }
+      // 終了日はその日の終わりまでとする
+      if path.Project.EndDate != nil {
+            output.Overrun = path.Finish.After(path.Project.EndDate.AddDate(0, 0, 1))
+      }
+      for _, item := range path.Path {
+            output.Path = append(output.Path, m.convertScheduledTodoToOutput(item))
+      }
+      for _, item := range path.Todos {
+            output.Todos = append(output.Todos, m.convertScheduledTodoToOutput(item))
+      }
+      return output
+}
+
+func (m *TodoModel) convertScheduledTodoToOutput(item ScheduledTodo) requests.ScheduledTodoOutput {
+      loc := m.location()
+      deadline := item.Todo.Deadline
+      return requests.ScheduledTodoOutput{
+            GetTodoOutput:  m.ConvertTodoToOutput(item.Todo),
+            EarliestStart:  item.EarliestStart.In(loc),
+            EarliestFinish: item.EarliestFinish.In(loc),
+            LatestFinish:   item.LatestFinish.In(loc),
+            SlackHours:     item.Slack.Hours(),
+            Critical:       item.Slack == 0,
+            Late:           !deadline.IsZero() && item.EarliestFinish.After(deadline),
+      }
+}
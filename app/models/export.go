@@ -0,0 +1,335 @@
+package models
+
+import (
+	"app/pkg/xlsx"
+	"app/requests"
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+      ExportFormatCSV      = "csv"
+      ExportFormatJSON     = "json"
+      ExportFormatMarkdown = "md"
+      ExportFormatXLSX     = "xlsx"
+)
+
+// exportBatchSize は一度に DB から読み込む Todo の件数です。
+const exportBatchSize = 500
+
+var (
+      // ErrInvalidExportFormat は対応していない形式が指定された場合に返されます。
+      ErrInvalidExportFormat = errors.New("format must be csv, json, md or xlsx")
+      // ErrExportSortUrgency は sort=urgency でエクスポートしようとした場合に返されます。
+      // 緊急度はすべての Todo を読み込まないと並び替えられないので、エクスポートでは使えません。
+      ErrExportSortUrgency = errors.New("sort=urgency is not supported for export, use priority or deadline")
+)
+
+// TodoExport は一覧と同じ条件で絞り込んだ Todo を、少しずつ読み込みながら書き出します。
+type TodoExport struct {
+      model  *TodoModel
+      db     *gorm.DB
+      sort   string
+      Format string
+}
+
+// exportRow はエクスポートする 1 件分の Todo です。CSV と JSON はインポートでそのまま取り込めます（import.go を参照）。
+type exportRow struct {
+      ID          uint     `json:"id"`
+      Title       string   `json:"title"`
+      Description string   `json:"description"`
+      Status      string   `json:"status"`
+      Done        bool     `json:"done"`
+      Priority    int      `json:"priority"`
+      Deadline    string   `json:"deadline"`
+      AllDay      bool     `json:"all_day"`
+      Tags        []string `json:"tags"`
+      Users       []string `json:"users"`
+      CreatedAt   string   `json:"created_at"`
+      UpdatedAt   string   `json:"updated_at"`
+}
+
+var exportColumns = []string{"id", "title", "description", "status", "done", "priority", "deadline", "all_day", "tags", "users", "created_at", "updated_at"}
+
+// NewTodoExport は query の条件で Todo を format の形式で書き出す TodoExport を返します。
+// 書き出しを始める前に条件を検証するので、誤りがあればここでエラーになります。
+func (m *TodoModel) NewTodoExport(format string, query requests.TodoListQuery) (*TodoExport, error) {
+      switch format {
+      case ExportFormatCSV, ExportFormatJSON, ExportFormatMarkdown, ExportFormatXLSX:
+      default:
+            return nil, ErrInvalidExportFormat
+      }
+      if query.Sort == TodoSortUrgency {
+            return nil, ErrExportSortUrgency
+      }
+      db, err := filterTodos(m.DB, query, m.location())
+      if err != nil {
+            return nil, err
+      }
+      // 並び順の指定が無い場合は ID 順にする（filterTodos の並び順は最後が ID なので、ここで追加しても変わらない）
+      return &TodoExport{model: m, db: db.Order("todos.id"), sort: query.Sort, Format: format}, nil
+}
+
+func (e *TodoExport) ContentType() string {
+      switch e.Format {
+      case ExportFormatCSV:
+            return "text/csv; charset=utf-8"
+      case ExportFormatJSON:
+            return "application/json; charset=utf-8"
+      case ExportFormatMarkdown:
+            return "text/markdown; charset=utf-8"
+      default:
+            return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+      }
+}
+
+func (e *TodoExport) FileName() string {
+      return "todos." + e.Format
+}
+
+// Write は Todo を exportBatchSize 件ずつ読み込みながら w に書き出します。
+// 途中でエラーになった場合、それまでに書き出した内容は取り消せません。
+// 書き出しの途中で Todo が追加・削除されても重複や抜けが出ないように、すべての読み込みを1つの REPEATABLE READ のトランザクションで行います。
+func (e *TodoExport) Write(w io.Writer) error {
+      return e.db.Transaction(func(tx *gorm.DB) error {
+            return e.write(tx, w)
+      }, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+func (e *TodoExport) write(db *gorm.DB, w io.Writer) error {
+      buf := bufio.NewWriter(w)
+      var writer exportWriter
+      switch e.Format {
+      case ExportFormatCSV:
+            writer = newCSVExportWriter(buf)
+      case ExportFormatJSON:
+            writer = &jsonExportWriter{w: buf}
+      case ExportFormatMarkdown:
+            writer = &markdownExportWriter{w: buf}
+      default:
+            sheet, err := xlsx.NewWriter(buf, "Todo")
+            if err != nil {
+                  return err
+            }
+            writer = &xlsxExportWriter{w: sheet}
+      }
+
+      if err := writer.WriteHeader(); err != nil {
+            return err
+      }
+      // OFFSET は読み飛ばす行も読むので、前の読み込みの最後の Todo より後ろを読む（キーセットページネーション）
+      var last *Todo
+      for {
+            batch := db.Session(&gorm.Session{})
+            if last != nil {
+                  query, args := exportKeyset(e.sort, *last)
+                  batch = batch.Where(query, args...)
+            }
+            var todos []Todo
+            if err := preloadTodo(batch).Limit(exportBatchSize).Find(&todos).Error; err != nil {
+                  return err
+            }
+            for _, todo := range todos {
+                  if err := writer.Write(e.model.convertTodoToExportRow(todo)); err != nil {
+                        return err
+                  }
+            }
+            if len(todos) < exportBatchSize {
+                  break
+            }
+            last = &todos[len(todos)-1]
+            if err := buf.Flush(); err != nil {
+                  return err
+            }
+      }
+      if err := writer.Close(); err != nil {
+            return err
+      }
+      return buf.Flush()
+}
+
+// exportKeyset は filterTodos の並び順で last より後ろの Todo に絞り込む条件を返します。
+func exportKeyset(sort string, last Todo) (string, []interface{}) {
+      switch sort {
+      case TodoSortPriority:
+            return "(todos.priority, todos.id) > (?, ?)", []interface{}{last.Priority, last.ID}
+      case TodoSortDeadline:
+            // 期限の無い Todo（0001-01-01）を後ろにする並び順に合わせる
+            noDeadline := last.Deadline.Before(time.Date(2, 1, 1, 0, 0, 0, 0, time.UTC))
+            return "(todos.deadline < '0002-01-01', todos.deadline, todos.id) > (?, ?, ?)", []interface{}{noDeadline, last.Deadline, last.ID}
+      default:
+            return "todos.id > ?", []interface{}{last.ID}
+      }
+}
+
+func (m *TodoModel) convertTodoToExportRow(todo Todo) exportRow {
+      row := exportRow{
+            ID:          todo.ID,
+            Title:       todo.Title,
+            Description: todo.Description,
+            Status:      todo.Status.Name,
+            Done:        todo.Status.IsDone,
+            Priority:    todo.Priority,
+            AllDay:      todo.DeadlineAllDay,
+            Tags:        []string{},
+            Users:       []string{},
+            CreatedAt:   todo.CreatedAt.In(m.location()).Format(time.RFC3339),
+            UpdatedAt:   todo.UpdatedAt.In(m.location()).Format(time.RFC3339),
+      }
+      // 期限はインポートで読み込める形にする。終日の期限は日付だけ
+      if !todo.Deadline.IsZero() {
+            deadline := localizeDeadline(todo.Deadline, todo.DeadlineAllDay, m.location())
+            if todo.DeadlineAllDay {
+                  row.Deadline = deadline.Format("2006-01-02")
+            } else {
+                  row.Deadline = deadline.Format(time.RFC3339)
+            }
+      }
+      for _, tag := range todo.Tags {
+            row.Tags = append(row.Tags, tag.Name)
+      }
+      for _, assignee := range todo.Assignees {
+            row.Users = append(row.Users, assignee.User.Email)
+      }
+      return row
+}
+
+// exportWriter は形式ごとの書き出しかたです。
+type exportWriter interface {
+      WriteHeader() error
+      Write(row exportRow) error
+      Close() error
+}
+
+type csvExportWriter struct {
+      w   io.Writer
+      csv *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) *csvExportWriter {
+      return &csvExportWriter{w: w, csv: csv.NewWriter(w)}
+}
+
+func (w *csvExportWriter) WriteHeader() error {
+      // Excel で開いたときに文字化けしないように BOM を付ける
+      if _, err := io.WriteString(w.w, "\ufeff"); err != nil {
+            return err
+      }
+      return w.csv.Write(exportColumns)
+}
+
+func (w *csvExportWriter) Write(row exportRow) error {
+      err := w.csv.Write([]string{
+            fmt.Sprint(row.ID), row.Title, row.Description, row.Status, fmt.Sprint(row.Done),
+            fmt.Sprintf("P%d", row.Priority), row.Deadline, fmt.Sprint(row.AllDay),
+            strings.Join(row.Tags, ", "), strings.Join(row.Users, ", "), row.CreatedAt, row.UpdatedAt,
+      })
+      if err != nil {
+            return err
+      }
+      w.csv.Flush()
+      return w.csv.Error()
+}
+
+func (w *csvExportWriter) Close() error {
+      w.csv.Flush()
+      return w.csv.Error()
+}
+
+// jsonExportWriter は配列の要素を 1 件ずつ書き出します。
+type jsonExportWriter struct {
+      w     io.Writer
+      count int
+}
+
+func (w *jsonExportWriter) WriteHeader() error {
+      _, err := io.WriteString(w.w, "[")
+      return err
+}
+
+func (w *jsonExportWriter) Write(row exportRow) error {
+      if w.count > 0 {
+            if _, err := io.WriteString(w.w, ","); err != nil {
+                  return err
+            }
+      }
+      w.count++
+      body, err := json.Marshal(row)
+      if err != nil {
+            return err
+      }
+      _, err = w.w.Write(append([]byte("\n"), body...))
+      return err
+}
+
+func (w *jsonExportWriter) Close() error {
+      _, err := io.WriteString(w.w, "\n]\n")
+      return err
+}
+
+// markdownExportWriter は完了したものにチェックを付けた表にします。報告書などに貼り付ける用途です。
+type markdownExportWriter struct {
+      w io.Writer
+}
+
+func (w *markdownExportWriter) WriteHeader() error {
+      _, err := io.WriteString(w.w, "| | タイトル | ステータス | 優先度 | 期限 | タグ | 担当者 |\n|---|---|---|---|---|---|---|\n")
+      return err
+}
+
+func (w *markdownExportWriter) Write(row exportRow) error {
+      check := "[ ]"
+      if row.Done {
+            check = "[x]"
+      }
+      deadline := row.Deadline
+      if t, err := time.Parse(time.RFC3339, deadline); err == nil {
+            deadline = t.Format("2006-01-02 15:04")
+      }
+      _, err := fmt.Fprintf(w.w, "| %s | %s | %s | P%d | %s | %s | %s |\n",
+            check, escapeMarkdownCell(row.Title), escapeMarkdownCell(row.Status), row.Priority, deadline,
+            escapeMarkdownCell(strings.Join(row.Tags, ", ")), escapeMarkdownCell(strings.Join(row.Users, ", ")))
+      return err
+}
+
+func (w *markdownExportWriter) Close() error {
+      return nil
+}
+
+// escapeMarkdownCell は表のセルを壊さないように | と改行をエスケープします。
+func escapeMarkdownCell(value string) string {
+      value = strings.ReplaceAll(value, "|", `\|`)
+      value = strings.ReplaceAll(value, "\r\n", "<br>")
+      return strings.ReplaceAll(value, "\n", "<br>")
+}
+
+type xlsxExportWriter struct {
+      w *xlsx.Writer
+}
+
+func (w *xlsxExportWriter) WriteHeader() error {
+      header := make([]interface{}, len(exportColumns))
+      for i, column := range exportColumns {
+            header[i] = column
+      }
+      return w.w.WriteRow(header...)
+}
+
+func (w *xlsxExportWriter) Write(row exportRow) error {
+      return w.w.WriteRow(row.ID, row.Title, row.Description, row.Status, row.Done,
+            fmt.Sprintf("P%d", row.Priority), row.Deadline, row.AllDay,
+            strings.Join(row.Tags, ", "), strings.Join(row.Users, ", "), row.CreatedAt, row.UpdatedAt)
+}
+
+func (w *xlsxExportWriter) Close() error {
+      return w.w.Close()
+}
@@ -0,0 +1,381 @@
+package models
+
+import (
+	"app/requests"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // TodoEventCreated などは、Todo の履歴の操作の種類です。
+      TodoEventCreated             = "created"
+      TodoEventUpdated             = "updated"
+      TodoEventStatusChanged       = "status_changed"
+      TodoEventMoved               = "moved"
+      TodoEventParentChanged       = "parent_changed"
+      TodoEventTagsChanged         = "tags_changed"
+      TodoEventAssigneesChanged    = "assignees_changed"
+      TodoEventDependenciesChanged = "dependencies_changed"
+      TodoEventDeleted             = "deleted"
+      TodoEventRestored            = "restored"
+      TodoEventPurged              = "purged"
+      TodoEventUndone              = "undone"
+)
+
+// TodoEvent は Todo に対して行われた操作の記録です。変更と同じトランザクションで書き込み、後から変更しません。
+// サブタスクの連鎖削除のように 1 回の操作で複数の Todo が変わった場合は、Todo ごとに 1 件ずつ記録します。
+type TodoEvent struct {
+      ID          uint `gorm:"primary_key" json:"id"`
+      TodoID      uint `gorm:"not null;index" json:"todo_id"`
+      WorkspaceID uint `gorm:"not null;index" json:"workspace_id"`
+      // 操作したユーザー。繰り返しの自動作成のように、ユーザーの操作でない場合は nil です。
+      ActorID *uint `json:"actor_id"`
+      Actor   *User `json:"actor"`
+      // 同じリクエストで記録した履歴に共通の ID。取り消し（undo.go を参照）の単位です。
+      OperationID *string `gorm:"size:32;index" json:"operation_id"`
+      Action      string  `gorm:"not null" json:"action"`
+      // 項目ごとの変更前と変更後の値。キーは todoSnapshot の JSON の名前です。
+      Changes   TodoChanges `gorm:"type:jsonb;serializer:json;not null" json:"changes"`
+      CreatedAt time.Time   `gorm:"index" json:"created_at"`
+}
+
+// TodoChanges は項目名ごとの変更です。
+type TodoChanges map[string]TodoFieldChange
+
+// TodoFieldChange は 1 つの項目の変更前と変更後の値です。作成時の変更前の値は、その項目のゼロ値です。
+type TodoFieldChange struct {
+      Before interface{} `json:"before"`
+      After  interface{} `json:"after"`
+}
+
+// todoSnapshot は履歴に残す Todo の項目です。ここに項目を追加すると、その項目の変更も記録されます。
+// 並び順（rank）や version のように、ユーザーにとって意味の無い項目は含めません。
+type todoSnapshot struct {
+      WorkspaceID     uint               `json:"-"`
+      Title           string             `json:"title"`
+      Description     string             `json:"description"`
+      Deadline        time.Time          `json:"deadline"`
+      DeadlineAllDay  bool               `json:"deadline_all_day"`
+      StatusID        uint               `json:"status_id"`
+      Priority        int                `json:"priority"`
+      EstimateMinutes *int               `json:"estimate_minutes"`
+      ParentID        *uint              `json:"parent_id"`
+      ProjectID       *uint              `json:"project_id"`
+      TagIDs          []uint             `json:"tag_ids"`
+      Assignees       []assigneeSnapshot `json:"assignees"`
+      DependsOnIDs    []uint             `json:"depends_on_ids"`
+      Deleted         bool               `json:"deleted"`
+}
+
+type assigneeSnapshot struct {
+      UserID uint   `json:"user_id"`
+      Role   string `json:"role"`
+}
+
+type actorContextKey struct{}
+
+// todoAudit は変更前の Todo の状態を覚えておき、record で変更後との差分を履歴に書き込みます。
+//
+//	audit, err := auditTodos(tx, TodoEventUpdated, id)
+//	（Todo を変更する）
+//	return audit.record(tx)
+type todoAudit struct {
+      action string
+      ids    []uint
+      before map[uint]todoSnapshot
+}
+
+// GetTodoHistory は Todo の履歴を新しい順に返します。ゴミ箱に入っている Todo や、完全に削除した Todo の履歴も見られます。
+func (m *TodoModel) GetTodoHistory(todoID uint, query requests.PageQuery) ([]TodoEvent, requests.PageOutput, error) {
+      events := m.DB.Model(&TodoEvent{}).Where("todo_id = ? AND workspace_id = ?", todoID, m.WorkspaceID)
+      if _, err := findScopedTodo(m.DB.Unscoped(), todoID); err != nil {
+            if !errors.Is(err, gorm.ErrRecordNotFound) {
+                  return nil, requests.PageOutput{}, err
+            }
+            // 完全に削除した Todo は、このワークスペースの履歴が残っている場合だけ見られる
+            var purged int64
+            if err := events.Session(&gorm.Session{}).Where("action = ?", TodoEventPurged).Count(&purged).Error; err != nil {
+                  return nil, requests.PageOutput{}, err
+            }
+            if purged == 0 {
+                  return nil, requests.PageOutput{}, err
+            }
+      }
+      page := normalizePage(query)
+
+      if err := events.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+            return nil, requests.PageOutput{}, err
+      }
+      page.TotalPages = int((page.Total + int64(page.PerPage) - 1) / int64(page.PerPage))
+
+      var result []TodoEvent
+      err := events.Preload("Actor").
+            Order("created_at DESC, id DESC").
+            Offset((page.Page - 1) * page.PerPage).Limit(page.PerPage).
+            Find(&result).Error
+      if err != nil {
+            return nil, requests.PageOutput{}, err
+      }
+      return result, page, nil
+}
+
+// recordPurged は完全に削除した Todo について、削除前のすべての項目を変更前の値として残した履歴を書き込みます。
+// 完全に削除した後も、いつ誰が削除したか、削除前にどうなっていたかを履歴で確認できます。
+func (a *todoAudit) recordPurged(tx *gorm.DB) error {
+      events := []TodoEvent{}
+      for _, id := range a.ids {
+            before, ok := a.before[id]
+            if !ok {
+                  continue
+            }
+            values, err := snapshotValues(before)
+            if err != nil {
+                  return err
+            }
+            changes := TodoChanges{}
+            for field, value := range values {
+                  changes[field] = TodoFieldChange{Before: value, After: nil}
+            }
+            events = append(events, TodoEvent{
+                  TodoID:      id,
+                  WorkspaceID: before.WorkspaceID,
+                  Action:      a.action,
+                  Changes:     changes,
+            })
+      }
+      return writeTodoEvents(tx, events)
+}
+
+// withActor は db で行う変更を、userID のユーザーの操作として履歴に記録するようにします。
+func withActor(db *gorm.DB, userID uint) *gorm.DB {
+      return db.WithContext(context.WithValue(db.Statement.Context, actorContextKey{}, userID))
+}
+
+func actorFromContext(db *gorm.DB) *uint {
+      id, ok := db.Statement.Context.Value(actorContextKey{}).(uint)
+      if !ok || id == 0 {
+            return nil
+      }
+      return &id
+}
+
+// auditTodos は ids の Todo の変更前の状態を読み込みます。存在しない ID は記録しません。
+func auditTodos(tx *gorm.DB, action string, ids ...uint) (*todoAudit, error) {
+      ids = uniqueIDs(ids)
+      before, err := snapshotTodos(tx, ids)
+      if err != nil {
+            return nil, err
+      }
+      return &todoAudit{action: action, ids: ids, before: before}, nil
+}
+
+// record は変更後の状態を読み込み、変更があった Todo の履歴を書き込みます。
+func (a *todoAudit) record(tx *gorm.DB) error {
+      after, err := snapshotTodos(tx, a.ids)
+      if err != nil {
+            return err
+      }
+      events := []TodoEvent{}
+      for _, id := range a.ids {
+            before, ok := a.before[id]
+            if !ok {
+                  continue
+            }
+            current, ok := after[id]
+            if !ok {
+                  continue
+            }
+            changes, err := diffSnapshots(before, current)
+            if err != nil {
+                  return err
+            }
+            if len(changes) == 0 {
+                  continue
+            }
+            events = append(events, TodoEvent{
+                  TodoID:      id,
+                  WorkspaceID: current.WorkspaceID,
+                  Action:      a.action,
+                  Changes:     changes,
+            })
+      }
+      return writeTodoEvents(tx, events)
+}
+
+// recordTodoCreated は作成した Todo の履歴を書き込みます。作成直後の状態は DB から読み直さずに todo から作ります。
+func recordTodoCreated(tx *gorm.DB, todo *Todo, assignees []TodoAssignee) error {
+      tagIDs := make([]uint, 0, len(todo.Tags))
+      for _, tag := range todo.Tags {
+            tagIDs = append(tagIDs, tag.ID)
+      }
+      empty := newTodoSnapshot(Todo{}, nil, nil, nil)
+      changes, err := diffSnapshots(empty, newTodoSnapshot(*todo, tagIDs, assignees, nil))
+      if err != nil {
+            return err
+      }
+      return writeTodoEvents(tx, []TodoEvent{{
+            TodoID:      todo.ID,
+            WorkspaceID: todo.WorkspaceID,
+            Action:      TodoEventCreated,
+            Changes:     changes,
+      }})
+}
+
+// writeTodoEvents は操作したユーザーと操作の ID を付けて履歴を書き込みます。
+// 操作の ID がある場合は、取り消せるよう操作（TodoOperation）も記録します。
+func writeTodoEvents(tx *gorm.DB, events []TodoEvent) error {
+      if len(events) == 0 {
+            return nil
+      }
+      actorID := actorFromContext(tx)
+      operationID := operationFromContext(tx)
+      for i := range events {
+            events[i].ActorID = actorID
+            events[i].OperationID = operationID
+      }
+      if operationID != nil {
+            operation := TodoOperation{ID: *operationID, WorkspaceID: events[0].WorkspaceID, ActorID: actorID}
+            if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&operation).Error; err != nil {
+                  return err
+            }
+      }
+      return tx.Omit("Actor").Create(&events).Error
+}
+
+// snapshotTodos は ids の Todo（ゴミ箱に入っているものを含む）の、履歴に残す項目を読み込みます。
+func snapshotTodos(tx *gorm.DB, ids []uint) (map[uint]todoSnapshot, error) {
+      snapshots := map[uint]todoSnapshot{}
+      if len(ids) == 0 {
+            return snapshots, nil
+      }
+      var todos []Todo
+      if err := tx.Unscoped().Where("id IN ?", ids).Find(&todos).Error; err != nil {
+            return nil, err
+      }
+      var tags []struct {
+            TodoID uint
+            TagID  uint
+      }
+      if err := tx.Table("todo_tags").Select("todo_id", "tag_id").Where("todo_id IN ?", ids).Find(&tags).Error; err != nil {
+            return nil, err
+      }
+      var assignees []TodoAssignee
+      if err := tx.Where("todo_id IN ?", ids).Find(&assignees).Error; err != nil {
+            return nil, err
+      }
+
+      var dependencies []TodoDependency
+      if err := tx.Where("todo_id IN ?", ids).Find(&dependencies).Error; err != nil {
+            return nil, err
+      }
+
+      tagIDs := map[uint][]uint{}
+      for _, tag := range tags {
+            tagIDs[tag.TodoID] = append(tagIDs[tag.TodoID], tag.TagID)
+      }
+      todoAssignees := map[uint][]TodoAssignee{}
+      for _, assignee := range assignees {
+            todoAssignees[assignee.TodoID] = append(todoAssignees[assignee.TodoID], assignee)
+      }
+      dependsOnIDs := map[uint][]uint{}
+      for _, dependency := range dependencies {
+            dependsOnIDs[dependency.TodoID] = append(dependsOnIDs[dependency.TodoID], dependency.DependsOnID)
+      }
+      for _, todo := range todos {
+            snapshots[todo.ID] = newTodoSnapshot(todo, tagIDs[todo.ID], todoAssignees[todo.ID], dependsOnIDs[todo.ID])
+      }
+      return snapshots, nil
+}
+
+// newTodoSnapshot は比較できるよう、タグと担当者と先行タスクを ID 順に並べ、期限を UTC にそろえます。
+func newTodoSnapshot(todo Todo, tagIDs []uint, assignees []TodoAssignee, dependsOnIDs []uint) todoSnapshot {
+      snapshot := todoSnapshot{
+            WorkspaceID: todo.WorkspaceID,
+            Title:       todo.Title,
+            Description: todo.Description,
+            // DB にはマイクロ秒までしか保存されないので、作成時の状態もそろえる
+            Deadline:        todo.Deadline.UTC().Truncate(time.Microsecond),
+            DeadlineAllDay:  todo.DeadlineAllDay,
+            StatusID:        todo.StatusID,
+            Priority:        todo.Priority,
+            EstimateMinutes: todo.EstimateMinutes,
+            ParentID:        todo.ParentID,
+            ProjectID:       todo.ProjectID,
+            TagIDs:          append([]uint{}, tagIDs...),
+            Assignees:       []assigneeSnapshot{},
+            DependsOnIDs:    append([]uint{}, dependsOnIDs...),
+            Deleted:         todo.DeletedAt.Valid,
+      }
+      sort.Slice(snapshot.TagIDs, func(i, j int) bool { return snapshot.TagIDs[i] < snapshot.TagIDs[j] })
+      sort.Slice(snapshot.DependsOnIDs, func(i, j int) bool { return snapshot.DependsOnIDs[i] < snapshot.DependsOnIDs[j] })
+      for _, assignee := range assignees {
+            snapshot.Assignees = append(snapshot.Assignees, assigneeSnapshot{UserID: assignee.UserID, Role: assignee.Role})
+      }
+      sort.Slice(snapshot.Assignees, func(i, j int) bool { return snapshot.Assignees[i].UserID < snapshot.Assignees[j].UserID })
+      return snapshot
+}
+
+// diffSnapshots は値が変わった項目だけを返します。値は JSON にしたときの形（数値は float64 など）で比較します。
+func diffSnapshots(before todoSnapshot, after todoSnapshot) (TodoChanges, error) {
+      beforeValues, err := snapshotValues(before)
+      if err != nil {
+            return nil, err
+      }
+      afterValues, err := snapshotValues(after)
+      if err != nil {
+            return nil, err
+      }
+      changes := TodoChanges{}
+      for field, value := range afterValues {
+            if !reflect.DeepEqual(beforeValues[field], value) {
+                  changes[field] = TodoFieldChange{Before: beforeValues[field], After: value}
+            }
+      }
+      return changes, nil
+}
+
+func snapshotValues(snapshot todoSnapshot) (map[string]interface{}, error) {
+      data, err := json.Marshal(snapshot)
+      if err != nil {
+            return nil, err
+      }
+      values := map[string]interface{}{}
+      if err := json.Unmarshal(data, &values); err != nil {
+            return nil, err
+      }
+      return values, nil
+}
+
+func (m *TodoModel) ConvertTodoEventToOutput(event TodoEvent) requests.TodoEventOutput {
+      output := requests.TodoEventOutput{
+            ID:          event.ID,
+            TodoID:      event.TodoID,
+            OperationID: event.OperationID,
+            Action:      event.Action,
+            Changes:     map[string]requests.FieldChangeOutput{},
+            CreatedAt:   event.CreatedAt,
+      }
+      if event.Actor != nil {
+            actor := convertUserToOutput(*event.Actor)
+            output.Actor = &actor
+      }
+      for field, change := range event.Changes {
+            output.Changes[field] = requests.FieldChangeOutput{Before: change.Before, After: change.After}
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertTodoEventsToOutput(events []TodoEvent) []requests.TodoEventOutput {
+      output := []requests.TodoEventOutput{}
+      for _, event := range events {
+            output = append(output, m.ConvertTodoEventToOutput(event))
+      }
+      return output
+}
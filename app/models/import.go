@@ -0,0 +1,623 @@
+package models
+
+import (
+	"app/pkg/ical"
+	"app/pkg/utils"
+	"app/requests"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+      // ImportFormatCSV は 1 行目を見出しとする CSV です。列は mapping で Todo の項目に対応付けます。
+      ImportFormatCSV = "csv"
+      // ImportFormatJSON は Todo のオブジェクトの配列です。
+      ImportFormatJSON = "json"
+      // ImportFormatICS は iCalendar の VTODO です。
+      ImportFormatICS = "ics"
+)
+
+const (
+      ImportJobPending   = "pending"
+      ImportJobRunning   = "running"
+      ImportJobSucceeded = "succeeded"
+      ImportJobFailed    = "failed"
+)
+
+// importFields は取り込める Todo の項目です。CSV の mapping のキーに使います。
+var importFields = []string{"title", "description", "deadline", "all_day", "priority", "status", "tags"}
+
+var (
+      // ErrInvalidImportFormat は対応していない形式が指定された場合に返されます。
+      ErrInvalidImportFormat = errors.New("format must be csv, json or ics")
+      // ErrInvalidImportFile はファイルを読み込めない場合に返されます。
+      ErrInvalidImportFile = errors.New("invalid import file")
+      // ErrInvalidImportMapping は CSV の mapping に存在しない項目や列が指定された場合に返されます。
+      ErrInvalidImportMapping = errors.New("invalid column mapping")
+      // ErrTooManyImportRows は 1 回に取り込める件数を超えた場合に返されます。
+      ErrTooManyImportRows = errors.New("too many rows to import")
+      // ErrImportRowsInvalid は検証で誤りが見つかった行がある場合に返されます。1 件も取り込みません。
+      ErrImportRowsInvalid = errors.New("some rows are invalid")
+)
+
+// ImportRow はファイルから読み込んだ 1 件分の Todo です。値の検証は ValidateImportRows で行います。
+type ImportRow struct {
+      // ファイル上の行番号（CSV）または何件目か（JSON、ICS）。エラーの報告に使います。
+      Row         int
+      Title       string
+      Description string
+      // RFC 3339、"2006-01-02"（終日）、"2006-01-02 15:04"、または「明日の15時」のような自然言語
+      Deadline string
+      AllDay   string
+      // 0〜4 または P0〜P4
+      Priority string
+      // ステータスの名前。省略した場合はデフォルトのステータスになります。
+      Status string
+      // ICS の STATUS:COMPLETED のように、完了済みとして取り込むかどうか。Status が優先です。
+      Done bool
+      Tags []string
+}
+
+// ImportRowError は検証で見つかった誤りです。
+type ImportRowError struct {
+      Row     int
+      Field   string
+      Message string
+}
+
+// ImportResult は取り込みの結果です。バックグラウンドで取り込む場合は Job が設定されます。
+type ImportResult struct {
+      Total  int
+      DryRun bool
+      Errors []ImportRowError
+      Todos  []Todo
+      Job    *ImportJob
+}
+
+// ImportJob はバックグラウンドで行う取り込みの進捗です。
+// 取り込みは 1 つのトランザクションで行うので、失敗した場合は 1 件も取り込まれません。
+type ImportJob struct {
+      ID         uint       `gorm:"primary_key" json:"id"`
+      UserID     uint       `gorm:"not null;index" json:"user_id"`
+      Format     string     `gorm:"not null" json:"format"`
+      Status     string     `gorm:"not null" json:"status"`
+      Total      int        `gorm:"not null" json:"total"`
+      Processed  int        `gorm:"not null;default:0" json:"processed"`
+      Error      string     `gorm:"not null;default:''" json:"error"`
+      CreatedAt  time.Time  `json:"created_at"`
+      UpdatedAt  time.Time  `json:"updated_at"`
+      FinishedAt *time.Time `json:"finished_at"`
+}
+
+// importTodo は検証を通った行です。
+type importTodo struct {
+      row  ImportRow
+      todo Todo
+}
+
+// importProgressInterval は何件ごとに ImportJob の進捗を記録するかです。
+const importProgressInterval = 50
+
+// ImportMaxRows は 1 回に取り込める件数です。環境変数 IMPORT_MAX_ROWS で変更できます。
+func ImportMaxRows() int {
+      return utils.GetEnvInt("IMPORT_MAX_ROWS", 10000)
+}
+
+// ImportAsyncRows はこの件数を超える取り込みをバックグラウンドで行います。環境変数 IMPORT_ASYNC_ROWS で変更できます。
+func ImportAsyncRows() int {
+      return utils.GetEnvInt("IMPORT_ASYNC_ROWS", 200)
+}
+
+// ParseImportFile は format の形式のファイルを読み込みます。
+// mapping は CSV の場合のみ使い、Todo の項目（title など）から CSV の見出しへの対応です。省略した項目は同じ名前の見出しを使います。
+func ParseImportFile(format string, r io.Reader, mapping map[string]string) ([]ImportRow, error) {
+      var rows []ImportRow
+      var err error
+      switch format {
+      case ImportFormatCSV:
+            rows, err = parseImportCSV(r, mapping)
+      case ImportFormatJSON:
+            rows, err = parseImportJSON(r)
+      case ImportFormatICS:
+            rows, err = parseImportICS(r)
+      default:
+            return nil, ErrInvalidImportFormat
+      }
+      if err != nil {
+            return nil, err
+      }
+      if len(rows) > ImportMaxRows() {
+            return nil, fmt.Errorf("%w: at most %d rows", ErrTooManyImportRows, ImportMaxRows())
+      }
+      return rows, nil
+}
+
+func parseImportCSV(r io.Reader, mapping map[string]string) ([]ImportRow, error) {
+      reader := csv.NewReader(r)
+      reader.FieldsPerRecord = -1
+      header, err := reader.Read()
+      if err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+      }
+      if len(header) > 0 {
+            // Excel が付ける BOM を取り除く
+            header[0] = strings.TrimPrefix(header[0], "\ufeff")
+      }
+
+      for field := range mapping {
+            if !contains(importFields, field) {
+                  return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidImportMapping, field)
+            }
+      }
+      columns := map[string]int{}
+      for _, field := range importFields {
+            name, ok := mapping[field]
+            if !ok {
+                  name = field
+            }
+            index := -1
+            for i, column := range header {
+                  if strings.EqualFold(strings.TrimSpace(column), name) {
+                        index = i
+                        break
+                  }
+            }
+            if index < 0 {
+                  if ok {
+                        return nil, fmt.Errorf("%w: column %q not found", ErrInvalidImportMapping, name)
+                  }
+                  continue
+            }
+            columns[field] = index
+      }
+      if _, ok := columns["title"]; !ok {
+            return nil, fmt.Errorf("%w: title column is required", ErrInvalidImportMapping)
+      }
+
+      var rows []ImportRow
+      for {
+            record, err := reader.Read()
+            if err == io.EOF {
+                  break
+            }
+            if err != nil {
+                  return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+            }
+            line, _ := reader.FieldPos(0)
+            value := func(field string) string {
+                  index, ok := columns[field]
+                  if !ok || index >= len(record) {
+                        return ""
+                  }
+                  return strings.TrimSpace(record[index])
+            }
+            rows = append(rows, ImportRow{
+                  Row:         line,
+                  Title:       value("title"),
+                  Description: value("description"),
+                  Deadline:    value("deadline"),
+                  AllDay:      value("all_day"),
+                  Priority:    value("priority"),
+                  Status:      value("status"),
+                  Tags:        splitTagNames(value("tags")),
+            })
+      }
+      return rows, nil
+}
+
+// importJSONRow は JSON で取り込む Todo です。tags はタグの名前の配列です。
+type importJSONRow struct {
+      Title       string   `json:"title"`
+      Description string   `json:"description"`
+      Deadline    string   `json:"deadline"`
+      AllDay      bool     `json:"all_day"`
+      Priority    *int     `json:"priority"`
+      Status      string   `json:"status"`
+      Tags        []string `json:"tags"`
+}
+
+func parseImportJSON(r io.Reader) ([]ImportRow, error) {
+      var records []importJSONRow
+      if err := json.NewDecoder(r).Decode(&records); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+      }
+      rows := make([]ImportRow, len(records))
+      for i, record := range records {
+            rows[i] = ImportRow{
+                  Row:         i + 1,
+                  Title:       record.Title,
+                  Description: record.Description,
+                  Deadline:    record.Deadline,
+                  Status:      record.Status,
+                  Tags:        record.Tags,
+            }
+            if record.AllDay {
+                  rows[i].AllDay = "true"
+            }
+            if record.Priority != nil {
+                  rows[i].Priority = strconv.Itoa(*record.Priority)
+            }
+      }
+      return rows, nil
+}
+
+func parseImportICS(r io.Reader) ([]ImportRow, error) {
+      calendar, err := ical.Parse(r)
+      if err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+      }
+      var rows []ImportRow
+      for _, component := range calendar.Components {
+            if component.Name != "VTODO" {
+                  continue
+            }
+            row := ImportRow{Row: len(rows) + 1}
+            for _, property := range component.Properties {
+                  switch property.Name {
+                  case "SUMMARY":
+                        row.Title = ical.UnescapeText(property.Value)
+                  case "DESCRIPTION":
+                        row.Description = ical.UnescapeText(property.Value)
+                  case "DUE":
+                        row.Deadline, row.AllDay = importICalDue(property)
+                  case "PRIORITY":
+                        // iCalendar の 1（高い）〜 9（低い）を P0〜P4 にする。0 は未定義
+                        if p, err := strconv.Atoi(property.Value); err == nil && p >= 1 && p <= 9 {
+                              row.Priority = strconv.Itoa((p - 1) / 2)
+                        }
+                  case "STATUS":
+                        row.Done = strings.EqualFold(property.Value, "COMPLETED")
+                  case "CATEGORIES":
+                        for _, name := range splitICalList(property.Value) {
+                              row.Tags = append(row.Tags, ical.UnescapeText(name))
+                        }
+                  }
+            }
+            rows = append(rows, row)
+      }
+      return rows, nil
+}
+
+// importICalDue は DUE を ImportRow の Deadline と AllDay にします。TZID 付きの日時はそのタイムゾーンで解釈します。
+func importICalDue(property ical.Property) (string, string) {
+      if strings.EqualFold(property.Param("VALUE"), "DATE") {
+            if t, err := time.Parse("20060102", property.Value); err == nil {
+                  return t.Format("2006-01-02"), "true"
+            }
+            return property.Value, "true"
+      }
+      t, err := utils.ParseICalTime(property.Value)
+      if err != nil {
+            return property.Value, ""
+      }
+      if tzid := property.Param("TZID"); tzid != "" && !strings.HasSuffix(property.Value, "Z") {
+            if loc, err := LoadTimeZone(tzid); err == nil {
+                  t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+            }
+      }
+      return t.Format(time.RFC3339), ""
+}
+
+// splitICalList はエスケープされていないカンマで区切ります。
+func splitICalList(value string) []string {
+      var items []string
+      start := 0
+      for i := 0; i < len(value); i++ {
+            switch value[i] {
+            case '\\':
+                  i++
+            case ',':
+                  items = append(items, value[start:i])
+                  start = i + 1
+            }
+      }
+      return append(items, value[start:])
+}
+
+// splitTagNames は "仕事, 至急" のようなカンマ区切りのタグの名前を分割します。
+func splitTagNames(value string) []string {
+      var names []string
+      for _, name := range strings.Split(value, ",") {
+            if name = strings.TrimSpace(name); name != "" {
+                  names = append(names, name)
+            }
+      }
+      return names
+}
+
+func contains(values []string, value string) bool {
+      for _, v := range values {
+            if v == value {
+                  return true
+            }
+      }
+      return false
+}
+
+// ValidateImportRows はすべての行を検証し、見つかった誤りを返します。DB には何も書き込みません。
+func (m *TodoModel) ValidateImportRows(rows []ImportRow) ([]ImportRowError, error) {
+      _, rowErrors, err := m.prepareImportRows(rows)
+      return rowErrors, err
+}
+
+// prepareImportRows は行を検証して作成する Todo にします。ステータスは名前で探します。
+func (m *TodoModel) prepareImportRows(rows []ImportRow) ([]importTodo, []ImportRowError, error) {
+      var statuses []Status
+      if err := m.DB.Order("position, id").Find(&statuses).Error; err != nil {
+            return nil, nil, err
+      }
+      var defaultStatusID, doneStatusID uint
+      for _, status := range statuses {
+            if status.IsDefault && defaultStatusID == 0 {
+                  defaultStatusID = status.ID
+            }
+            if status.IsDone && doneStatusID == 0 {
+                  doneStatusID = status.ID
+            }
+      }
+
+      todos := make([]importTodo, 0, len(rows))
+      rowErrors := []ImportRowError{}
+      for _, row := range rows {
+            fail := func(field string, message string) {
+                  rowErrors = append(rowErrors, ImportRowError{Row: row.Row, Field: field, Message: message})
+            }
+            todo := Todo{
+                  Title:       strings.TrimSpace(row.Title),
+                  Description: row.Description,
+                  Priority:    PriorityDefault,
+                  StatusID:    defaultStatusID,
+                  Version:     1,
+            }
+            if todo.Title == "" {
+                  fail("title", "title is required")
+            }
+
+            allDay := false
+            if row.AllDay != "" {
+                  value, err := strconv.ParseBool(row.AllDay)
+                  if err != nil {
+                        fail("all_day", "all_day must be true or false")
+                  }
+                  allDay = value
+            }
+            if row.Deadline != "" {
+                  deadline, isAllDay, err := m.parseImportDeadline(row.Deadline)
+                  if err != nil {
+                        fail("deadline", err.Error())
+                  }
+                  todo.Deadline = normalizeDeadline(deadline, allDay || isAllDay, m.location())
+                  todo.DeadlineAllDay = allDay || isAllDay
+            }
+
+            if row.Priority != "" {
+                  priority, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(row.Priority), "P"))
+                  if err == nil {
+                        err = validatePriority(priority)
+                  }
+                  if err != nil {
+                        fail("priority", ErrInvalidPriority.Error())
+                  }
+                  todo.Priority = priority
+            }
+
+            switch {
+            case row.Status != "":
+                  found := false
+                  for _, status := range statuses {
+                        if strings.EqualFold(status.Name, strings.TrimSpace(row.Status)) {
+                              todo.StatusID = status.ID
+                              found = true
+                              break
+                        }
+                  }
+                  if !found {
+                        fail("status", fmt.Sprintf("status %q does not exist", row.Status))
+                  }
+            case row.Done && doneStatusID != 0:
+                  todo.StatusID = doneStatusID
+            }
+
+            for _, name := range row.Tags {
+                  if strings.TrimSpace(name) == "" {
+                        fail("tags", "tag name must not be empty")
+                        break
+                  }
+            }
+            todos = append(todos, importTodo{row: row, todo: todo})
+      }
+      return todos, rowErrors, nil
+}
+
+// parseImportDeadline は取り込む期限を m のタイムゾーンで解釈します。日付だけの場合は終日の期限になります。
+func (m *TodoModel) parseImportDeadline(value string) (time.Time, bool, error) {
+      value = strings.TrimSpace(value)
+      if t, err := time.Parse(time.RFC3339, value); err == nil {
+            return t, false, nil
+      }
+      for _, layout := range []string{"2006-01-02", "2006/01/02"} {
+            if t, err := time.ParseInLocation(layout, value, m.location()); err == nil {
+                  return t, true, nil
+            }
+      }
+      for _, layout := range []string{"2006-01-02 15:04", "2006/01/02 15:04", "2006-01-02T15:04:05"} {
+            if t, err := time.ParseInLocation(layout, value, m.location()); err == nil {
+                  return t, false, nil
+            }
+      }
+      interpreted, err := m.interpretDeadline(value)
+      if err != nil {
+            return time.Time{}, false, err
+      }
+      return interpreted.Deadline, interpreted.AllDay, nil
+}
+
+// ImportTodos は rows を検証し、誤りが無ければ userID のユーザーが担当する Todo として取り込みます。
+// dryRun の場合は検証だけを行います。誤りがある場合は ErrImportRowsInvalid を返し、1 件も取り込みません。
+// 件数が ImportAsyncRows を超える場合は ImportJob を作成してバックグラウンドで取り込み、すぐに返します。
+func (m *TodoModel) ImportTodos(userID uint, format string, rows []ImportRow, dryRun bool) (ImportResult, error) {
+      result := ImportResult{Total: len(rows), DryRun: dryRun}
+      todos, rowErrors, err := m.prepareImportRows(rows)
+      if err != nil {
+            return ImportResult{}, err
+      }
+      result.Errors = rowErrors
+      if dryRun {
+            return result, nil
+      }
+      if len(rowErrors) > 0 {
+            return result, ErrImportRowsInvalid
+      }
+
+      var user User
+      if err := m.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+            return ImportResult{}, err
+      }
+
+      if len(todos) > ImportAsyncRows() {
+            job := ImportJob{UserID: userID, Format: format, Status: ImportJobPending, Total: len(todos)}
+            if err := m.DB.Create(&job).Error; err != nil {
+                  return ImportResult{}, err
+            }
+            go m.runImportJob(job.ID, &user, todos)
+            result.Job = &job
+            return result, nil
+      }
+
+      err = m.DB.Transaction(func(tx *gorm.DB) error {
+            return createImportedTodos(tx, &user, todos, nil)
+      })
+      if err != nil {
+            return ImportResult{}, err
+      }
+      for _, todo := range todos {
+            result.Todos = append(result.Todos, todo.todo)
+      }
+      return result, nil
+}
+
+// runImportJob はバックグラウンドで取り込み、進捗を ImportJob に記録します。
+// 進捗はトランザクションの外で更新するので、取り込み中でも GetImportJob で確認できます。
+func (m *TodoModel) runImportJob(jobID uint, user *User, todos []importTodo) {
+      m.DB.Model(&ImportJob{}).Where("id = ?", jobID).Update("status", ImportJobRunning)
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            return createImportedTodos(tx, user, todos, func(processed int) {
+                  m.DB.Model(&ImportJob{}).Where("id = ?", jobID).Update("processed", processed)
+            })
+      })
+
+      now := time.Now()
+      updates := map[string]interface{}{"finished_at": now, "status": ImportJobSucceeded, "processed": len(todos)}
+      if err != nil {
+            log.Printf("import job %d failed: %v", jobID, err)
+            updates["status"] = ImportJobFailed
+            updates["processed"] = 0
+            updates["error"] = err.Error()
+      }
+      if err := m.DB.Model(&ImportJob{}).Where("id = ?", jobID).Updates(updates).Error; err != nil {
+            log.Printf("import job %d: failed to record the result: %v", jobID, err)
+      }
+}
+
+// createImportedTodos は検証を通った Todo を作成します。タグは名前で探し、無ければ作成します。
+// progress が nil でない場合は importProgressInterval 件ごとに作成した件数を渡します。
+func createImportedTodos(tx *gorm.DB, user *User, todos []importTodo, progress func(int)) error {
+      tags := map[string]*Tag{}
+      for i := range todos {
+            todo := &todos[i].todo
+            for _, name := range todos[i].row.Tags {
+                  name = strings.TrimSpace(name)
+                  key := strings.ToLower(name)
+                  if _, ok := tags[key]; !ok {
+                        tag, err := FindOrCreateTag(tx, name)
+                        if err != nil {
+                              return err
+                        }
+                        tags[key] = &tag
+                  }
+                  if !containsTag(todo.Tags, tags[key].ID) {
+                        todo.Tags = append(todo.Tags, tags[key])
+                  }
+            }
+            if err := createTodo(tx, todo, []TodoAssignee{{UserID: user.ID, Role: AssigneeRoleOwner}}); err != nil {
+                  return fmt.Errorf("row %d: %w", todos[i].row.Row, err)
+            }
+            if progress != nil && (i+1)%importProgressInterval == 0 {
+                  progress(i + 1)
+            }
+      }
+      return nil
+}
+
+func containsTag(tags []*Tag, id uint) bool {
+      for _, tag := range tags {
+            if tag.ID == id {
+                  return true
+            }
+      }
+      return false
+}
+
+// GetImportJob は userID のユーザーが開始した取り込みの進捗を返します。他のユーザーのものは ErrRecordNotFound になります。
+func (m *TodoModel) GetImportJob(userID uint, id uint) (ImportJob, error) {
+      var job ImportJob
+      if err := m.DB.Where("id = ? AND user_id = ?", id, userID).First(&job).Error; err != nil {
+            return ImportJob{}, err
+      }
+      return job, nil
+}
+
+func (m *TodoModel) ConvertImportResultToOutput(result ImportResult) requests.ImportResultOutput {
+      output := requests.ImportResultOutput{
+            Total:  result.Total,
+            DryRun: result.DryRun,
+            Valid:  result.Total - countErrorRows(result.Errors),
+            Errors: []requests.ImportRowErrorOutput{},
+      }
+      for _, rowError := range result.Errors {
+            output.Errors = append(output.Errors, requests.ImportRowErrorOutput{
+                  Row:     rowError.Row,
+                  Field:   rowError.Field,
+                  Message: rowError.Message,
+            })
+      }
+      if len(result.Todos) > 0 {
+            output.Created = len(result.Todos)
+            output.Todos = m.ConvertTodosToOutput(result.Todos)
+      }
+      if result.Job != nil {
+            job := m.ConvertImportJobToOutput(*result.Job)
+            output.Job = &job
+      }
+      return output
+}
+
+func countErrorRows(rowErrors []ImportRowError) int {
+      rows := map[int]bool{}
+      for _, rowError := range rowErrors {
+            rows[rowError.Row] = true
+      }
+      return len(rows)
+}
+
+func (m *TodoModel) ConvertImportJobToOutput(job ImportJob) requests.ImportJobOutput {
+      return requests.ImportJobOutput{
+            ID:         job.ID,
+            Format:     job.Format,
+            Status:     job.Status,
+            Total:      job.Total,
+            Processed:  job.Processed,
+            Error:      job.Error,
+            CreatedAt:  job.CreatedAt,
+            FinishedAt: job.FinishedAt,
+      }
+}
@@ -0,0 +1,345 @@
+package models
+
+import (
+	"app/pkg/utils"
+	"app/requests"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // InvitationStatusPending は返事を待っている招待です。
+      InvitationStatusPending = "pending"
+      // InvitationStatusAccepted は承諾された招待です。
+      InvitationStatusAccepted = "accepted"
+      // InvitationStatusDeclined は辞退された招待です。
+      InvitationStatusDeclined = "declined"
+      // InvitationStatusRevoked は取り消された招待です。同じメールアドレスを招待し直した場合も以前の招待は取り消されます。
+      InvitationStatusRevoked = "revoked"
+      // InvitationStatusExpired は返事が無いまま期限が切れた招待です。DB には保存せず、出力するときにだけ使います。
+      InvitationStatusExpired = "expired"
+)
+
+// WorkspaceInvitation はメールアドレスを指定したワークスペースへの招待です。
+// 招待リンクのトークンは署名付きで、招待の ID と期限だけを持ちます。
+type WorkspaceInvitation struct {
+      ID          uint       `gorm:"primary_key" json:"id"`
+      WorkspaceID uint       `gorm:"not null;index" json:"workspace_id"`
+      Email       string     `gorm:"not null;index" json:"email"`
+      Role        string     `gorm:"not null;default:'member'" json:"role"`
+      Status      string     `gorm:"not null;default:'pending'" json:"status"`
+      InvitedByID uint       `gorm:"not null" json:"invited_by_id"`
+      ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+      RespondedAt *time.Time `json:"responded_at"`
+      Workspace   Workspace  `json:"workspace"`
+      InvitedBy   User       `json:"invited_by"`
+      CreatedAt   time.Time  `json:"created_at"`
+}
+
+var (
+      // ErrInvalidInvitation は招待リンクのトークンが正しくない場合に返されます。
+      ErrInvalidInvitation = errors.New("invitation not found")
+      // ErrInvitationExpired は招待の期限が切れている場合に返されます。
+      ErrInvitationExpired = errors.New("invitation has expired")
+      // ErrInvitationNotPending は既に承諾・辞退・取り消しされた招待に返事をしようとした場合に返されます。
+      ErrInvitationNotPending = errors.New("invitation is no longer pending")
+      // ErrInvitationEmailMismatch は招待されたメールアドレスと異なるユーザーが承諾しようとした場合に返されます。
+      ErrInvitationEmailMismatch = errors.New("invitation was sent to another email address")
+)
+
+// InvitationTTL は招待リンクの有効期間です。INVITATION_TTL_HOURS で設定します（デフォルトは7日）。
+func InvitationTTL() time.Duration {
+      return time.Duration(utils.GetEnvInt("INVITATION_TTL_HOURS", 7*24)) * time.Hour
+}
+
+// InvitationLink は招待リンクの URL を返します。INVITATION_URL（デフォルトは http://localhost:8080/invitations）の後ろにトークンを付けます。
+func InvitationLink(token string) string {
+      base := os.Getenv("INVITATION_URL")
+      if base == "" {
+            base = "http://localhost:8080/invitations"
+      }
+      return strings.TrimRight(base, "/") + "/" + token
+}
+
+// CreateWorkspaceInvitation は email 宛ての招待を作成し、招待リンクのトークンを返します。
+// 管理者以上の役割が必要で、持ち主として招待できるのは持ち主だけです。
+// 同じメールアドレスへの返事待ちの招待がある場合は、それを取り消して作り直します。
+func (m *TodoModel) CreateWorkspaceInvitation(workspaceID uint, callerID uint, input requests.InvitationInput) (WorkspaceInvitation, string, error) {
+      var invitation WorkspaceInvitation
+      var token string
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            role := input.Role
+            if role == "" {
+                  role = WorkspaceRoleMember
+            }
+            if err := m.checkGrantWorkspaceRole(tx, workspaceID, callerID, role); err != nil {
+                  return err
+            }
+            email := strings.TrimSpace(input.Email)
+
+            var count int64
+            err := tx.Model(&WorkspaceMember{}).
+                  Joins("JOIN users ON users.id = workspace_members.user_id").
+                  Where("workspace_members.workspace_id = ? AND LOWER(users.email) = LOWER(?)", workspaceID, email).
+                  Count(&count).Error
+            if err != nil {
+                  return err
+            }
+            if count > 0 {
+                  return ErrAlreadyWorkspaceMember
+            }
+
+            now := time.Now()
+            err = tx.Model(&WorkspaceInvitation{}).
+                  Where("workspace_id = ? AND LOWER(email) = LOWER(?) AND status = ?", workspaceID, email, InvitationStatusPending).
+                  Updates(map[string]interface{}{"status": InvitationStatusRevoked, "responded_at": now}).Error
+            if err != nil {
+                  return err
+            }
+
+            invitation = WorkspaceInvitation{
+                  WorkspaceID: workspaceID,
+                  Email:       email,
+                  Role:        role,
+                  Status:      InvitationStatusPending,
+                  InvitedByID: callerID,
+                  ExpiresAt:   now.Add(InvitationTTL()),
+            }
+            if err := tx.Omit("Workspace", "InvitedBy").Create(&invitation).Error; err != nil {
+                  return err
+            }
+            token, err = utils.GenerateInvitationToken(invitation.ID, invitation.ExpiresAt)
+            if err != nil {
+                  return err
+            }
+            return tx.Preload("Workspace").Preload("InvitedBy").Where("id = ?", invitation.ID).First(&invitation).Error
+      })
+      if err != nil {
+            return WorkspaceInvitation{}, "", err
+      }
+      return invitation, token, nil
+}
+
+// GetWorkspaceInvitations はワークスペースの招待を新しい順に返します。管理者以上の役割が必要です。
+func (m *TodoModel) GetWorkspaceInvitations(workspaceID uint, callerID uint) ([]WorkspaceInvitation, error) {
+      if err := m.requireWorkspaceRole(m.DB, workspaceID, callerID, WorkspaceRoleOwner, WorkspaceRoleAdmin); err != nil {
+            return nil, err
+      }
+      var invitations []WorkspaceInvitation
+      err := m.DB.Preload("Workspace").Preload("InvitedBy").
+            Where("workspace_id = ?", workspaceID).
+            Order("created_at DESC, id DESC").
+            Find(&invitations).Error
+      if err != nil {
+            return nil, err
+      }
+      return invitations, nil
+}
+
+// RevokeWorkspaceInvitation は返事待ちの招待を取り消します。管理者以上の役割が必要です。
+func (m *TodoModel) RevokeWorkspaceInvitation(workspaceID uint, callerID uint, invitationID uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := m.requireWorkspaceRole(tx, workspaceID, callerID, WorkspaceRoleOwner, WorkspaceRoleAdmin); err != nil {
+                  return err
+            }
+            var invitation WorkspaceInvitation
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+                  Where("workspace_id = ? AND id = ?", workspaceID, invitationID).First(&invitation).Error; err != nil {
+                  return err
+            }
+            if invitation.Status != InvitationStatusPending {
+                  return ErrInvitationNotPending
+            }
+            return respondInvitation(tx, invitation, InvitationStatusRevoked)
+      })
+}
+
+// GetInvitation は招待リンクのトークンから招待を返します。承諾する前に、どのワークスペースへの招待かを表示するために使います。
+func (m *TodoModel) GetInvitation(token string) (WorkspaceInvitation, error) {
+      id, err := parseInvitationToken(token)
+      if err != nil {
+            return WorkspaceInvitation{}, err
+      }
+      var invitation WorkspaceInvitation
+      err = m.DB.Preload("Workspace").Preload("InvitedBy").Where("id = ?", id).First(&invitation).Error
+      if errors.Is(err, gorm.ErrRecordNotFound) {
+            return WorkspaceInvitation{}, ErrInvalidInvitation
+      }
+      if err != nil {
+            return WorkspaceInvitation{}, err
+      }
+      return invitation, nil
+}
+
+// AcceptInvitation は既存のユーザーとして招待を承諾し、ワークスペースに参加します。
+// 招待されたメールアドレスのユーザーでなければ承諾できません。
+func (m *TodoModel) AcceptInvitation(token string, userID uint) (WorkspaceMember, error) {
+      var member WorkspaceMember
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var user User
+            if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+                  return err
+            }
+            var err error
+            member, err = acceptInvitation(tx, token, user)
+            return err
+      })
+      if err != nil {
+            return WorkspaceMember{}, err
+      }
+      return member, nil
+}
+
+// SignUpWithInvitation は招待されたメールアドレスでアカウントを作成し、そのまま招待を承諾します。
+// 招待されたワークスペースに参加するので、個人用のワークスペースは作りません。
+func (m *TodoModel) SignUpWithInvitation(token string, input requests.InvitationSignUpInput) (User, WorkspaceMember, error) {
+      invitation, err := m.GetInvitation(token)
+      if err != nil {
+            return User{}, WorkspaceMember{}, err
+      }
+      newUser, err := m.buildUser(requests.CreateUserInput{
+            Name:     input.Name,
+            Email:    invitation.Email,
+            Password: input.Password,
+            TimeZone: input.TimeZone,
+      })
+      if err != nil {
+            return User{}, WorkspaceMember{}, err
+      }
+
+      var member WorkspaceMember
+      err = m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Create(&newUser).Error; err != nil {
+                  return err
+            }
+            var err error
+            member, err = acceptInvitation(tx, token, newUser)
+            return err
+      })
+      if err != nil {
+            return User{}, WorkspaceMember{}, err
+      }
+      return newUser, member, nil
+}
+
+// DeclineInvitation は招待を辞退します。アカウントを持っていなくても、招待リンクのトークンだけで辞退できます。
+func (m *TodoModel) DeclineInvitation(token string) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            invitation, err := lockPendingInvitation(tx, token)
+            if err != nil {
+                  return err
+            }
+            return respondInvitation(tx, invitation, InvitationStatusDeclined)
+      })
+}
+
+func acceptInvitation(tx *gorm.DB, token string, user User) (WorkspaceMember, error) {
+      invitation, err := lockPendingInvitation(tx, token)
+      if err != nil {
+            return WorkspaceMember{}, err
+      }
+      if !strings.EqualFold(strings.TrimSpace(user.Email), invitation.Email) {
+            return WorkspaceMember{}, ErrInvitationEmailMismatch
+      }
+      var count int64
+      if err := tx.Model(&WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", invitation.WorkspaceID, user.ID).Count(&count).Error; err != nil {
+            return WorkspaceMember{}, err
+      }
+      if count > 0 {
+            return WorkspaceMember{}, ErrAlreadyWorkspaceMember
+      }
+
+      member := WorkspaceMember{WorkspaceID: invitation.WorkspaceID, UserID: user.ID, Role: invitation.Role}
+      if err := tx.Omit("Workspace", "User").Create(&member).Error; err != nil {
+            return WorkspaceMember{}, err
+      }
+      if err := respondInvitation(tx, invitation, InvitationStatusAccepted); err != nil {
+            return WorkspaceMember{}, err
+      }
+      if err := tx.Where("id = ?", invitation.WorkspaceID).First(&member.Workspace).Error; err != nil {
+            return WorkspaceMember{}, err
+      }
+      member.User = user
+      return member, nil
+}
+
+// lockPendingInvitation はトークンの招待を更新用にロックして返します。返事待ちでない場合や期限が切れている場合はエラーです。
+func lockPendingInvitation(tx *gorm.DB, token string) (WorkspaceInvitation, error) {
+      id, err := parseInvitationToken(token)
+      if err != nil {
+            return WorkspaceInvitation{}, err
+      }
+      var invitation WorkspaceInvitation
+      err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&invitation).Error
+      if errors.Is(err, gorm.ErrRecordNotFound) {
+            return WorkspaceInvitation{}, ErrInvalidInvitation
+      }
+      if err != nil {
+            return WorkspaceInvitation{}, err
+      }
+      if invitation.Status != InvitationStatusPending {
+            return WorkspaceInvitation{}, ErrInvitationNotPending
+      }
+      if !invitation.ExpiresAt.After(time.Now()) {
+            return WorkspaceInvitation{}, ErrInvitationExpired
+      }
+      return invitation, nil
+}
+
+func respondInvitation(tx *gorm.DB, invitation WorkspaceInvitation, status string) error {
+      return tx.Model(&WorkspaceInvitation{}).Where("id = ?", invitation.ID).
+            Updates(map[string]interface{}{"status": status, "responded_at": time.Now()}).Error
+}
+
+func parseInvitationToken(token string) (uint, error) {
+      id, err := utils.ParseInvitationToken(token)
+      if errors.Is(err, jwt.ErrTokenExpired) {
+            return 0, ErrInvitationExpired
+      }
+      if err != nil {
+            return 0, ErrInvalidInvitation
+      }
+      return id, nil
+}
+
+// invitationStatus は招待の状態を返します。返事待ちのまま期限が切れたものは expired になります。
+func invitationStatus(invitation WorkspaceInvitation, now time.Time) string {
+      if invitation.Status == InvitationStatusPending && !invitation.ExpiresAt.After(now) {
+            return InvitationStatusExpired
+      }
+      return invitation.Status
+}
+
+func (m *TodoModel) ConvertInvitationToOutput(invitation WorkspaceInvitation) requests.InvitationOutput {
+      return requests.InvitationOutput{
+            ID:            invitation.ID,
+            WorkspaceID:   invitation.WorkspaceID,
+            WorkspaceName: invitation.Workspace.Name,
+            Email:         invitation.Email,
+            Role:          invitation.Role,
+            Status:        invitationStatus(invitation, time.Now()),
+            InvitedBy: requests.AuthOutput{
+                  ID:       invitation.InvitedBy.ID,
+                  Name:     invitation.InvitedBy.Name,
+                  Email:    invitation.InvitedBy.Email,
+                  TimeZone: invitation.InvitedBy.TimeZone,
+            },
+            ExpiresAt:   invitation.ExpiresAt,
+            RespondedAt: invitation.RespondedAt,
+            CreatedAt:   invitation.CreatedAt,
+      }
+}
+
+func (m *TodoModel) ConvertInvitationsToOutput(invitations []WorkspaceInvitation) []requests.InvitationOutput {
+      output := []requests.InvitationOutput{}
+      for _, invitation := range invitations {
+            output = append(output, m.ConvertInvitationToOutput(invitation))
+      }
+      return output
+}
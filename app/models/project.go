@@ -0,0 +1,611 @@
+package models
+
+import (
+	"app/requests"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // ProjectRoleManager はプロジェクトの設定やメンバーを管理でき、アーカイブや削除もできます。
+      ProjectRoleManager = "manager"
+      // ProjectRoleMember はプロジェクトの Todo を作成・変更できます。
+      ProjectRoleMember = "member"
+)
+
+// projectDateLayout はプロジェクトの開始日・終了日の形式です。
+const projectDateLayout = "2006-01-02"
+
+// Project はワークスペースの中で Todo をまとめる単位です。
+// プロジェクトの Todo はワークスペースのメンバー全員が見られますが、変更できるのはプロジェクトのメンバーだけです。
+// ワークスペースの持ち主と管理者は、すべてのプロジェクトのマネージャーとして扱います。
+type Project struct {
+      ID uint `gorm:"primary_key" json:"id"`
+      // 属するワークスペース（workspace.go を参照）
+      WorkspaceID uint       `gorm:"not null;default:0;index" json:"workspace_id"`
+      Name        string     `gorm:"not null" json:"name"`
+      Description string     `json:"description"`
+      Color       string     `json:"color"`
+      // アーカイブしたプロジェクトの Todo は、一覧・ボード・エクスポート・カレンダーに出てきません
+      Archived   bool       `gorm:"not null;default:false" json:"archived"`
+      ArchivedAt *time.Time `json:"archived_at"`
+      StartDate  *time.Time `gorm:"type:date" json:"start_date"`
+      EndDate    *time.Time `gorm:"type:date" json:"end_date"`
+      CreatedAt  time.Time  `json:"created_at"`
+      UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ProjectMember はプロジェクトのメンバーと役割です。
+type ProjectMember struct {
+      ProjectID uint      `gorm:"primaryKey;autoIncrement:false" json:"project_id"`
+      UserID    uint      `gorm:"primaryKey;autoIncrement:false;index" json:"user_id"`
+      Role      string    `gorm:"not null;default:'member'" json:"role"`
+      User      User      `json:"user"`
+      CreatedAt time.Time `json:"created_at"`
+}
+
+// ProjectStats はプロジェクトの進み具合です。サブタスクも 1 件として数えます（ゴミ箱の Todo は除く）。
+type ProjectStats struct {
+      Total   int
+      Done    int
+      Overdue int
+      // ステータスごとの件数。ステータスの並び順
+      ByStatus []ProjectStatusCount
+}
+
+type ProjectStatusCount struct {
+      Status Status
+      Count  int
+}
+
+var (
+      // ErrProjectForbidden はプロジェクトの役割が足りない場合に返されます。
+      ErrProjectForbidden = errors.New("your project role does not allow this operation")
+      // ErrInvalidProjectRole は対応していない役割が指定された場合に返されます。
+      ErrInvalidProjectRole = errors.New("role must be manager or member")
+      // ErrInvalidProjectDate は開始日・終了日の形式が正しくない場合や、終了日が開始日より前の場合に返されます。
+      ErrInvalidProjectDate = errors.New("start_date and end_date must be YYYY-MM-DD and end_date must not be before start_date")
+      // ErrProjectArchived はアーカイブしたプロジェクトに Todo を追加しようとした場合に返されます。
+      ErrProjectArchived = errors.New("project is archived")
+      // ErrLastProjectManager はマネージャーがいなくなる変更をしようとした場合に返されます。
+      ErrLastProjectManager = errors.New("project must have at least one manager")
+      // ErrAlreadyProjectMember は既にメンバーのユーザーを追加しようとした場合に返されます。
+      ErrAlreadyProjectMember = errors.New("user is already a member of the project")
+)
+
+// WithUser は userID のユーザーとして、プロジェクトの権限を確認する TodoModel を返します。
+// Todo の履歴（history.go を参照）にも、このユーザーの操作として記録します。
+func (m *TodoModel) WithUser(userID uint) *TodoModel {
+      local := *m
+      local.UserID = userID
+      local.DB = withActor(m.DB, userID)
+      return &local
+}
+
+// GetProjects はプロジェクトを名前順に返します。includeArchived が false の場合はアーカイブしたものを除きます。
+func (m *TodoModel) GetProjects(includeArchived bool) ([]Project, error) {
+      db := m.DB
+      if !includeArchived {
+            db = db.Where("archived = ?", false)
+      }
+      var projects []Project
+      if err := db.Order("name, id").Find(&projects).Error; err != nil {
+            return nil, err
+      }
+      return projects, nil
+}
+
+func (m *TodoModel) GetProjectByID(id uint) (Project, error) {
+      var project Project
+      if err := m.DB.Where("id = ?", id).First(&project).Error; err != nil {
+            return Project{}, err
+      }
+      return project, nil
+}
+
+// CreateProject はプロジェクトを作成します。作成したユーザーがマネージャーになります。
+func (m *TodoModel) CreateProject(input requests.CreateProjectInput) (Project, error) {
+      project := Project{
+            Name:        strings.TrimSpace(input.Name),
+            Description: input.Description,
+            Color:       input.Color,
+      }
+      var err error
+      if project.StartDate, err = parseProjectDate(input.StartDate); err != nil {
+            return Project{}, err
+      }
+      if project.EndDate, err = parseProjectDate(input.EndDate); err != nil {
+            return Project{}, err
+      }
+      if err := validateProjectDates(project); err != nil {
+            return Project{}, err
+      }
+
+      err = m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Create(&project).Error; err != nil {
+                  return err
+            }
+            if m.UserID == 0 {
+                  return nil
+            }
+            member := ProjectMember{ProjectID: project.ID, UserID: m.UserID, Role: ProjectRoleManager}
+            return tx.Omit("User").Create(&member).Error
+      })
+      if err != nil {
+            return Project{}, err
+      }
+      return project, nil
+}
+
+// UpdateProject はプロジェクトの名前・説明・色・期間を変更します。マネージャーの役割が必要です。
+// 開始日・終了日は空文字列を指定すると未設定に戻ります。
+func (m *TodoModel) UpdateProject(id uint, input requests.UpdateProjectInput) (Project, error) {
+      var project Project
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&project).Error; err != nil {
+                  return err
+            }
+            if err := m.requireProjectRole(tx, project.ID, ProjectRoleManager); err != nil {
+                  return err
+            }
+            if name := strings.TrimSpace(input.Name); name != "" {
+                  project.Name = name
+            }
+            if input.Description != nil {
+                  project.Description = *input.Description
+            }
+            if input.Color != nil {
+                  project.Color = *input.Color
+            }
+            var err error
+            if input.StartDate != nil {
+                  if project.StartDate, err = parseProjectDate(*input.StartDate); err != nil {
+                        return err
+                  }
+            }
+            if input.EndDate != nil {
+                  if project.EndDate, err = parseProjectDate(*input.EndDate); err != nil {
+                        return err
+                  }
+            }
+            if err := validateProjectDates(project); err != nil {
+                  return err
+            }
+            return tx.Save(&project).Error
+      })
+      if err != nil {
+            return Project{}, err
+      }
+      return project, nil
+}
+
+// SetProjectArchived はプロジェクトをアーカイブ（archived が true）または元に戻します。マネージャーの役割が必要です。
+// アーカイブしても Todo は削除せず、project_id を指定した一覧や Todo の取得では引き続き見られます。
+func (m *TodoModel) SetProjectArchived(id uint, archived bool) (Project, error) {
+      var project Project
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&project).Error; err != nil {
+                  return err
+            }
+            if err := m.requireProjectRole(tx, project.ID, ProjectRoleManager); err != nil {
+                  return err
+            }
+            if project.Archived == archived {
+                  return nil
+            }
+            project.Archived = archived
+            project.ArchivedAt = nil
+            if archived {
+                  now := time.Now()
+                  project.ArchivedAt = &now
+            }
+            return tx.Save(&project).Error
+      })
+      if err != nil {
+            return Project{}, err
+      }
+      return project, nil
+}
+
+// DeleteProject はプロジェクトを削除します。マネージャーの役割が必要です。
+// プロジェクトの Todo（ゴミ箱を含む）は削除せず、どのプロジェクトにも属さない Todo になります。
+func (m *TodoModel) DeleteProject(id uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            var project Project
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&project).Error; err != nil {
+                  return err
+            }
+            if err := m.requireProjectRole(tx, project.ID, ProjectRoleManager); err != nil {
+                  return err
+            }
+            if err := tx.Unscoped().Model(&Todo{}).Where("project_id = ?", project.ID).Updates(map[string]interface{}{
+                  "project_id": nil,
+                  "version":    gorm.Expr("version + 1"),
+            }).Error; err != nil {
+                  return err
+            }
+            if err := tx.Where("project_id = ?", project.ID).Delete(&ProjectMember{}).Error; err != nil {
+                  return err
+            }
+            return tx.Delete(&project).Error
+      })
+}
+
+// GetProjectStats はプロジェクトの Todo の件数を、完了・期限切れ・ステータスごとに集計します。
+func (m *TodoModel) GetProjectStats(id uint) (ProjectStats, error) {
+      if _, err := m.GetProjectByID(id); err != nil {
+            return ProjectStats{}, err
+      }
+      statuses, err := m.GetStatuses()
+      if err != nil {
+            return ProjectStats{}, err
+      }
+
+      var counts []struct {
+            StatusID uint
+            Count    int
+            Overdue  int
+      }
+      err = m.DB.Model(&Todo{}).
+            Select("status_id, COUNT(*) AS count, COUNT(*) FILTER (WHERE deadline > ? AND deadline < ?) AS overdue", time.Time{}, time.Now()).
+            Where("project_id = ?", id).
+            Group("status_id").
+            Scan(&counts).Error
+      if err != nil {
+            return ProjectStats{}, err
+      }
+
+      stats := ProjectStats{ByStatus: []ProjectStatusCount{}}
+      byStatus := map[uint]int{}
+      for _, count := range counts {
+            byStatus[count.StatusID] = count.Count
+            stats.Total += count.Count
+            for _, status := range statuses {
+                  if status.ID != count.StatusID {
+                        continue
+                  }
+                  if status.IsDone {
+                        stats.Done += count.Count
+                  } else {
+                        // 完了したものは期限を過ぎていても期限切れに数えない
+                        stats.Overdue += count.Overdue
+                  }
+            }
+      }
+      for _, status := range statuses {
+            stats.ByStatus = append(stats.ByStatus, ProjectStatusCount{Status: status, Count: byStatus[status.ID]})
+      }
+      return stats, nil
+}
+
+// GetProjectMembers はプロジェクトのメンバーを返します。
+func (m *TodoModel) GetProjectMembers(id uint) ([]ProjectMember, error) {
+      if _, err := m.GetProjectByID(id); err != nil {
+            return nil, err
+      }
+      var members []ProjectMember
+      if err := m.DB.Preload("User").Where("project_id = ?", id).Order("created_at, user_id").Find(&members).Error; err != nil {
+            return nil, err
+      }
+      return members, nil
+}
+
+// AddProjectMember はワークスペースのメンバーをプロジェクトに追加します。マネージャーの役割が必要です。
+func (m *TodoModel) AddProjectMember(id uint, input requests.ProjectMemberInput) (ProjectMember, error) {
+      var member ProjectMember
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            project, err := m.lockProjectForManager(tx, id)
+            if err != nil {
+                  return err
+            }
+            role := input.Role
+            if role == "" {
+                  role = ProjectRoleMember
+            }
+            if err := validateProjectRole(role); err != nil {
+                  return err
+            }
+
+            var user User
+            switch {
+            case input.UserID != 0:
+                  err = tx.Where("id = ?", input.UserID).First(&user).Error
+            case input.Email != "":
+                  err = tx.Where("email = ?", input.Email).First(&user).Error
+            default:
+                  err = ErrAssigneeUserRequired
+            }
+            if err != nil {
+                  return err
+            }
+            if err := checkWorkspaceMembers(tx, project.WorkspaceID, []uint{user.ID}); err != nil {
+                  return err
+            }
+            var count int64
+            if err := tx.Model(&ProjectMember{}).Where("project_id = ? AND user_id = ?", project.ID, user.ID).Count(&count).Error; err != nil {
+                  return err
+            }
+            if count > 0 {
+                  return ErrAlreadyProjectMember
+            }
+
+            member = ProjectMember{ProjectID: project.ID, UserID: user.ID, Role: role}
+            if err := tx.Omit("User").Create(&member).Error; err != nil {
+                  return err
+            }
+            member.User = user
+            return nil
+      })
+      if err != nil {
+            return ProjectMember{}, err
+      }
+      return member, nil
+}
+
+// UpdateProjectMember はメンバーの役割を変更します。マネージャーの役割が必要です。
+func (m *TodoModel) UpdateProjectMember(id uint, userID uint, role string) (ProjectMember, error) {
+      var member ProjectMember
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := validateProjectRole(role); err != nil {
+                  return err
+            }
+            if _, err := m.lockProjectForManager(tx, id); err != nil {
+                  return err
+            }
+            if err := tx.Preload("User").Where("project_id = ? AND user_id = ?", id, userID).First(&member).Error; err != nil {
+                  return err
+            }
+            if member.Role == ProjectRoleManager && role != ProjectRoleManager {
+                  if err := checkOtherProjectManager(tx, id, userID); err != nil {
+                        return err
+                  }
+            }
+            member.Role = role
+            return tx.Model(&ProjectMember{}).Where("project_id = ? AND user_id = ?", id, userID).Update("role", role).Error
+      })
+      if err != nil {
+            return ProjectMember{}, err
+      }
+      return member, nil
+}
+
+// RemoveProjectMember はメンバーをプロジェクトから外します。自分で抜ける場合を除き、マネージャーの役割が必要です。
+func (m *TodoModel) RemoveProjectMember(id uint, userID uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            var project Project
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&project).Error; err != nil {
+                  return err
+            }
+            if userID != m.UserID {
+                  if err := m.requireProjectRole(tx, project.ID, ProjectRoleManager); err != nil {
+                        return err
+                  }
+            }
+            var member ProjectMember
+            if err := tx.Where("project_id = ? AND user_id = ?", id, userID).First(&member).Error; err != nil {
+                  return err
+            }
+            if member.Role == ProjectRoleManager {
+                  if err := checkOtherProjectManager(tx, id, userID); err != nil {
+                        return err
+                  }
+            }
+            return tx.Where("project_id = ? AND user_id = ?", id, userID).Delete(&ProjectMember{}).Error
+      })
+}
+
+// CheckTodoWrite は Todo（ゴミ箱のものを含む）のプロジェクトで、Todo を変更できるかを確認します。
+func (m *TodoModel) CheckTodoWrite(todoID uint) error {
+      var todo Todo
+      if err := m.DB.Unscoped().Select("id", "project_id").Where("id = ?", todoID).First(&todo).Error; err != nil {
+            return err
+      }
+      return m.checkProjectWrite(m.DB, todo.ProjectID)
+}
+
+// checkProjectWrite は projectID のプロジェクトの Todo を変更できるかを確認します。
+// プロジェクトに属さない Todo と、ユーザーを指定していない場合（定期実行するジョブなど）は常に変更できます。
+func (m *TodoModel) checkProjectWrite(tx *gorm.DB, projectID *uint) error {
+      if projectID == nil {
+            return nil
+      }
+      return m.requireProjectRole(tx, *projectID, ProjectRoleManager, ProjectRoleMember)
+}
+
+// checkProjectTarget は Todo を projectID のプロジェクトに入れられるかを確認します。アーカイブしたプロジェクトには入れられません。
+func (m *TodoModel) checkProjectTarget(tx *gorm.DB, projectID *uint) error {
+      if projectID == nil {
+            return nil
+      }
+      var project Project
+      if err := tx.Where("id = ?", *projectID).First(&project).Error; err != nil {
+            return err
+      }
+      if project.Archived {
+            return ErrProjectArchived
+      }
+      return m.checkProjectWrite(tx, projectID)
+}
+
+// requireProjectRole は呼び出したユーザーが roles のいずれかの役割でプロジェクトに参加していることを確認します。
+// ワークスペースの持ち主と管理者はマネージャーとして扱います。
+func (m *TodoModel) requireProjectRole(tx *gorm.DB, projectID uint, roles ...string) error {
+      if m.UserID == 0 {
+            return nil
+      }
+      role, err := projectRole(tx, projectID, m.UserID)
+      if err != nil {
+            return err
+      }
+      for _, r := range roles {
+            if role == r {
+                  return nil
+            }
+      }
+      return ErrProjectForbidden
+}
+
+func projectRole(tx *gorm.DB, projectID uint, userID uint) (string, error) {
+      var workspaceRoles []string
+      err := tx.Model(&WorkspaceMember{}).
+            Where("user_id = ? AND workspace_id = (SELECT workspace_id FROM projects WHERE id = ?)", userID, projectID).
+            Pluck("role", &workspaceRoles).Error
+      if err != nil {
+            return "", err
+      }
+      if len(workspaceRoles) > 0 && (workspaceRoles[0] == WorkspaceRoleOwner || workspaceRoles[0] == WorkspaceRoleAdmin) {
+            return ProjectRoleManager, nil
+      }
+      var roles []string
+      if err := tx.Model(&ProjectMember{}).Where("project_id = ? AND user_id = ?", projectID, userID).Pluck("role", &roles).Error; err != nil {
+            return "", err
+      }
+      if len(roles) == 0 {
+            return "", nil
+      }
+      return roles[0], nil
+}
+
+func (m *TodoModel) lockProjectForManager(tx *gorm.DB, id uint) (Project, error) {
+      var project Project
+      if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&project).Error; err != nil {
+            return Project{}, err
+      }
+      if err := m.requireProjectRole(tx, project.ID, ProjectRoleManager); err != nil {
+            return Project{}, err
+      }
+      return project, nil
+}
+
+// checkOtherProjectManager は userID 以外のマネージャーがいることを確認します。
+// ワークスペースの持ち主と管理者はいつでも管理できますが、プロジェクトのメンバーとしてマネージャーを 1 人以上残します。
+func checkOtherProjectManager(tx *gorm.DB, projectID uint, userID uint) error {
+      var count int64
+      if err := tx.Model(&ProjectMember{}).Where("project_id = ? AND user_id <> ? AND role = ?", projectID, userID, ProjectRoleManager).Count(&count).Error; err != nil {
+            return err
+      }
+      if count == 0 {
+            return ErrLastProjectManager
+      }
+      return nil
+}
+
+func validateProjectRole(role string) error {
+      switch role {
+      case ProjectRoleManager, ProjectRoleMember:
+            return nil
+      default:
+            return ErrInvalidProjectRole
+      }
+}
+
+func parseProjectDate(value string) (*time.Time, error) {
+      if value == "" {
+            return nil, nil
+      }
+      date, err := time.Parse(projectDateLayout, value)
+      if err != nil {
+            return nil, ErrInvalidProjectDate
+      }
+      return &date, nil
+}
+
+func validateProjectDates(project Project) error {
+      if project.StartDate != nil && project.EndDate != nil && project.EndDate.Before(*project.StartDate) {
+            return ErrInvalidProjectDate
+      }
+      return nil
+}
+
+// excludeArchivedProjects はアーカイブしたプロジェクトの Todo を除きます。
+func excludeArchivedProjects(db *gorm.DB) *gorm.DB {
+      return db.Where("todos.project_id IS NULL OR todos.project_id NOT IN (SELECT id FROM projects WHERE archived)")
+}
+
+// filterByProject は project_id が指定された場合はそのプロジェクトの Todo に絞り込み、
+// 指定されていない場合は include_archived が true でない限りアーカイブしたプロジェクトの Todo を除きます。
+func filterByProject(db *gorm.DB, projectID uint, includeArchived bool) *gorm.DB {
+      if projectID != 0 {
+            return db.Where("todos.project_id = ?", projectID)
+      }
+      if includeArchived {
+            return db
+      }
+      return excludeArchivedProjects(db)
+}
+
+func formatProjectDate(date *time.Time) *string {
+      if date == nil {
+            return nil
+      }
+      s := date.Format(projectDateLayout)
+      return &s
+}
+
+func (m *TodoModel) ConvertProjectToOutput(project Project) requests.ProjectOutput {
+      return requests.ProjectOutput{
+            ID:          project.ID,
+            Name:        project.Name,
+            Description: project.Description,
+            Color:       project.Color,
+            Archived:    project.Archived,
+            ArchivedAt:  project.ArchivedAt,
+            StartDate:   formatProjectDate(project.StartDate),
+            EndDate:     formatProjectDate(project.EndDate),
+            CreatedAt:   project.CreatedAt,
+            UpdatedAt:   project.UpdatedAt,
+      }
+}
+
+func (m *TodoModel) ConvertProjectsToOutput(projects []Project) []requests.ProjectOutput {
+      output := []requests.ProjectOutput{}
+      for _, project := range projects {
+            output = append(output, m.ConvertProjectToOutput(project))
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertProjectStatsToOutput(stats ProjectStats) requests.ProjectStatsOutput {
+      output := requests.ProjectStatsOutput{
+            Total:    stats.Total,
+            Done:     stats.Done,
+            Open:     stats.Total - stats.Done,
+            Overdue:  stats.Overdue,
+            ByStatus: []requests.ProjectStatusCountOutput{},
+      }
+      if stats.Total > 0 {
+            output.Percent = stats.Done * 100 / stats.Total
+      }
+      for _, count := range stats.ByStatus {
+            output.ByStatus = append(output.ByStatus, requests.ProjectStatusCountOutput{
+                  Status: m.ConvertStatusToOutput(count.Status),
+                  Count:  count.Count,
+            })
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertProjectMemberToOutput(member ProjectMember) requests.ProjectMemberOutput {
+      return requests.ProjectMemberOutput{
+            User: requests.AuthOutput{
+                  ID:       member.User.ID,
+                  Name:     member.User.Name,
+                  Email:    member.User.Email,
+                  TimeZone: member.User.TimeZone,
+            },
+            Role:     member.Role,
+            JoinedAt: member.CreatedAt,
+      }
+}
+
+func (m *TodoModel) ConvertProjectMembersToOutput(members []ProjectMember) []requests.ProjectMemberOutput {
+      output := []requests.ProjectMemberOutput{}
+      for _, member := range members {
+            output = append(output, m.ConvertProjectMemberToOutput(member))
+      }
+      return output
+}
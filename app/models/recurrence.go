@@ -0,0 +1,560 @@
+package models
+
+import (
+	"app/pkg/utils"
+	"app/requests"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // RecurrenceGenerateOnComplete は今回の Todo を完了したときに次回の Todo を作成します。
+      RecurrenceGenerateOnComplete = "on_complete"
+      // RecurrenceGenerateSchedule は期限が近づいた次回の Todo を定期的に作成します（jobs/recurrence.go を参照）。
+      RecurrenceGenerateSchedule = "schedule"
+)
+
+const (
+      // RecurrenceScopeThis は選択した回の Todo だけを変更します。
+      RecurrenceScopeThis = "this"
+      // RecurrenceScopeFollowing は選択した回とそれ以降の Todo を変更します。
+      RecurrenceScopeFollowing = "following"
+      // RecurrenceScopeAll は繰り返しのすべての Todo を変更します。
+      RecurrenceScopeAll = "all"
+)
+
+// RecurrenceSeries は繰り返しの Todo のまとまりです。次回の Todo は Title などのテンプレートと RRULE から作成します。
+type RecurrenceSeries struct {
+      ID uint `gorm:"primary_key" json:"id"`
+      // 属するワークスペース（workspace.go を参照）
+      WorkspaceID uint `gorm:"not null;default:0;index" json:"workspace_id"`
+      // RFC 5545 の RRULE（例: FREQ=WEEKLY;BYDAY=MO）
+      RRule string `gorm:"not null" json:"rrule"`
+      // 繰り返しの起点となる日時（最初の回の期限）
+      DTStart time.Time `gorm:"not null" json:"dtstart"`
+      // BYDAY などの曜日や日付を判定するタイムゾーン（繰り返しを設定したユーザーのタイムゾーン）
+      TimeZone string `gorm:"not null;default:'UTC'" json:"time_zone"`
+      // 除外する回の日時（EXDATE）。iCalendar 形式をカンマ区切りで保存します。
+      ExDates  string `gorm:"not null;default:''" json:"exdates"`
+      Generate string `gorm:"not null;default:'on_complete'" json:"generate"`
+      // 次回の Todo のテンプレート
+      Title       string    `gorm:"not null" json:"title"`
+      Description string    `json:"description"`
+      Priority    int       `gorm:"not null;default:2" json:"priority"`
+      CreatedAt   time.Time `json:"created_at"`
+      UpdatedAt   time.Time `json:"updated_at"`
+}
+
+var (
+      // ErrNoDeadline は期限の無い Todo を繰り返しにしようとした場合に返されます。
+      ErrNoDeadline = errors.New("todo needs a deadline to repeat")
+      // ErrNotRecurring は繰り返しではない Todo の繰り返しを変更しようとした場合に返されます。
+      ErrNotRecurring = errors.New("todo is not recurring")
+      // ErrInvalidRecurrenceScope は対応していない変更範囲が指定された場合に返されます。
+      ErrInvalidRecurrenceScope = errors.New("scope must be this, following or all")
+      // ErrInvalidRecurrenceGenerate は対応していない作成タイミングが指定された場合に返されます。
+      ErrInvalidRecurrenceGenerate = errors.New("generate must be on_complete or schedule")
+)
+
+// GetTodoRecurrence は Todo が属する繰り返しを返します。
+func (m *TodoModel) GetTodoRecurrence(id uint) (RecurrenceSeries, error) {
+      todo, err := m.GetTodoByID(id)
+      if err != nil {
+            return RecurrenceSeries{}, err
+      }
+      if todo.SeriesID == nil {
+            return RecurrenceSeries{}, ErrNotRecurring
+      }
+      var series RecurrenceSeries
+      if err := m.DB.Where("id = ?", *todo.SeriesID).First(&series).Error; err != nil {
+            return RecurrenceSeries{}, err
+      }
+      return series, nil
+}
+
+// SetTodoRecurrence は version が一致する場合のみ、Todo を繰り返しにする、または繰り返しのルールを変更します。
+// 既に繰り返しの Todo の場合、scope が following ならこの回から新しい繰り返しに分け、all なら繰り返し全体のルールを変更します。
+// どちらの場合も、この回より後の未完了の Todo は新しいルールで作り直します。scope の指定が無い場合は this です。
+// scope が this の場合は、この回だけを 1 回きり（COUNT=1）の繰り返しに分け、元の繰り返しはこの回を除いてそのまま続けます。
+func (m *TodoModel) SetTodoRecurrence(id uint, version uint, input requests.SetRecurrenceInput) (RecurrenceSeries, error) {
+      rule, err := utils.ParseRRule(input.RRule)
+      if err != nil {
+            return RecurrenceSeries{}, err
+      }
+      generate := input.Generate
+      if generate == "" {
+            generate = RecurrenceGenerateOnComplete
+      }
+      if generate != RecurrenceGenerateOnComplete && generate != RecurrenceGenerateSchedule {
+            return RecurrenceSeries{}, ErrInvalidRecurrenceGenerate
+      }
+      // UpdateTodo と同じく、指定が無ければこの回だけを変更する
+      scope := input.Scope
+      if scope == "" {
+            scope = RecurrenceScopeThis
+      }
+      if scope != RecurrenceScopeThis && scope != RecurrenceScopeFollowing && scope != RecurrenceScopeAll {
+            return RecurrenceSeries{}, ErrInvalidRecurrenceScope
+      }
+
+      var series RecurrenceSeries
+      err = m.DB.Transaction(func(tx *gorm.DB) error {
+            todo, err := lockTodoVersion(tx, id, version)
+            if err != nil {
+                  return err
+            }
+            if todo.Deadline.IsZero() {
+                  return ErrNoDeadline
+            }
+            occurrence := todo.Deadline
+            if todo.OccurrenceAt != nil {
+                  occurrence = *todo.OccurrenceAt
+            }
+
+            // scope が this の場合に、この回を外した元の繰り返し
+            var detachedFrom *RecurrenceSeries
+            switch {
+            case todo.SeriesID == nil:
+                  series = RecurrenceSeries{
+                        DTStart:     todo.Deadline,
+                        Title:       todo.Title,
+                        Description: todo.Description,
+                        Priority:    todo.Priority,
+                  }
+            case scope == RecurrenceScopeAll:
+                  if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", *todo.SeriesID).First(&series).Error; err != nil {
+                        return err
+                  }
+            case scope == RecurrenceScopeThis:
+                  // 元の繰り返しがこの回を作り直さないように、この回を除外する
+                  var old RecurrenceSeries
+                  if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", *todo.SeriesID).First(&old).Error; err != nil {
+                        return err
+                  }
+                  exdates := append(parseExDates(old.ExDates), occurrence)
+                  if err := tx.Model(&old).Update("ex_dates", formatExDates(exdates)).Error; err != nil {
+                        return err
+                  }
+                  detachedFrom = &old
+                  // この回だけの変更なので、新しいルールはこの回で終わらせる。次回以降は元の繰り返しが作る
+                  rule.Count = 1
+                  rule.Until = time.Time{}
+                  series = RecurrenceSeries{
+                        DTStart:     occurrence,
+                        Title:       old.Title,
+                        Description: old.Description,
+                        Priority:    old.Priority,
+                  }
+            default:
+                  // この回より前の回で繰り返しを終わらせ、この回から新しい繰り返しにする
+                  var old RecurrenceSeries
+                  if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", *todo.SeriesID).First(&old).Error; err != nil {
+                        return err
+                  }
+                  if err := endSeriesBefore(tx, &old, occurrence); err != nil {
+                        return err
+                  }
+                  series = RecurrenceSeries{
+                        DTStart:     occurrence,
+                        ExDates:     old.ExDates,
+                        Title:       old.Title,
+                        Description: old.Description,
+                        Priority:    old.Priority,
+                  }
+            }
+
+            series.RRule = rule.String()
+            series.Generate = generate
+            series.TimeZone = m.location().String()
+            // 終日の期限は日付の 0 時（UTC）で保存しているので、日付は UTC で判定する
+            if todo.DeadlineAllDay {
+                  series.TimeZone = time.UTC.String()
+            }
+            if input.ExDates != nil {
+                  series.ExDates = formatExDates(input.ExDates)
+            }
+            if err := tx.Save(&series).Error; err != nil {
+                  return err
+            }
+
+            if todo.SeriesID != nil && scope == RecurrenceScopeFollowing {
+                  if err := tx.Model(&Todo{}).Where("series_id = ? AND occurrence_at >= ?", *todo.SeriesID, occurrence).Update("series_id", series.ID).Error; err != nil {
+                        return err
+                  }
+            }
+            // 元の繰り返しの次の回は、タグや担当者を引き継げるように、この回を外す前に作成する
+            if detachedFrom != nil {
+                  if err := continueSeries(tx, detachedFrom, id); err != nil {
+                        return err
+                  }
+            }
+            if err := tx.Model(&Todo{}).Where("id = ?", id).Updates(map[string]interface{}{
+                  "series_id":     series.ID,
+                  "occurrence_at": occurrence,
+                  "version":       gorm.Expr("version + 1"),
+            }).Error; err != nil {
+                  return err
+            }
+            // この回だけの繰り返しには次の回が無いので、作り直すものは無い
+            if detachedFrom != nil {
+                  return nil
+            }
+
+            // 古いルールで作成済みの、この回より後の未完了の Todo は作り直す
+            if err := deletePendingOccurrences(tx, series.ID, occurrence); err != nil {
+                  return err
+            }
+            if series.Generate == RecurrenceGenerateSchedule {
+                  _, err := generateScheduledOccurrences(tx, series.ID, time.Now().Add(RecurrenceLookahead()))
+                  return err
+            }
+            // この回が既に完了している場合は、作り直す次の回を完了したときと同じように作成する
+            var done bool
+            if err := tx.Model(&Status{}).Select("is_done").Where("id = ?", todo.StatusID).Scan(&done).Error; err != nil {
+                  return err
+            }
+            if !done {
+                  return nil
+            }
+            todo.SeriesID = &series.ID
+            todo.OccurrenceAt = &occurrence
+            return onTodoCompleted(tx, &todo)
+      })
+      if err != nil {
+            return RecurrenceSeries{}, err
+      }
+      return series, nil
+}
+
+// StopTodoRecurrence は version が一致する場合のみ、この回で繰り返しを終わらせます。
+// この回より後の未完了の Todo はゴミ箱へ移動します。
+func (m *TodoModel) StopTodoRecurrence(id uint, version uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            todo, err := lockTodoVersion(tx, id, version)
+            if err != nil {
+                  return err
+            }
+            if todo.SeriesID == nil {
+                  return ErrNotRecurring
+            }
+            var series RecurrenceSeries
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", *todo.SeriesID).First(&series).Error; err != nil {
+                  return err
+            }
+            occurrence := todo.Deadline
+            if todo.OccurrenceAt != nil {
+                  occurrence = *todo.OccurrenceAt
+            }
+            if err := endSeriesBefore(tx, &series, occurrence.Add(time.Second)); err != nil {
+                  return err
+            }
+            if err := deletePendingOccurrences(tx, series.ID, occurrence); err != nil {
+                  return err
+            }
+            return touchTodo(tx, id)
+      })
+}
+
+// GenerateScheduledOccurrences は作成タイミングが schedule の繰り返しについて、until までに期限が来る回の Todo を作成します。
+// 作成した Todo の数を返します。
+func (m *TodoModel) GenerateScheduledOccurrences(until time.Time) (int, error) {
+      var seriesIDs []uint
+      if err := m.DB.Model(&RecurrenceSeries{}).Where("generate = ?", RecurrenceGenerateSchedule).Pluck("id", &seriesIDs).Error; err != nil {
+            return 0, err
+      }
+      total := 0
+      for _, seriesID := range seriesIDs {
+            err := m.DB.Transaction(func(tx *gorm.DB) error {
+                  created, err := generateScheduledOccurrences(tx, seriesID, until)
+                  total += created
+                  return err
+            })
+            if err != nil {
+                  return total, err
+            }
+      }
+      return total, nil
+}
+
+// lockTodoVersion は Todo の行をロックし、version が一致することを確認します。
+func lockTodoVersion(tx *gorm.DB, id uint, version uint) (Todo, error) {
+      var todo Todo
+      if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&todo).Error; err != nil {
+            return Todo{}, err
+      }
+      if todo.Version != version {
+            return Todo{}, ErrVersionMismatch
+      }
+      return todo, nil
+}
+
+// onTodoCompleted は繰り返しの Todo が完了扱いのステータスになったときに、次回の Todo を作成します。
+func onTodoCompleted(tx *gorm.DB, todo *Todo) error {
+      if todo.SeriesID == nil {
+            return nil
+      }
+      var series RecurrenceSeries
+      if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", *todo.SeriesID).First(&series).Error; err != nil {
+            return err
+      }
+      if series.Generate != RecurrenceGenerateOnComplete {
+            return nil
+      }
+      // 既に未完了の次回以降の Todo があれば作らない
+      query := tx.Model(&Todo{}).Joins("JOIN statuses ON statuses.id = todos.status_id").
+            Where("todos.series_id = ? AND todos.id <> ? AND NOT statuses.is_done", series.ID, todo.ID)
+      if todo.OccurrenceAt != nil {
+            query = query.Where("todos.occurrence_at > ?", *todo.OccurrenceAt)
+      }
+      var pending int64
+      if err := query.Count(&pending).Error; err != nil {
+            return err
+      }
+      if pending > 0 {
+            return nil
+      }
+      _, err := generateNextOccurrence(tx, &series)
+      return err
+}
+
+// continueSeries は作成タイミングが on_complete の繰り返しから detachedID の回を外すときに、他に未完了の回が無ければ次の回を作成します。
+// 外した回の完了を待って、元の繰り返しが止まらないようにします。
+func continueSeries(tx *gorm.DB, series *RecurrenceSeries, detachedID uint) error {
+      if series.Generate != RecurrenceGenerateOnComplete {
+            return nil
+      }
+      var pending int64
+      if err := tx.Model(&Todo{}).Joins("JOIN statuses ON statuses.id = todos.status_id").
+            Where("todos.series_id = ? AND todos.id <> ? AND NOT statuses.is_done", series.ID, detachedID).Count(&pending).Error; err != nil {
+            return err
+      }
+      if pending > 0 {
+            return nil
+      }
+      _, err := generateNextOccurrence(tx, series)
+      return err
+}
+
+// generateScheduledOccurrences は until までに期限が来る回の Todo をまとめて作成します。
+func generateScheduledOccurrences(tx *gorm.DB, seriesID uint, until time.Time) (int, error) {
+      var series RecurrenceSeries
+      if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", seriesID).First(&series).Error; err != nil {
+            return 0, err
+      }
+      created := 0
+      for {
+            next, err := nextOccurrence(tx, &series)
+            if err != nil || next == nil || next.After(until) {
+                  return created, err
+            }
+            if _, err := generateNextOccurrence(tx, &series); err != nil {
+                  return created, err
+            }
+            created++
+      }
+}
+
+// nextOccurrence は最後に作成した回の次の日時を返します。繰り返しが終わっている場合は nil を返します。
+// ゴミ箱に入った回も含めて数えるので、削除した回が作り直されることはありません。
+func nextOccurrence(tx *gorm.DB, series *RecurrenceSeries) (*time.Time, error) {
+      rule, err := utils.ParseRRule(series.RRule)
+      if err != nil {
+            return nil, err
+      }
+      var last struct{ At *time.Time }
+      if err := tx.Unscoped().Model(&Todo{}).Select("MAX(occurrence_at) AS at").Where("series_id = ?", series.ID).Scan(&last).Error; err != nil {
+            return nil, err
+      }
+      after := series.DTStart.Add(-time.Second)
+      if last.At != nil {
+            after = *last.At
+      }
+      loc, err := LoadTimeZone(series.TimeZone)
+      if err != nil {
+            loc = time.UTC
+      }
+      next, ok := rule.Next(series.DTStart.In(loc), after, parseExDates(series.ExDates))
+      if !ok {
+            return nil, nil
+      }
+      return &next, nil
+}
+
+// generateNextOccurrence は次の回の Todo をテンプレートから作成します。
+// タグと担当者は、最後に作成した回の Todo から引き継ぎます。
+func generateNextOccurrence(tx *gorm.DB, series *RecurrenceSeries) (*Todo, error) {
+      next, err := nextOccurrence(tx, series)
+      if err != nil || next == nil {
+            return nil, err
+      }
+
+      var previous Todo
+      err = tx.Preload("Assignees").Preload("Tags").Where("series_id = ?", series.ID).Order("occurrence_at DESC").First(&previous).Error
+      if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, err
+      }
+
+      seriesID := series.ID
+      occurrence := *next
+      todo := Todo{
+            Title:        series.Title,
+            Description:  series.Description,
+            Priority:     series.Priority,
+            Deadline:     occurrence,
+            DeadlineAllDay: previous.DeadlineAllDay,
+            WorkspaceID:  series.WorkspaceID,
+            ProjectID:    previous.ProjectID,
+            SeriesID:     &seriesID,
+            OccurrenceAt: &occurrence,
+            ParentID:     previous.ParentID,
+            Tags:         previous.Tags,
+      }
+      if err := createTodo(tx, &todo, previous.Assignees); err != nil {
+            return nil, err
+      }
+      return &todo, nil
+}
+
+// applyToSeries は UpdateTodo で scope に following / all が指定された場合に、同じ繰り返しの未完了の Todo とテンプレートにも変更を反映します。
+// 期限はその回だけの変更として扱います。
+func applyToSeries(tx *gorm.DB, todo Todo, scope string, input requests.UpdateTodoInput) error {
+      if todo.SeriesID == nil {
+            return ErrNotRecurring
+      }
+
+      seriesUpdates := map[string]interface{}{}
+      todoUpdates := map[string]interface{}{"version": gorm.Expr("version + 1")}
+      if input.Title != "" {
+            seriesUpdates["title"] = input.Title
+            todoUpdates["title"] = input.Title
+      }
+      if input.Description != "" {
+            seriesUpdates["description"] = input.Description
+            todoUpdates["description"] = input.Description
+      }
+      if input.Priority != nil {
+            seriesUpdates["priority"] = *input.Priority
+            todoUpdates["priority"] = *input.Priority
+      }
+      if len(seriesUpdates) > 0 {
+            if err := tx.Model(&RecurrenceSeries{}).Where("id = ?", *todo.SeriesID).Updates(seriesUpdates).Error; err != nil {
+                  return err
+            }
+      }
+
+      query := tx.Model(&Todo{}).Where("series_id = ? AND todos.id <> ?", *todo.SeriesID, todo.ID).
+            Where("status_id IN (SELECT id FROM statuses WHERE NOT is_done)")
+      if scope == RecurrenceScopeFollowing && todo.OccurrenceAt != nil {
+            query = query.Where("occurrence_at > ?", *todo.OccurrenceAt)
+      }
+      var ids []uint
+      if err := query.Pluck("todos.id", &ids).Error; err != nil {
+            return err
+      }
+      if len(ids) == 0 {
+            return nil
+      }
+      if err := tx.Model(&Todo{}).Where("id IN ?", ids).Updates(todoUpdates).Error; err != nil {
+            return err
+      }
+      if input.TagIDs != nil {
+            for _, id := range ids {
+                  if err := replaceTodoTags(tx, id, *input.TagIDs); err != nil {
+                        return err
+                  }
+            }
+      }
+      return nil
+}
+
+// endSeriesBefore は before より前の回で繰り返しが終わるように UNTIL を設定します。
+func endSeriesBefore(tx *gorm.DB, series *RecurrenceSeries, before time.Time) error {
+      rule, err := utils.ParseRRule(series.RRule)
+      if err != nil {
+            return err
+      }
+      until := before.Add(-time.Second).UTC()
+      if !rule.Until.IsZero() && rule.Until.Before(until) {
+            return nil
+      }
+      // COUNT と UNTIL は同時に使えないので、COUNT は UNTIL に置き換える
+      rule.Count = 0
+      rule.Until = until
+      series.RRule = rule.String()
+      return tx.Model(series).Update("rrule", series.RRule).Error
+}
+
+// deletePendingOccurrences は after より後の未完了の回の Todo をゴミ箱へ移動します。
+// ゴミ箱の回は作り直さないように nextOccurrence で数えるので、ここで移動する回（と既にゴミ箱にある回）は繰り返しから外します。
+func deletePendingOccurrences(tx *gorm.DB, seriesID uint, after time.Time) error {
+      var ids []uint
+      if err := tx.Model(&Todo{}).Where("series_id = ? AND occurrence_at > ?", seriesID, after).
+            Where("status_id IN (SELECT id FROM statuses WHERE NOT is_done)").
+            Pluck("id", &ids).Error; err != nil {
+            return err
+      }
+      if err := tx.Unscoped().Model(&Todo{}).Where("series_id = ? AND occurrence_at > ?", seriesID, after).
+            Where("deleted_at IS NOT NULL OR id IN ?", append(ids, 0)).
+            Update("series_id", nil).Error; err != nil {
+            return err
+      }
+      if len(ids) == 0 {
+            return nil
+      }
+      audit, err := auditTodos(tx, TodoEventDeleted, ids...)
+      if err != nil {
+            return err
+      }
+      if err := tx.Where("id IN ?", ids).Delete(&Todo{}).Error; err != nil {
+            return err
+      }
+      return audit.record(tx)
+}
+
+// RecurrenceLookahead は作成タイミングが schedule の繰り返しで、何日先の回まで作成しておくかです。
+// 環境変数 RECURRENCE_LOOKAHEAD_DAYS で変更できます。
+func RecurrenceLookahead() time.Duration {
+      return time.Duration(utils.GetEnvInt("RECURRENCE_LOOKAHEAD_DAYS", 7)) * 24 * time.Hour
+}
+
+func formatExDates(exdates []time.Time) string {
+      values := make([]string, len(exdates))
+      for i, exdate := range exdates {
+            values[i] = utils.FormatICalTime(exdate)
+      }
+      return strings.Join(values, ",")
+}
+
+func parseExDates(value string) []time.Time {
+      var exdates []time.Time
+      for _, part := range strings.Split(value, ",") {
+            if exdate, err := utils.ParseICalTime(part); err == nil {
+                  exdates = append(exdates, exdate)
+            }
+      }
+      return exdates
+}
+
+// ConvertRecurrenceToOutput は繰り返しを出力用に変換します。next には次に作成される回の日時が入ります。
+func (m *TodoModel) ConvertRecurrenceToOutput(series RecurrenceSeries) (requests.RecurrenceOutput, error) {
+      exdates := parseExDates(series.ExDates)
+      if exdates == nil {
+            exdates = []time.Time{}
+      }
+      next, err := nextOccurrence(m.DB, &series)
+      if err != nil {
+            return requests.RecurrenceOutput{}, err
+      }
+      return requests.RecurrenceOutput{
+            ID:       series.ID,
+            RRule:    series.RRule,
+            DTStart:  series.DTStart,
+            ExDates:  exdates,
+            Generate: series.Generate,
+            Next:     next,
+      }, nil
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"app/pkg/utils"
+	"app/requests"
+)
+
+// seriesOccurrences は繰り返しに属する（ゴミ箱に入っていない）回の日時を昇順で返します。
+func seriesOccurrences(t *testing.T, m *TodoModel, seriesID uint) []time.Time {
+      t.Helper()
+      var occurrences []time.Time
+      if err := m.DB.Model(&Todo{}).Where("series_id = ?", seriesID).Order("occurrence_at").Pluck("occurrence_at", &occurrences).Error; err != nil {
+            t.Fatal(err)
+      }
+      return occurrences
+}
+
+func equalTimes(a []time.Time, b []time.Time) bool {
+      if len(a) != len(b) {
+            return false
+      }
+      for i := range a {
+            if !a[i].Equal(b[i]) {
+                  return false
+            }
+      }
+      return true
+}
+
+// scope=this はこの回だけを 1 回きりの繰り返しに分け、元の繰り返しはこの回を除いて続くこと
+func TestSetTodoRecurrenceScopeThis(t *testing.T) {
+      tests := []struct {
+            generate string
+            // scope=this の後の、元の繰り返しの回。before は scope=this の前の回
+            want func(deadline time.Time, before []time.Time) []time.Time
+      }{
+            {
+                  // 未完了の回を外したので、元の繰り返しの次の回をすぐに作る
+                  generate: RecurrenceGenerateOnComplete,
+                  want: func(deadline time.Time, before []time.Time) []time.Time {
+                        return []time.Time{deadline.AddDate(0, 0, 1)}
+                  },
+            },
+            {
+                  // 作成済みの回はそのまま残り、この回だけが外れる
+                  generate: RecurrenceGenerateSchedule,
+                  want: func(deadline time.Time, before []time.Time) []time.Time {
+                        return before[1:]
+                  },
+            },
+      }
+      for _, tt := range tests {
+            t.Run(tt.generate, func(t *testing.T) {
+                  m, user := newTestModel(t)
+                  deadline := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+                  todo, err := m.CreateTodo(requests.CreateTodoInput{Title: "日報", Deadline: deadline, Email: user.Email})
+                  if err != nil {
+                        t.Fatal(err)
+                  }
+                  old, err := m.SetTodoRecurrence(todo.ID, todo.Version, requests.SetRecurrenceInput{RRule: "FREQ=DAILY", Generate: tt.generate})
+                  if err != nil {
+                        t.Fatal(err)
+                  }
+                  before := seriesOccurrences(t, m, old.ID)
+                  if len(before) == 0 || !before[0].Equal(deadline) {
+                        t.Fatalf("occurrences before the split = %v", before)
+                  }
+
+                  todo, err = m.GetTodoByID(todo.ID)
+                  if err != nil {
+                        t.Fatal(err)
+                  }
+                  split, err := m.SetTodoRecurrence(todo.ID, todo.Version, requests.SetRecurrenceInput{RRule: "FREQ=WEEKLY", Generate: tt.generate, Scope: RecurrenceScopeThis})
+                  if err != nil {
+                        t.Fatal(err)
+                  }
+                  if split.ID == old.ID {
+                        t.Fatal("scope=this changed the original series")
+                  }
+                  rule, err := utils.ParseRRule(split.RRule)
+                  if err != nil {
+                        t.Fatal(err)
+                  }
+                  if rule.Count != 1 {
+                        t.Errorf("split rrule = %s, want COUNT=1", split.RRule)
+                  }
+                  if got := seriesOccurrences(t, m, split.ID); !equalTimes(got, []time.Time{deadline}) {
+                        t.Errorf("split series occurrences = %v, want only %v", got, deadline)
+                  }
+                  output, err := m.ConvertRecurrenceToOutput(split)
+                  if err != nil {
+                        t.Fatal(err)
+                  }
+                  if output.Next != nil {
+                        t.Errorf("split series next = %v, want nil", *output.Next)
+                  }
+
+                  want := tt.want(deadline, before)
+                  if got := seriesOccurrences(t, m, old.ID); !equalTimes(got, want) {
+                        t.Errorf("original series occurrences = %v, want %v", got, want)
+                  }
+                  // 定期実行のジョブが動いても、外した回が作り直されたり、回が重複したりしないこと
+                  if _, err := m.GenerateScheduledOccurrences(time.Now().Add(RecurrenceLookahead())); err != nil {
+                        t.Fatal(err)
+                  }
+                  if got := seriesOccurrences(t, m, old.ID); !equalTimes(got, want) {
+                        t.Errorf("original series occurrences after the scheduled job = %v, want %v", got, want)
+                  }
+                  if got := seriesOccurrences(t, m, split.ID); len(got) != 1 {
+                        t.Errorf("split series occurrences after the scheduled job = %v, want one", got)
+                  }
+            })
+      }
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"app/pkg/notify"
+	"app/requests"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // ReminderChannelEmail はメールでリマインダーを送ります。送り先を省略した場合はユーザーのメールアドレスに送ります。
+      ReminderChannelEmail = "email"
+      // ReminderChannelWebhook は Webhook の URL にリマインダーを POST します。
+      ReminderChannelWebhook = "webhook"
+)
+
+// ReminderRule はユーザーごとのリマインダーの設定です。担当している（ウォッチャーとして見ているだけのものは除く）未完了の Todo の期限の BeforeMinutes 分前に通知します。
+type ReminderRule struct {
+      ID            uint      `gorm:"primary_key" json:"id"`
+      UserID        uint      `gorm:"not null;index" json:"user_id"`
+      BeforeMinutes int       `gorm:"not null" json:"before_minutes"`
+      Channel       string    `gorm:"not null" json:"channel"`
+      Target        string    `gorm:"not null;default:''" json:"target"`
+      CreatedAt     time.Time `json:"created_at"`
+}
+
+// ReminderDelivery はリマインダーを送った記録です。同じリマインダーを 2 回送らないために使います。
+// 期限が変わった場合は、新しい期限に対してもう一度送ります。
+type ReminderDelivery struct {
+      ID             uint       `gorm:"primary_key" json:"id"`
+      ReminderRuleID uint       `gorm:"not null;uniqueIndex:idx_reminder_delivery" json:"reminder_rule_id"`
+      TodoID         uint       `gorm:"not null;uniqueIndex:idx_reminder_delivery" json:"todo_id"`
+      Deadline       time.Time  `gorm:"not null;uniqueIndex:idx_reminder_delivery" json:"deadline"`
+      SentAt         *time.Time `json:"sent_at"`
+      Error          string     `gorm:"not null;default:''" json:"error"`
+      CreatedAt      time.Time  `json:"created_at"`
+}
+
+// DueReminder は送る時刻になったリマインダーです。
+type DueReminder struct {
+      ReminderRuleID uint
+      Channel        string
+      Target         string
+      BeforeMinutes  int
+      TodoID         uint
+      Title          string
+      // 保存されている期限（送信記録に使う）。通知する期限は LocalDeadline を使う
+      Deadline       time.Time
+      DeadlineAllDay bool
+      UserID         uint
+      // 受け取るユーザーのタイムゾーン
+      TimeZone       string
+}
+
+// LocalDeadline は期限を受け取るユーザーのタイムゾーンの日時にします。終日の期限は、その日付の 0 時になります。
+func (r DueReminder) LocalDeadline() time.Time {
+      loc, err := LoadTimeZone(r.TimeZone)
+      if err != nil {
+            loc, err = LoadTimeZone(DefaultTimeZone())
+      }
+      if err != nil {
+            loc = time.UTC
+      }
+      return localizeDeadline(r.Deadline, r.DeadlineAllDay, loc)
+}
+
+var (
+      // ErrInvalidReminderChannel は対応していないチャネルが指定された場合に返されます。
+      ErrInvalidReminderChannel = errors.New("channel must be email or webhook")
+      // ErrInvalidReminderTarget は送り先のメールアドレスや Webhook の URL が正しくない場合に返されます。
+      ErrInvalidReminderTarget = errors.New("target must be an email address for email, or a public http or https URL for webhook")
+)
+
+func (m *TodoModel) GetReminderRules(userID uint) ([]ReminderRule, error) {
+      var rules []ReminderRule
+      if err := m.DB.Where("user_id = ?", userID).Order("before_minutes DESC, id").Find(&rules).Error; err != nil {
+            return nil, err
+      }
+      return rules, nil
+}
+
+func (m *TodoModel) CreateReminderRule(userID uint, input requests.ReminderRuleInput) (ReminderRule, error) {
+      if err := validateReminderRule(input); err != nil {
+            return ReminderRule{}, err
+      }
+      newRule := ReminderRule{
+            UserID:        userID,
+            BeforeMinutes: input.BeforeMinutes,
+            Channel:       input.Channel,
+            Target:        input.Target,
+      }
+      if err := m.DB.Create(&newRule).Error; err != nil {
+            return ReminderRule{}, err
+      }
+      return newRule, nil
+}
+
+// UpdateReminderRule は userID のユーザーのリマインダーの設定を変更します。他のユーザーの設定は ErrRecordNotFound になります。
+func (m *TodoModel) UpdateReminderRule(userID uint, id uint, input requests.ReminderRuleInput) (ReminderRule, error) {
+      if err := validateReminderRule(input); err != nil {
+            return ReminderRule{}, err
+      }
+      var rule ReminderRule
+      if err := m.DB.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error; err != nil {
+            return ReminderRule{}, err
+      }
+      rule.BeforeMinutes = input.BeforeMinutes
+      rule.Channel = input.Channel
+      rule.Target = input.Target
+      if err := m.DB.Save(&rule).Error; err != nil {
+            return ReminderRule{}, err
+      }
+      return rule, nil
+}
+
+func (m *TodoModel) DeleteReminderRule(userID uint, id uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            result := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&ReminderRule{})
+            if result.Error != nil {
+                  return result.Error
+            }
+            if result.RowsAffected == 0 {
+                  return gorm.ErrRecordNotFound
+            }
+            return tx.Where("reminder_rule_id = ?", id).Delete(&ReminderDelivery{}).Error
+      })
+}
+
+// GetDueReminders は now の時点で送る時刻になった、まだ送っていないリマインダーを返します。
+// サーバーが止まっていたなどで maxDelay より前に送るはずだったリマインダーは、今さら送っても意味が無いので返しません。
+// 終日の期限は、受け取るユーザーのタイムゾーンでのその日付の 0 時を期限として送る時刻を求めます。
+func (m *TodoModel) GetDueReminders(now time.Time, maxDelay time.Duration) ([]DueReminder, error) {
+      var reminders []DueReminder
+      err := m.DB.Raw(`SELECT * FROM (
+                  SELECT r.id AS reminder_rule_id, r.channel, r.before_minutes,
+                        t.id AS todo_id, t.title, t.deadline, t.deadline_all_day, u.id AS user_id, zone.name AS time_zone,
+                        CASE WHEN r.channel = ? AND r.target = '' THEN u.email ELSE r.target END AS target,
+                        CASE WHEN t.deadline_all_day
+                              THEN (t.deadline AT TIME ZONE 'UTC')::date::timestamp AT TIME ZONE zone.name
+                              ELSE t.deadline
+                        END - r.before_minutes * INTERVAL '1 minute' AS remind_at
+                  FROM reminder_rules r
+                  JOIN users u ON u.id = r.user_id
+                  -- Postgres が知らないタイムゾーンでクエリ全体が失敗しないよう、デフォルトのタイムゾーンにする
+                  CROSS JOIN LATERAL (
+                        SELECT COALESCE((SELECT name FROM pg_timezone_names WHERE name = u.time_zone), ?) AS name
+                  ) zone
+                  JOIN user_todos ut ON ut.user_id = r.user_id AND ut.role <> ?
+                  JOIN todos t ON t.id = ut.todo_id AND t.deleted_at IS NULL
+                  JOIN workspace_members wm ON wm.workspace_id = t.workspace_id AND wm.user_id = r.user_id
+                  JOIN statuses s ON s.id = t.status_id AND NOT s.is_done
+                  WHERE NOT EXISTS (
+                        SELECT 1 FROM reminder_deliveries d
+                        WHERE d.reminder_rule_id = r.id AND d.todo_id = t.id AND d.deadline = t.deadline
+                  )
+            ) due
+            WHERE due.remind_at <= ? AND due.remind_at > ?
+            ORDER BY due.remind_at, due.reminder_rule_id`, ReminderChannelEmail, DefaultTimeZone(), AssigneeRoleWatcher, now, now.Add(-maxDelay)).Scan(&reminders).Error
+      if err != nil {
+            return nil, err
+      }
+      return reminders, nil
+}
+
+// ClaimReminder はリマインダーを送る前に送信記録を作成します。既に記録がある（他で送った）場合は false を返します。
+func (m *TodoModel) ClaimReminder(reminder DueReminder) (uint, bool, error) {
+      delivery := ReminderDelivery{
+            ReminderRuleID: reminder.ReminderRuleID,
+            TodoID:         reminder.TodoID,
+            Deadline:       reminder.Deadline,
+      }
+      result := m.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery)
+      if result.Error != nil {
+            return 0, false, result.Error
+      }
+      return delivery.ID, result.RowsAffected > 0, nil
+}
+
+// FinishReminder は送信の結果を記録します。失敗した場合は再送せず、エラーを記録します。
+func (m *TodoModel) FinishReminder(deliveryID uint, sendErr error) error {
+      updates := map[string]interface{}{}
+      if sendErr != nil {
+            updates["error"] = sendErr.Error()
+      } else {
+            updates["sent_at"] = time.Now()
+      }
+      return m.DB.Model(&ReminderDelivery{}).Where("id = ?", deliveryID).Updates(updates).Error
+}
+
+func validateReminderRule(input requests.ReminderRuleInput) error {
+      switch input.Channel {
+      case ReminderChannelEmail:
+            if input.Target == "" {
+                  return nil
+            }
+            // 「名前 <アドレス>」の形式は受け付けず、アドレスだけにする
+            address, err := mail.ParseAddress(input.Target)
+            if err != nil || address.Address != input.Target {
+                  return ErrInvalidReminderTarget
+            }
+            return nil
+      case ReminderChannelWebhook:
+            target, err := url.Parse(input.Target)
+            if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+                  return ErrInvalidReminderTarget
+            }
+            // 送るときにも接続先のアドレスを確認する（notify.WebhookChannel を参照）
+            if err := notify.CheckWebhookTarget(context.Background(), target, notify.WebhookAllowedHosts()); err != nil {
+                  return fmt.Errorf("%w: %v", ErrInvalidReminderTarget, err)
+            }
+            return nil
+      default:
+            return ErrInvalidReminderChannel
+      }
+}
+
+func (m *TodoModel) ConvertReminderRuleToOutput(rule ReminderRule) requests.ReminderRuleOutput {
+      return requests.ReminderRuleOutput{
+            ID:            rule.ID,
+            BeforeMinutes: rule.BeforeMinutes,
+            Channel:       rule.Channel,
+            Target:        rule.Target,
+      }
+}
+
+func (m *TodoModel) ConvertReminderRulesToOutput(rules []ReminderRule) []requests.ReminderRuleOutput {
+      output := []requests.ReminderRuleOutput{}
+      for _, rule := range rules {
+            output = append(output, m.ConvertReminderRuleToOutput(rule))
+      }
+      return output
+}
@@ -0,0 +1,401 @@
+package models
+
+import (
+	"app/requests"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Status は Todo の進捗状態（backlog → in progress → review → done など）を表します。
+type Status struct {
+      ID       uint   `gorm:"primary_key" json:"id"`
+      // 属するワークスペース（workspace.go を参照）
+      WorkspaceID uint `gorm:"not null;default:0;index" json:"workspace_id"`
+      Name     string `gorm:"not null" json:"name"`
+      Color    string `json:"color"`
+      Position int    `gorm:"not null;default:0" json:"position"`
+      // IsDefault が true のステータスが、新しく作成された Todo に設定されます。
+      IsDefault bool `gorm:"not null;default:false" json:"is_default"`
+      // IsDone が true のステータスにある Todo は完了扱いになります。
+      IsDone bool `gorm:"not null;default:false" json:"is_done"`
+      // このステータスから遷移できるステータス。1つも設定されていない場合はどのステータスにも遷移できます。
+      Transitions []*Status `gorm:"many2many:status_transitions;joinForeignKey:FromStatusID;joinReferences:ToStatusID" json:"-"`
+}
+
+// TodoStatusHistory は Todo がいつどのステータスに入ったかの履歴です。
+type TodoStatusHistory struct {
+      ID        uint       `gorm:"primary_key" json:"id"`
+      TodoID    uint       `gorm:"not null;index" json:"todo_id"`
+      StatusID  uint       `gorm:"not null" json:"status_id"`
+      Status    Status     `json:"status"`
+      EnteredAt time.Time  `gorm:"not null" json:"entered_at"`
+      LeftAt    *time.Time `json:"left_at"`
+}
+
+var (
+      // ErrInvalidTransition は許可されていないステータス遷移を行おうとした場合に返されます。
+      ErrInvalidTransition = errors.New("status transition is not allowed")
+      // ErrStatusInUse は Todo が使用中のステータスを削除しようとした場合に返されます。
+      ErrStatusInUse = errors.New("status is used by todos")
+      // ErrStatusNameTaken は同じ名前のステータスが既に存在する場合に返されます。
+      ErrStatusNameTaken = errors.New("status name is already taken")
+      // ErrDefaultStatusRequired はデフォルトのステータスのデフォルトを外そうとした場合に返されます。
+      ErrDefaultStatusRequired = errors.New("set another status as default instead")
+      // ErrDefaultStatusDelete はデフォルトのステータスを削除しようとした場合に返されます。
+      ErrDefaultStatusDelete = errors.New("default status cannot be deleted")
+)
+
+// defaultStatuses は初回起動時に作成されるステータスです。
+var defaultStatuses = []Status{
+      {Name: "backlog", Color: "#9e9e9e", Position: 0, IsDefault: true},
+      {Name: "in progress", Color: "#2196f3", Position: 1},
+      {Name: "review", Color: "#ff9800", Position: 2},
+      {Name: "done", Color: "#4caf50", Position: 3, IsDone: true},
+}
+
+// defaultTransitions は defaultStatuses 間で許可する遷移です（Position で指定）。
+var defaultTransitions = map[int][]int{
+      0: {1},
+      1: {0, 2},
+      2: {1, 3},
+      3: {0},
+}
+
+// EnsureDefaultStatuses はワークスペースにステータスが1つも無い場合に、デフォルトのステータスと遷移を作成します。
+func EnsureDefaultStatuses(db *gorm.DB, workspaceID uint) error {
+      var count int64
+      if err := db.Model(&Status{}).Where("workspace_id = ?", workspaceID).Count(&count).Error; err != nil {
+            return err
+      }
+      if count > 0 {
+            return nil
+      }
+
+      return db.Transaction(func(tx *gorm.DB) error {
+            statuses := make([]Status, len(defaultStatuses))
+            copy(statuses, defaultStatuses)
+            for i := range statuses {
+                  statuses[i].WorkspaceID = workspaceID
+            }
+            if err := tx.Create(&statuses).Error; err != nil {
+                  return err
+            }
+            for from, tos := range defaultTransitions {
+                  var targets []*Status
+                  for _, to := range tos {
+                        targets = append(targets, &statuses[to])
+                  }
+                  if err := tx.Model(&statuses[from]).Association("Transitions").Append(targets); err != nil {
+                        return err
+                  }
+            }
+            return nil
+      })
+}
+
+// GetDefaultStatus は新しい Todo に設定するステータスを返します。
+func GetDefaultStatus(db *gorm.DB) (Status, error) {
+      var status Status
+      if err := db.Where("is_default = ?", true).Order("position").First(&status).Error; err != nil {
+            return Status{}, err
+      }
+      return status, nil
+}
+
+func (m *TodoModel) GetStatuses() ([]Status, error) {
+      var statuses []Status
+      if err := m.DB.Preload("Transitions").Order("position, id").Find(&statuses).Error; err != nil {
+            return nil, err
+      }
+      return statuses, nil
+}
+
+func (m *TodoModel) GetStatusByID(id uint) (Status, error) {
+      var status Status
+      if err := m.DB.Preload("Transitions").Where("id = ?", id).First(&status).Error; err != nil {
+            return Status{}, err
+      }
+      return status, nil
+}
+
+func (m *TodoModel) CreateStatus(input requests.CreateStatusInput) (Status, error) {
+      newStatus := Status{
+            Name:      input.Name,
+            Color:     input.Color,
+            Position:  input.Position,
+            IsDefault: input.IsDefault,
+            IsDone:    input.IsDone,
+      }
+
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := checkStatusName(tx, 0, newStatus.Name); err != nil {
+                  return err
+            }
+            // デフォルトのステータスは常に1つだけにする
+            if newStatus.IsDefault {
+                  if err := tx.Model(&Status{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+                        return err
+                  }
+            }
+            return tx.Create(&newStatus).Error
+      })
+      if err != nil {
+            return Status{}, err
+      }
+      return newStatus, nil
+}
+
+func (m *TodoModel) UpdateStatus(id uint, input requests.UpdateStatusInput) (Status, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var status Status
+            if err := tx.Where("id = ?", id).First(&status).Error; err != nil {
+                  return err
+            }
+
+            updates := map[string]interface{}{}
+            if input.Name != "" {
+                  if err := checkStatusName(tx, id, input.Name); err != nil {
+                        return err
+                  }
+                  updates["name"] = input.Name
+            }
+            if input.Color != "" {
+                  updates["color"] = input.Color
+            }
+            if input.Position != nil {
+                  updates["position"] = *input.Position
+            }
+            if input.IsDone != nil {
+                  updates["is_done"] = *input.IsDone
+            }
+            if input.IsDefault != nil {
+                  // デフォルトを外すことはできない（別のステータスをデフォルトにする）
+                  if !*input.IsDefault && status.IsDefault {
+                        return ErrDefaultStatusRequired
+                  }
+                  if *input.IsDefault {
+                        if err := tx.Model(&Status{}).Where("is_default = ? AND id <> ?", true, id).Update("is_default", false).Error; err != nil {
+                              return err
+                        }
+                  }
+                  updates["is_default"] = *input.IsDefault
+            }
+            if len(updates) == 0 {
+                  return nil
+            }
+            return tx.Model(&status).Updates(updates).Error
+      })
+      if err != nil {
+            return Status{}, err
+      }
+      return m.GetStatusByID(id)
+}
+
+// DeleteStatus はステータスを削除します。Todo が使用中のステータスやデフォルトのステータスは削除できません。
+func (m *TodoModel) DeleteStatus(id uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            var status Status
+            if err := tx.Where("id = ?", id).First(&status).Error; err != nil {
+                  return err
+            }
+            if status.IsDefault {
+                  return ErrDefaultStatusDelete
+            }
+
+            // ゴミ箱の Todo も含めて使用中か確認する
+            var count int64
+            if err := tx.Unscoped().Model(&Todo{}).Where("status_id = ?", id).Count(&count).Error; err != nil {
+                  return err
+            }
+            if count > 0 {
+                  return ErrStatusInUse
+            }
+
+            if err := tx.Exec("DELETE FROM status_transitions WHERE from_status_id = ? OR to_status_id = ?", id, id).Error; err != nil {
+                  return err
+            }
+            if err := tx.Where("status_id = ?", id).Delete(&TodoStatusHistory{}).Error; err != nil {
+                  return err
+            }
+            return tx.Delete(&status).Error
+      })
+}
+
+// SetStatusTransitions はステータスから遷移できるステータスを置き換えます。
+func (m *TodoModel) SetStatusTransitions(id uint, toIDs []uint) (Status, error) {
+      status, err := m.GetStatusByID(id)
+      if err != nil {
+            return Status{}, err
+      }
+
+      var targets []*Status
+      if len(toIDs) > 0 {
+            if err := m.DB.Where("id IN ?", toIDs).Find(&targets).Error; err != nil {
+                  return Status{}, err
+            }
+            if len(targets) != len(uniqueIDs(toIDs)) {
+                  return Status{}, gorm.ErrRecordNotFound
+            }
+      }
+      if err := m.DB.Model(&status).Association("Transitions").Replace(targets); err != nil {
+            return Status{}, err
+      }
+      return m.GetStatusByID(id)
+}
+
+// ChangeTodoStatus は version が一致する場合のみ Todo のステータスを変更します。
+func (m *TodoModel) ChangeTodoStatus(id uint, version uint, statusID uint) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var todo Todo
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&todo).Error; err != nil {
+                  return err
+            }
+            if todo.Version != version {
+                  return ErrVersionMismatch
+            }
+            audit, err := auditTodos(tx, TodoEventStatusChanged, id)
+            if err != nil {
+                  return err
+            }
+            if err := changeTodoStatus(tx, &todo, statusID, time.Now()); err != nil {
+                  return err
+            }
+            if err := tx.Model(&Todo{}).Where("id = ?", id).Update("version", gorm.Expr("version + 1")).Error; err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(id)
+}
+
+// GetTodoStatusHistory は Todo のステータス履歴を古い順に返します。
+func (m *TodoModel) GetTodoStatusHistory(todoID uint) ([]TodoStatusHistory, error) {
+      if _, err := m.GetTodoByID(todoID); err != nil {
+            return nil, err
+      }
+      var histories []TodoStatusHistory
+      if err := m.DB.Preload("Status").Where("todo_id = ?", todoID).Order("entered_at, id").Find(&histories).Error; err != nil {
+            return nil, err
+      }
+      return histories, nil
+}
+
+// canTransition は from から to への遷移が許可されているかを返します。
+func canTransition(tx *gorm.DB, from uint, to uint) (bool, error) {
+      if from == to {
+            return true, nil
+      }
+      var total int64
+      if err := tx.Table("status_transitions").Where("from_status_id = ?", from).Count(&total).Error; err != nil {
+            return false, err
+      }
+      // 遷移先が1つも設定されていないステータスからは、どこへでも遷移できる
+      if total == 0 {
+            return true, nil
+      }
+      var count int64
+      if err := tx.Table("status_transitions").Where("from_status_id = ? AND to_status_id = ?", from, to).Count(&count).Error; err != nil {
+            return false, err
+      }
+      return count > 0, nil
+}
+
+// changeTodoStatus は遷移を検証した上で Todo のステータスを変更し、履歴を記録します。
+// version の更新は呼び出し元で行います。
+func changeTodoStatus(tx *gorm.DB, todo *Todo, statusID uint, now time.Time) error {
+      if todo.StatusID == statusID {
+            return nil
+      }
+
+      var previous Status
+      if err := tx.Where("id = ?", todo.StatusID).First(&previous).Error; err != nil {
+            return err
+      }
+      var status Status
+      if err := tx.Where("id = ?", statusID).First(&status).Error; err != nil {
+            return err
+      }
+      ok, err := canTransition(tx, todo.StatusID, statusID)
+      if err != nil {
+            return err
+      }
+      if !ok {
+            return ErrInvalidTransition
+      }
+
+      if err := tx.Model(&Todo{}).Where("id = ?", todo.ID).Updates(map[string]interface{}{
+            "status_id":         statusID,
+            "status_changed_at": now,
+      }).Error; err != nil {
+            return err
+      }
+      if err := tx.Model(&TodoStatusHistory{}).Where("todo_id = ? AND left_at IS NULL", todo.ID).Update("left_at", now).Error; err != nil {
+            return err
+      }
+      if err := tx.Create(&TodoStatusHistory{TodoID: todo.ID, StatusID: statusID, EnteredAt: now}).Error; err != nil {
+            return err
+      }
+
+      todo.StatusID = statusID
+      todo.Status = status
+      todo.StatusChangedAt = &now
+
+      // 繰り返しの Todo が完了したら次回の Todo を作成する
+      if !previous.IsDone && status.IsDone {
+            return onTodoCompleted(tx, todo)
+      }
+      return nil
+}
+
+func checkStatusName(tx *gorm.DB, id uint, name string) error {
+      var count int64
+      if err := tx.Model(&Status{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, id).Count(&count).Error; err != nil {
+            return err
+      }
+      if count > 0 {
+            return ErrStatusNameTaken
+      }
+      return nil
+}
+
+func uniqueIDs(ids []uint) []uint {
+      seen := map[uint]bool{}
+      var result []uint
+      for _, id := range ids {
+            if !seen[id] {
+                  seen[id] = true
+                  result = append(result, id)
+            }
+      }
+      return result
+}
+
+func (m *TodoModel) ConvertStatusToOutput(status Status) requests.StatusOutput {
+      transitionIDs := []uint{}
+      for _, transition := range status.Transitions {
+            transitionIDs = append(transitionIDs, transition.ID)
+      }
+      return requests.StatusOutput{
+            ID:            status.ID,
+            Name:          status.Name,
+            Color:         status.Color,
+            Position:      status.Position,
+            IsDefault:     status.IsDefault,
+            IsDone:        status.IsDone,
+            TransitionIDs: transitionIDs,
+      }
+}
+
+func (m *TodoModel) ConvertStatusesToOutput(statuses []Status) []requests.StatusOutput {
+      var output []requests.StatusOutput
+      for _, status := range statuses {
+            output = append(output, m.ConvertStatusToOutput(status))
+      }
+      return output
+}
@@ -0,0 +1,282 @@
+package models
+
+import (
+	"app/requests"
+	"errors"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // SubtaskDeleteOrphan は親を削除したとき、子タスクを親の無い Todo として残します。
+      SubtaskDeleteOrphan = "orphan"
+      // SubtaskDeleteCascade は親を削除したとき、子孫のタスクもまとめてゴミ箱へ移動します。
+      SubtaskDeleteCascade = "cascade"
+      // SubtaskDeleteBlock は子タスクがある Todo の削除を拒否します。
+      SubtaskDeleteBlock = "block"
+)
+
+// hierarchyLockKey は親子関係を変更するときに取る advisory lock のキーです。
+// 同時に付け替えが行われて循環ができてしまうのを防ぎます。
+const hierarchyLockKey = 30030
+
+var (
+      // ErrTodoCycle は親子関係が循環してしまう付け替えを行おうとした場合に返されます。
+      ErrTodoCycle = errors.New("todo cannot be a descendant of itself")
+      // ErrHasChildren は削除方法が block のときに、子タスクがある Todo を削除しようとした場合に返されます。
+      ErrHasChildren = errors.New("todo has subtasks")
+      // ErrInvalidDeleteMode は対応していない削除方法が指定された場合に返されます。
+      ErrInvalidDeleteMode = errors.New("delete mode must be orphan, cascade or block")
+)
+
+// TodoProgress は子孫のタスクのうち、完了しているものの割合です。
+type TodoProgress struct {
+      Done  int
+      Total int
+}
+
+// DefaultSubtaskDeleteMode は削除方法が指定されなかったときに使う削除方法です。
+// 環境変数 SUBTASK_DELETE_MODE で変更できます。
+func DefaultSubtaskDeleteMode() string {
+      if mode := os.Getenv("SUBTASK_DELETE_MODE"); mode != "" {
+            return mode
+      }
+      return SubtaskDeleteBlock
+}
+
+// GetChildTodos は直下の子タスクを返します。
+func (m *TodoModel) GetChildTodos(id uint) ([]Todo, error) {
+      if _, err := m.GetTodoByID(id); err != nil {
+            return nil, err
+      }
+      var todos []Todo
+      if err := preloadTodo(m.DB).Where("parent_id = ?", id).Order(rankOrder).Order("id").Find(&todos).Error; err != nil {
+            return nil, err
+      }
+      if err := attachComputed(m.DB, todos); err != nil {
+            return nil, err
+      }
+      return todos, nil
+}
+
+// GetTodoTree は id の Todo と、その子孫すべてを返します。子孫は再帰 CTE で 1 回のクエリで求めます。
+func (m *TodoModel) GetTodoTree(id uint) (Todo, []Todo, error) {
+      root, err := m.GetTodoByID(id)
+      if err != nil {
+            return Todo{}, nil, err
+      }
+
+      var ids []uint
+      if err := m.DB.Raw(`WITH RECURSIVE descendants AS (
+            SELECT id FROM todos WHERE parent_id = ? AND deleted_at IS NULL
+            UNION
+            SELECT t.id FROM todos t JOIN descendants d ON t.parent_id = d.id WHERE t.deleted_at IS NULL
+      ) SELECT id FROM descendants`, id).Scan(&ids).Error; err != nil {
+            return Todo{}, nil, err
+      }
+
+      var descendants []Todo
+      if len(ids) > 0 {
+            if err := preloadTodo(m.DB).Where("id IN ?", ids).Order(rankOrder).Order("id").Find(&descendants).Error; err != nil {
+                  return Todo{}, nil, err
+            }
+            if err := attachComputed(m.DB, descendants); err != nil {
+                  return Todo{}, nil, err
+            }
+      }
+      return root, descendants, nil
+}
+
+// SetTodoParent は version が一致する場合のみ Todo の親を付け替えます。parentID が nil の場合は親を外します。
+func (m *TodoModel) SetTodoParent(id uint, version uint, parentID *uint) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", hierarchyLockKey).Error; err != nil {
+                  return err
+            }
+
+            var todo Todo
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&todo).Error; err != nil {
+                  return err
+            }
+            if todo.Version != version {
+                  return ErrVersionMismatch
+            }
+            if parentID != nil {
+                  if err := checkParent(tx, id, *parentID); err != nil {
+                        return err
+                  }
+            }
+
+            audit, err := auditTodos(tx, TodoEventParentChanged, id)
+            if err != nil {
+                  return err
+            }
+            if err := tx.Model(&Todo{}).Where("id = ?", id).Updates(map[string]interface{}{
+                  "parent_id": parentID,
+                  "version":   gorm.Expr("version + 1"),
+            }).Error; err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(id)
+}
+
+// checkParent は parentID の Todo が存在し、id の Todo の子孫ではないことを確認します。
+func checkParent(tx *gorm.DB, id uint, parentID uint) error {
+      if _, err := (&TodoModel{DB: tx}).GetTodoByID(parentID); err != nil {
+            return err
+      }
+      if id == 0 {
+            return nil
+      }
+      if id == parentID {
+            return ErrTodoCycle
+      }
+
+      // 新しい親の祖先をたどって、自分自身が含まれていないか確認する
+      var count int64
+      if err := tx.Raw(`WITH RECURSIVE ancestors AS (
+            SELECT id, parent_id FROM todos WHERE id = ?
+            UNION
+            SELECT t.id, t.parent_id FROM todos t JOIN ancestors a ON t.id = a.parent_id
+      ) SELECT COUNT(*) FROM ancestors WHERE id = ?`, parentID, id).Scan(&count).Error; err != nil {
+            return err
+      }
+      if count > 0 {
+            return ErrTodoCycle
+      }
+      return nil
+}
+
+// deleteSubtasks は親の Todo を削除する前に、mode に従って子タスクを処理します。
+func deleteSubtasks(tx *gorm.DB, id uint, mode string) error {
+      switch mode {
+      case SubtaskDeleteOrphan:
+            return tx.Model(&Todo{}).Where("parent_id = ?", id).Update("parent_id", nil).Error
+      case SubtaskDeleteCascade:
+            ids, err := liveDescendantIDs(tx, id)
+            if err != nil {
+                  return err
+            }
+            if len(ids) == 0 {
+                  return nil
+            }
+            return tx.Where("id IN ?", ids).Delete(&Todo{}).Error
+      case SubtaskDeleteBlock:
+            var count int64
+            if err := tx.Model(&Todo{}).Where("parent_id = ?", id).Count(&count).Error; err != nil {
+                  return err
+            }
+            if count > 0 {
+                  return ErrHasChildren
+            }
+            return nil
+      default:
+            return ErrInvalidDeleteMode
+      }
+}
+
+// liveDescendantIDs はゴミ箱に入っていない子孫タスクの ID を返します。
+func liveDescendantIDs(tx *gorm.DB, id uint) ([]uint, error) {
+      var ids []uint
+      if err := tx.Raw(`WITH RECURSIVE descendants AS (
+            SELECT id FROM todos WHERE parent_id = ? AND deleted_at IS NULL
+            UNION
+            SELECT t.id FROM todos t JOIN descendants d ON t.parent_id = d.id WHERE t.deleted_at IS NULL
+      ) SELECT id FROM descendants`, id).Scan(&ids).Error; err != nil {
+            return nil, err
+      }
+      return ids, nil
+}
+
+// trashedDescendantIDs は id の Todo と同じ削除日時（deletedAt）にゴミ箱へ移動した子孫タスクの ID を返します。
+// 親と一緒に cascade で削除した子孫タスクです。
+func trashedDescendantIDs(tx *gorm.DB, id uint, deletedAt time.Time) ([]uint, error) {
+      var ids []uint
+      if err := tx.Raw(`WITH RECURSIVE descendants AS (
+            SELECT id FROM todos WHERE parent_id = ? AND deleted_at = ?
+            UNION
+            SELECT t.id FROM todos t JOIN descendants d ON t.parent_id = d.id WHERE t.deleted_at = ?
+      ) SELECT id FROM descendants`, id, deletedAt, deletedAt).Scan(&ids).Error; err != nil {
+            return nil, err
+      }
+      return ids, nil
+}
+
+// attachProgress は todos それぞれの子孫タスクの完了状況を求めて Progress に設定します。
+// 子孫の数に関係なく 1 回のクエリで求めます。
+func attachProgress(db *gorm.DB, todos []Todo) error {
+      if len(todos) == 0 {
+            return nil
+      }
+      ids := make([]uint, len(todos))
+      for i, todo := range todos {
+            ids[i] = todo.ID
+      }
+
+      var rows []struct {
+            RootID uint
+            Total  int
+            Done   int
+      }
+      if err := db.Raw(`WITH RECURSIVE descendants AS (
+            SELECT parent_id AS root_id, id, status_id FROM todos WHERE parent_id IN ? AND deleted_at IS NULL
+            UNION
+            SELECT d.root_id, t.id, t.status_id FROM todos t JOIN descendants d ON t.parent_id = d.id WHERE t.deleted_at IS NULL
+      )
+      SELECT d.root_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE s.is_done) AS done
+      FROM descendants d JOIN statuses s ON s.id = d.status_id
+      GROUP BY d.root_id`, ids).Scan(&rows).Error; err != nil {
+            return err
+      }
+
+      progress := map[uint]*TodoProgress{}
+      for _, row := range rows {
+            progress[row.RootID] = &TodoProgress{Done: row.Done, Total: row.Total}
+      }
+      for i := range todos {
+            todos[i].Progress = progress[todos[i].ID]
+      }
+      return nil
+}
+
+// ConvertTodoTreeToOutput は GetTodoTree の結果を入れ子の形に変換します。
+func (m *TodoModel) ConvertTodoTreeToOutput(root Todo, descendants []Todo) requests.TodoTreeOutput {
+      children := map[uint][]Todo{}
+      for _, todo := range descendants {
+            if todo.ParentID != nil {
+                  children[*todo.ParentID] = append(children[*todo.ParentID], todo)
+            }
+      }
+
+      var build func(todo Todo) requests.TodoTreeOutput
+      build = func(todo Todo) requests.TodoTreeOutput {
+            output := requests.TodoTreeOutput{
+                  GetTodoOutput: m.ConvertTodoToOutput(todo),
+                  Children:      []requests.TodoTreeOutput{},
+            }
+            for _, child := range children[todo.ID] {
+                  output.Children = append(output.Children, build(child))
+            }
+            return output
+      }
+      return build(root)
+}
+
+func convertProgressToOutput(progress *TodoProgress) *requests.ProgressOutput {
+      if progress == nil || progress.Total == 0 {
+            return nil
+      }
+      return &requests.ProgressOutput{
+            Done:    progress.Done,
+            Total:   progress.Total,
+            Percent: progress.Done * 100 / progress.Total,
+      }
+}
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"app/requests"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tag は Todo に付けるタグです。1 つの Todo に複数のタグを付けられます（todo_tags 中間テーブル）。
+type Tag struct {
+      ID        uint      `gorm:"primary_key" json:"id"`
+      // 属するワークスペース（workspace.go を参照）
+      WorkspaceID uint `gorm:"not null;default:0;index" json:"workspace_id"`
+      Name      string    `gorm:"not null" json:"name"`
+      Color     string    `json:"color"`
+      CreatedAt time.Time `json:"created_at"`
+}
+
+// TodoTag は Todo とタグの関係（todo_tags 中間テーブル）です。
+// Rank はタグごとの列のボードでの並び順です（board.go を参照）。タグを付けたばかりで未設定の場合は列の末尾に並びます。
+type TodoTag struct {
+      TodoID uint   `gorm:"primaryKey;autoIncrement:false"`
+      TagID  uint   `gorm:"primaryKey;autoIncrement:false;index"`
+      Rank   string `gorm:"not null;default:''"`
+}
+
+const (
+      // TagMatchAny はいずれかのタグが付いている Todo に絞り込みます。
+      TagMatchAny = "any"
+      // TagMatchAll は指定したタグがすべて付いている Todo に絞り込みます。
+      TagMatchAll = "all"
+)
+
+var (
+      // ErrTagNameTaken は同じ名前（大文字・小文字は区別しない）のタグが既に存在する場合に返されます。
+      ErrTagNameTaken = errors.New("tag name is already taken")
+      // ErrInvalidTagMatch は対応していない tag_match が指定された場合に返されます。
+      ErrInvalidTagMatch = errors.New("tag_match must be any or all")
+)
+
+func (m *TodoModel) GetTags() ([]Tag, error) {
+      var tags []Tag
+      if err := m.DB.Order("name, id").Find(&tags).Error; err != nil {
+            return nil, err
+      }
+      return tags, nil
+}
+
+func (m *TodoModel) GetTagByID(id uint) (Tag, error) {
+      var tag Tag
+      if err := m.DB.Where("id = ?", id).First(&tag).Error; err != nil {
+            return Tag{}, err
+      }
+      return tag, nil
+}
+
+func (m *TodoModel) CreateTag(input requests.CreateTagInput) (Tag, error) {
+      newTag := Tag{
+            Name:  strings.TrimSpace(input.Name),
+            Color: input.Color,
+      }
+
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := checkTagName(tx, 0, newTag.Name); err != nil {
+                  return err
+            }
+            return tx.Create(&newTag).Error
+      })
+      if err != nil {
+            return Tag{}, err
+      }
+      return newTag, nil
+}
+
+// UpdateTag はタグの名前（リネーム）や色を変更します。
+func (m *TodoModel) UpdateTag(id uint, input requests.UpdateTagInput) (Tag, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var tag Tag
+            if err := tx.Where("id = ?", id).First(&tag).Error; err != nil {
+                  return err
+            }
+
+            updates := map[string]interface{}{}
+            if name := strings.TrimSpace(input.Name); name != "" {
+                  if err := checkTagName(tx, id, name); err != nil {
+                        return err
+                  }
+                  updates["name"] = name
+            }
+            if input.Color != "" {
+                  updates["color"] = input.Color
+            }
+            if len(updates) == 0 {
+                  return nil
+            }
+            return tx.Model(&tag).Updates(updates).Error
+      })
+      if err != nil {
+            return Tag{}, err
+      }
+      return m.GetTagByID(id)
+}
+
+// DeleteTag はタグを削除します。Todo からも外れます。
+func (m *TodoModel) DeleteTag(id uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            var tag Tag
+            if err := tx.Where("id = ?", id).First(&tag).Error; err != nil {
+                  return err
+            }
+            var todoIDs []uint
+            if err := tx.Table("todo_tags").Where("tag_id = ?", id).Pluck("todo_id", &todoIDs).Error; err != nil {
+                  return err
+            }
+            audit, err := auditTodos(tx, TodoEventTagsChanged, todoIDs...)
+            if err != nil {
+                  return err
+            }
+            if err := tx.Exec("DELETE FROM todo_tags WHERE tag_id = ?", id).Error; err != nil {
+                  return err
+            }
+            if len(todoIDs) > 0 {
+                  if err := tx.Exec("UPDATE todos SET version = version + 1 WHERE id IN ?", todoIDs).Error; err != nil {
+                        return err
+                  }
+            }
+            if err := tx.Delete(&tag).Error; err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+}
+
+// MergeTags は sourceIDs のタグを id のタグにまとめます。
+// sourceIDs のタグが付いていた Todo には id のタグが付き、sourceIDs のタグは削除されます。
+func (m *TodoModel) MergeTags(id uint, sourceIDs []uint) (Tag, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var target Tag
+            if err := tx.Where("id = ?", id).First(&target).Error; err != nil {
+                  return err
+            }
+
+            var sources []Tag
+            for _, sourceID := range uniqueIDs(sourceIDs) {
+                  if sourceID != id {
+                        sources = append(sources, Tag{ID: sourceID})
+                  }
+            }
+            if len(sources) == 0 {
+                  return nil
+            }
+            ids := make([]uint, len(sources))
+            for i, source := range sources {
+                  ids[i] = source.ID
+            }
+            var count int64
+            if err := tx.Model(&Tag{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+                  return err
+            }
+            if int(count) != len(ids) {
+                  return gorm.ErrRecordNotFound
+            }
+
+            var todoIDs []uint
+            if err := tx.Table("todo_tags").Where("tag_id IN ?", ids).Pluck("todo_id", &todoIDs).Error; err != nil {
+                  return err
+            }
+            audit, err := auditTodos(tx, TodoEventTagsChanged, todoIDs...)
+            if err != nil {
+                  return err
+            }
+
+            // 既に target が付いている Todo に重複して付けないようにする
+            if err := tx.Exec(`INSERT INTO todo_tags (todo_id, tag_id)
+                  SELECT DISTINCT todo_id, ? FROM todo_tags WHERE tag_id IN ?
+                  ON CONFLICT DO NOTHING`, id, ids).Error; err != nil {
+                  return err
+            }
+            if err := tx.Exec("DELETE FROM todo_tags WHERE tag_id IN ?", ids).Error; err != nil {
+                  return err
+            }
+            if err := tx.Exec("UPDATE todos SET version = version + 1 WHERE id IN (SELECT todo_id FROM todo_tags WHERE tag_id = ?)", id).Error; err != nil {
+                  return err
+            }
+            if err := tx.Where("id IN ?", ids).Delete(&Tag{}).Error; err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Tag{}, err
+      }
+      return m.GetTagByID(id)
+}
+
+// SetTodoTags は version が一致する場合のみ、Todo に付いているタグを tagIDs に置き換えます。
+func (m *TodoModel) SetTodoTags(id uint, version uint, tagIDs []uint) (Todo, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            result := tx.Model(&Todo{}).Where("id = ? AND version = ?", id, version).Update("version", gorm.Expr("version + 1"))
+            if result.Error != nil {
+                  return result.Error
+            }
+            if result.RowsAffected == 0 {
+                  if err := tx.Select("id").First(&Todo{}, id).Error; err != nil {
+                        return err
+                  }
+                  return ErrVersionMismatch
+            }
+            audit, err := auditTodos(tx, TodoEventTagsChanged, id)
+            if err != nil {
+                  return err
+            }
+            if err := replaceTodoTags(tx, id, tagIDs); err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(id)
+}
+
+// replaceTodoTags は Todo に付いているタグを tagIDs に置き換えます。存在しないタグが含まれていればエラーになります。
+func replaceTodoTags(tx *gorm.DB, todoID uint, tagIDs []uint) error {
+      tags, err := findTags(tx, tagIDs)
+      if err != nil {
+            return err
+      }
+      todo := &Todo{BaseModel: BaseModel{ID: todoID}}
+      if len(tags) == 0 {
+            return tx.Model(todo).Association("Tags").Clear()
+      }
+      return tx.Model(todo).Association("Tags").Replace(tags)
+}
+
+func findTags(tx *gorm.DB, tagIDs []uint) ([]*Tag, error) {
+      tagIDs = uniqueIDs(tagIDs)
+      tags := []*Tag{}
+      if len(tagIDs) == 0 {
+            return tags, nil
+      }
+      if err := tx.Where("id IN ?", tagIDs).Find(&tags).Error; err != nil {
+            return nil, err
+      }
+      if len(tags) != len(tagIDs) {
+            return nil, gorm.ErrRecordNotFound
+      }
+      return tags, nil
+}
+
+// FindOrCreateTag は名前が一致する（大文字・小文字は区別しない）タグを返します。無ければ作成します。
+func FindOrCreateTag(tx *gorm.DB, name string) (Tag, error) {
+      var tag Tag
+      err := tx.Where("LOWER(name) = LOWER(?)", name).Order("id").First(&tag).Error
+      if err == nil {
+            return tag, nil
+      }
+      if !errors.Is(err, gorm.ErrRecordNotFound) {
+            return Tag{}, err
+      }
+      tag = Tag{Name: name}
+      if err := tx.Create(&tag).Error; err != nil {
+            return Tag{}, err
+      }
+      return tag, nil
+}
+
+// filterByTags は tagIDs のタグで Todo を絞り込みます。match が all の場合はすべてのタグが付いている Todo だけを返します。
+func filterByTags(db *gorm.DB, tagIDs []uint, match string) (*gorm.DB, error) {
+      if len(tagIDs) == 0 {
+            return db, nil
+      }
+      tagIDs = uniqueIDs(tagIDs)
+      switch match {
+      case "", TagMatchAny:
+            return db.Where("todos.id IN (SELECT todo_id FROM todo_tags WHERE tag_id IN ?)", tagIDs), nil
+      case TagMatchAll:
+            return db.Where("todos.id IN (SELECT todo_id FROM todo_tags WHERE tag_id IN ? GROUP BY todo_id HAVING COUNT(DISTINCT tag_id) = ?)", tagIDs, len(tagIDs)), nil
+      default:
+            return nil, ErrInvalidTagMatch
+      }
+}
+
+func checkTagName(tx *gorm.DB, id uint, name string) error {
+      var count int64
+      if err := tx.Model(&Tag{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, id).Count(&count).Error; err != nil {
+            return err
+      }
+      if count > 0 {
+            return ErrTagNameTaken
+      }
+      return nil
+}
+
+func (m *TodoModel) ConvertTagToOutput(tag Tag) requests.TagOutput {
+      return requests.TagOutput{
+            ID:    tag.ID,
+            Name:  tag.Name,
+            Color: tag.Color,
+      }
+}
+
+func (m *TodoModel) ConvertTagsToOutput(tags []Tag) []requests.TagOutput {
+      output := []requests.TagOutput{}
+      for _, tag := range tags {
+            output = append(output, m.ConvertTagToOutput(tag))
+      }
+      return output
+}
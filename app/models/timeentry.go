@@ -0,0 +1,584 @@
+package models
+
+import (
+	"app/requests"
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // TimeEntrySourceTimer はタイマーで計測した記録です。
+      TimeEntrySourceTimer = "timer"
+      // TimeEntrySourceManual は手で入力した記録です。
+      TimeEntrySourceManual = "manual"
+)
+
+const (
+      TimesheetFormatJSON = "json"
+      TimesheetFormatCSV  = "csv"
+)
+
+// timesheetDateLayout はタイムシートの期間と週の初めの日付の形式です。
+const timesheetDateLayout = "2006-01-02"
+
+// timesheetMaxDays はタイムシートで一度に集計できる日数です。
+const timesheetMaxDays = 366
+
+// TimeEntry はユーザーが Todo に使った時間の記録です。タイマーで計測したものと、手で入力したものがあります。
+// 計測中のタイマーは EndedAt が nil で、ユーザーごとに（ワークスペースをまたいでも）1 つまでです。
+// 開始はユーザーごとに順番に行い、部分ユニークインデックスでも 2 つにならないことを保証します。
+type TimeEntry struct {
+      ID uint `gorm:"primary_key" json:"id"`
+      // 属するワークスペース（workspace.go を参照）
+      WorkspaceID uint       `gorm:"not null;default:0;index" json:"workspace_id"`
+      TodoID      uint       `gorm:"not null;index" json:"todo_id"`
+      Todo        *Todo      `json:"todo,omitempty"`
+      UserID      uint       `gorm:"not null;index;uniqueIndex:idx_time_entries_running,where:ended_at IS NULL" json:"user_id"`
+      User        User       `json:"user"`
+      StartedAt   time.Time  `gorm:"not null;index" json:"started_at"`
+      EndedAt     *time.Time `json:"ended_at"`
+      // timer または manual
+      Source    string    `gorm:"not null" json:"source"`
+      Note      string    `json:"note"`
+      CreatedAt time.Time `json:"created_at"`
+      UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Timesheet は開始日時が期間内の記録（計測中のものを除く）を、週・ユーザー・プロジェクトごとに集計したものです。
+type Timesheet struct {
+      // From の日から To の日まで。呼び出したユーザーのタイムゾーンの日付です
+      From time.Time
+      To   time.Time
+      Rows []TimesheetRow
+}
+
+// TimesheetRow は 1 週間に 1 人のユーザーが 1 つのプロジェクトに使った時間です。
+type TimesheetRow struct {
+      // 週の初めの月曜日
+      WeekStart time.Time
+      User      User
+      // プロジェクトに属さない Todo の場合は nil
+      Project  *Project
+      Duration time.Duration
+      Entries  int
+}
+
+var (
+      // ErrNoRunningTimer は計測中のタイマーが無いときにタイマーを止めようとした場合に返されます。
+      ErrNoRunningTimer = errors.New("no timer is running")
+      // ErrInvalidTimeEntry は終了日時が開始日時より前の記録や、長さの無い記録を作ろうとした場合に返されます。
+      ErrInvalidTimeEntry = errors.New("time entry needs started_at and either ended_at after it or positive minutes")
+      // ErrTimeEntryForbidden は他のユーザーの記録を変更・削除しようとした場合に返されます。
+      ErrTimeEntryForbidden = errors.New("you cannot change another user's time entry")
+      // ErrInvalidEstimate は見積もりに負の値が指定された場合に返されます。
+      ErrInvalidEstimate = errors.New("estimate_minutes must not be negative")
+      // ErrInvalidTimesheetRange は期間の形式が正しくない場合や、期間が長すぎる場合に返されます。
+      ErrInvalidTimesheetRange = errors.New("from and to must be YYYY-MM-DD, to must not be before from, and the range must be at most 366 days")
+      // ErrInvalidTimesheetFormat は対応していない形式が指定された場合に返されます。
+      ErrInvalidTimesheetFormat = errors.New("format must be json or csv")
+      // ErrTimesheetForbidden はワークスペースの持ち主・管理者以外が、他のユーザーのタイムシートを見ようとした場合に返されます。
+      ErrTimesheetForbidden = errors.New("only workspace owners and admins can see other users' timesheets")
+)
+
+var timesheetColumns = []string{"week_start", "user_id", "user_name", "user_email", "project_id", "project_name", "minutes", "hours", "entries"}
+
+// validateEstimate は見積もり（分）を確認し、保存する値を返します。0 は見積もり無し（nil）として扱います。
+func validateEstimate(minutes int) (*int, error) {
+      if minutes < 0 {
+            return nil, ErrInvalidEstimate
+      }
+      if minutes == 0 {
+            return nil, nil
+      }
+      return &minutes, nil
+}
+
+// GetRunningTimer は呼び出したユーザーの計測中のタイマーを返します。他のワークスペースのものも返し、無い場合は nil です。
+func (m *TodoModel) GetRunningTimer() (*TimeEntry, error) {
+      var entries []TimeEntry
+      err := preloadTimeEntry(acrossWorkspaces(m.DB)).
+            Where("user_id = ? AND ended_at IS NULL", m.UserID).
+            Limit(1).Find(&entries).Error
+      if err != nil {
+            return nil, err
+      }
+      if len(entries) == 0 {
+            return nil, nil
+      }
+      return &entries[0], nil
+}
+
+// StartTimer は Todo のタイマーを開始します。他の Todo のタイマーを計測中の場合は、そのタイマーを止めてから開始します。
+// 同じ Todo のタイマーを計測中の場合は、そのまま計測を続けます。
+func (m *TodoModel) StartTimer(todoID uint) (TimeEntry, error) {
+      var entryID uint
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := lockTimerUser(tx, m.UserID); err != nil {
+                  return err
+            }
+            if _, err := findScopedTodo(tx, todoID); err != nil {
+                  return err
+            }
+            running, err := findRunningTimer(tx, m.UserID)
+            if err != nil {
+                  return err
+            }
+            if running != nil && running.TodoID == todoID {
+                  entryID = running.ID
+                  return nil
+            }
+
+            now := time.Now()
+            if running != nil {
+                  if err := acrossWorkspaces(tx).Model(running).Update("ended_at", now).Error; err != nil {
+                        return err
+                  }
+            }
+            entry := TimeEntry{TodoID: todoID, UserID: m.UserID, StartedAt: now, Source: TimeEntrySourceTimer}
+            if err := tx.Omit("Todo", "User").Create(&entry).Error; err != nil {
+                  return err
+            }
+            entryID = entry.ID
+            return nil
+      })
+      if err != nil {
+            return TimeEntry{}, err
+      }
+      return m.getTimeEntry(m.DB, entryID)
+}
+
+// StopTimer は呼び出したユーザーの計測中のタイマーを止めます。他のワークスペースのタイマーも止めます。
+func (m *TodoModel) StopTimer() (TimeEntry, error) {
+      var entryID uint
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := lockTimerUser(tx, m.UserID); err != nil {
+                  return err
+            }
+            running, err := findRunningTimer(tx, m.UserID)
+            if err != nil {
+                  return err
+            }
+            if running == nil {
+                  return ErrNoRunningTimer
+            }
+            entryID = running.ID
+            return acrossWorkspaces(tx).Model(running).Update("ended_at", time.Now()).Error
+      })
+      if err != nil {
+            return TimeEntry{}, err
+      }
+      return m.getTimeEntry(acrossWorkspaces(m.DB), entryID)
+}
+
+// lockTimerUser はユーザーの行をロックして、同じユーザーのタイマーの開始・停止を順番に行うようにします。
+func lockTimerUser(tx *gorm.DB, userID uint) error {
+      return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", userID).First(&User{}).Error
+}
+
+// findRunningTimer はユーザーの計測中のタイマーを、ワークスペースに関係なく探します。無い場合は nil です。
+func findRunningTimer(tx *gorm.DB, userID uint) (*TimeEntry, error) {
+      var entries []TimeEntry
+      if err := acrossWorkspaces(tx).Where("user_id = ? AND ended_at IS NULL", userID).Limit(1).Find(&entries).Error; err != nil {
+            return nil, err
+      }
+      if len(entries) == 0 {
+            return nil, nil
+      }
+      return &entries[0], nil
+}
+
+// GetTimeEntries は Todo の記録を新しい順に返します。?page=1&per_page=20 でページを指定します。
+func (m *TodoModel) GetTimeEntries(todoID uint, query requests.PageQuery) ([]TimeEntry, requests.PageOutput, error) {
+      if _, err := findScopedTodo(m.DB, todoID); err != nil {
+            return nil, requests.PageOutput{}, err
+      }
+      page := normalizePage(query)
+
+      entries := m.DB.Model(&TimeEntry{}).Where("todo_id = ?", todoID)
+      if err := entries.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+            return nil, requests.PageOutput{}, err
+      }
+      page.TotalPages = int((page.Total + int64(page.PerPage) - 1) / int64(page.PerPage))
+
+      var result []TimeEntry
+      err := entries.Preload("User").
+            Order("started_at DESC, id DESC").
+            Offset((page.Page - 1) * page.PerPage).Limit(page.PerPage).
+            Find(&result).Error
+      if err != nil {
+            return nil, requests.PageOutput{}, err
+      }
+      return result, page, nil
+}
+
+// CreateTimeEntry は呼び出したユーザーが Todo に使った時間を、手で入力して記録します。
+func (m *TodoModel) CreateTimeEntry(todoID uint, input requests.CreateTimeEntryInput) (TimeEntry, error) {
+      endedAt, err := timeEntryEnd(input.StartedAt, input.EndedAt, input.Minutes)
+      if err != nil {
+            return TimeEntry{}, err
+      }
+      if _, err := findScopedTodo(m.DB, todoID); err != nil {
+            return TimeEntry{}, err
+      }
+      entry := TimeEntry{
+            TodoID:    todoID,
+            UserID:    m.UserID,
+            StartedAt: input.StartedAt,
+            EndedAt:   &endedAt,
+            Source:    TimeEntrySourceManual,
+            Note:      input.Note,
+      }
+      if err := m.DB.Omit("Todo", "User").Create(&entry).Error; err != nil {
+            return TimeEntry{}, err
+      }
+      return m.getTimeEntry(m.DB, entry.ID)
+}
+
+// UpdateTimeEntry は記録の日時やメモを変更します。記録したユーザーだけが変更できます。
+// 計測中のタイマーに終了日時（または分数）を指定すると、その日時で止まります。
+func (m *TodoModel) UpdateTimeEntry(todoID uint, entryID uint, input requests.UpdateTimeEntryInput) (TimeEntry, error) {
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var entry TimeEntry
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND todo_id = ?", entryID, todoID).First(&entry).Error; err != nil {
+                  return err
+            }
+            if entry.UserID != m.UserID {
+                  return ErrTimeEntryForbidden
+            }
+
+            startedAt := entry.StartedAt
+            if input.StartedAt != nil {
+                  startedAt = *input.StartedAt
+            }
+            endedAt := entry.EndedAt
+            if input.EndedAt != nil || input.Minutes != nil {
+                  end, err := timeEntryEnd(startedAt, input.EndedAt, input.Minutes)
+                  if err != nil {
+                        return err
+                  }
+                  endedAt = &end
+            }
+            if endedAt != nil && !endedAt.After(startedAt) {
+                  return ErrInvalidTimeEntry
+            }
+            // 計測中のタイマーは、未来から始めることはできない
+            if endedAt == nil && startedAt.After(time.Now()) {
+                  return ErrInvalidTimeEntry
+            }
+
+            updates := map[string]interface{}{
+                  "started_at": startedAt,
+                  "ended_at":   endedAt,
+            }
+            if input.Note != nil {
+                  updates["note"] = *input.Note
+            }
+            return tx.Model(&entry).Updates(updates).Error
+      })
+      if err != nil {
+            return TimeEntry{}, err
+      }
+      return m.getTimeEntry(m.DB, entryID)
+}
+
+// DeleteTimeEntry は記録を削除します。記録したユーザーと、ワークスペースの持ち主・管理者が削除できます。
+func (m *TodoModel) DeleteTimeEntry(todoID uint, entryID uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            var entry TimeEntry
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND todo_id = ?", entryID, todoID).First(&entry).Error; err != nil {
+                  return err
+            }
+            if entry.UserID != m.UserID {
+                  err := m.requireWorkspaceRole(tx, entry.WorkspaceID, m.UserID, WorkspaceRoleOwner, WorkspaceRoleAdmin)
+                  if errors.Is(err, ErrWorkspaceForbidden) {
+                        return ErrTimeEntryForbidden
+                  }
+                  if err != nil {
+                        return err
+                  }
+            }
+            return tx.Delete(&entry).Error
+      })
+}
+
+func (m *TodoModel) getTimeEntry(db *gorm.DB, entryID uint) (TimeEntry, error) {
+      var entry TimeEntry
+      if err := preloadTimeEntry(db).Where("id = ?", entryID).First(&entry).Error; err != nil {
+            return TimeEntry{}, err
+      }
+      return entry, nil
+}
+
+func preloadTimeEntry(db *gorm.DB) *gorm.DB {
+      return db.Preload("Todo").Preload("User")
+}
+
+// timeEntryEnd は開始日時と、終了日時または分数から、記録の終了日時を求めます。
+func timeEntryEnd(startedAt time.Time, endedAt *time.Time, minutes *int) (time.Time, error) {
+      if startedAt.IsZero() {
+            return time.Time{}, ErrInvalidTimeEntry
+      }
+      switch {
+      case endedAt != nil:
+            if !endedAt.After(startedAt) {
+                  return time.Time{}, ErrInvalidTimeEntry
+            }
+            return *endedAt, nil
+      case minutes != nil && *minutes > 0:
+            return startedAt.Add(time.Duration(*minutes) * time.Minute), nil
+      default:
+            return time.Time{}, ErrInvalidTimeEntry
+      }
+}
+
+// attachTimeSpent は todos それぞれについて、記録された時間の合計を求めて TimeSpent に設定します。
+// 計測中のタイマーは今までの時間を含めます。
+func attachTimeSpent(db *gorm.DB, todos []Todo) error {
+      if len(todos) == 0 {
+            return nil
+      }
+      ids := make([]uint, len(todos))
+      for i, todo := range todos {
+            ids[i] = todo.ID
+      }
+
+      var rows []struct {
+            TodoID  uint
+            Seconds float64
+      }
+      if err := db.Raw(`SELECT todo_id, SUM(EXTRACT(EPOCH FROM COALESCE(ended_at, ?) - started_at)) AS seconds
+      FROM time_entries WHERE todo_id IN ? GROUP BY todo_id`, time.Now(), ids).Scan(&rows).Error; err != nil {
+            return err
+      }
+
+      spent := map[uint]time.Duration{}
+      for _, row := range rows {
+            spent[row.TodoID] = time.Duration(row.Seconds * float64(time.Second))
+      }
+      for i := range todos {
+            todos[i].TimeSpent = spent[todos[i].ID]
+      }
+      return nil
+}
+
+// GetTimesheet は開始日時が query の期間内の記録（計測中のものを除く）を、週（月曜日から）・ユーザー・プロジェクトごとに集計します。
+// 週と期間は呼び出したユーザーのタイムゾーンで区切り、記録は開始日時の週に数えます。ゴミ箱に入っている Todo の記録も含めます。
+// 期間を省略した場合は、4 週間前の週の初めから今日までです。
+// ワークスペースの持ち主・管理者以外は、自分の記録だけを集計します。
+func (m *TodoModel) GetTimesheet(query requests.TimesheetQuery) (Timesheet, error) {
+      loc := m.location()
+      now := time.Now().In(loc)
+      today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+      // 今週の月曜日の 3 週間前
+      sinceMonday := (int(today.Weekday()) + 6) % 7
+      from := today.AddDate(0, 0, -sinceMonday-7*3)
+      to := today
+      var err error
+      if query.From != "" {
+            if from, err = time.ParseInLocation(timesheetDateLayout, query.From, loc); err != nil {
+                  return Timesheet{}, ErrInvalidTimesheetRange
+            }
+      }
+      if query.To != "" {
+            if to, err = time.ParseInLocation(timesheetDateLayout, query.To, loc); err != nil {
+                  return Timesheet{}, ErrInvalidTimesheetRange
+            }
+      }
+      if to.Before(from) || to.Sub(from) >= timesheetMaxDays*24*time.Hour {
+            return Timesheet{}, ErrInvalidTimesheetRange
+      }
+
+      userID := query.UserID
+      if userID != m.UserID {
+            err := m.requireWorkspaceRole(m.DB, m.WorkspaceID, m.UserID, WorkspaceRoleOwner, WorkspaceRoleAdmin)
+            switch {
+            case errors.Is(err, ErrWorkspaceForbidden) && userID == 0:
+                  userID = m.UserID
+            case errors.Is(err, ErrWorkspaceForbidden):
+                  return Timesheet{}, ErrTimesheetForbidden
+            case err != nil:
+                  return Timesheet{}, err
+            }
+      }
+
+      // 生の SQL と同じく Table には条件が付かないので、ワークスペースは明示する
+      db := m.DB.Table("time_entries AS e").
+            Select("date_trunc('week', e.started_at AT TIME ZONE ?)::date AS week_start, e.user_id, t.project_id, "+
+                  "SUM(EXTRACT(EPOCH FROM e.ended_at - e.started_at)) AS seconds, COUNT(*) AS entries", loc.String()).
+            Joins("JOIN todos t ON t.id = e.todo_id").
+            Where("e.workspace_id = ? AND e.ended_at IS NOT NULL AND e.started_at >= ? AND e.started_at < ?", m.WorkspaceID, from, to.AddDate(0, 0, 1))
+      if userID != 0 {
+            db = db.Where("e.user_id = ?", userID)
+      }
+      if query.ProjectID != 0 {
+            db = db.Where("t.project_id = ?", query.ProjectID)
+      }
+      var rows []struct {
+            WeekStart time.Time
+            UserID    uint
+            ProjectID *uint
+            Seconds   float64
+            Entries   int
+      }
+      if err := db.Group("week_start, e.user_id, t.project_id").Order("week_start, e.user_id, t.project_id NULLS FIRST").Scan(&rows).Error; err != nil {
+            return Timesheet{}, err
+      }
+
+      var userIDs, projectIDs []uint
+      for _, row := range rows {
+            userIDs = append(userIDs, row.UserID)
+            if row.ProjectID != nil {
+                  projectIDs = append(projectIDs, *row.ProjectID)
+            }
+      }
+      users := map[uint]User{}
+      if len(userIDs) > 0 {
+            var found []User
+            if err := m.DB.Where("id IN ?", uniqueIDs(userIDs)).Find(&found).Error; err != nil {
+                  return Timesheet{}, err
+            }
+            for _, user := range found {
+                  users[user.ID] = user
+            }
+      }
+      projects := map[uint]Project{}
+      if len(projectIDs) > 0 {
+            var found []Project
+            if err := m.DB.Where("id IN ?", uniqueIDs(projectIDs)).Find(&found).Error; err != nil {
+                  return Timesheet{}, err
+            }
+            for _, project := range found {
+                  projects[project.ID] = project
+            }
+      }
+
+      sheet := Timesheet{From: from, To: to, Rows: []TimesheetRow{}}
+      for _, row := range rows {
+            item := TimesheetRow{
+                  WeekStart: row.WeekStart,
+                  User:      users[row.UserID],
+                  Duration:  time.Duration(row.Seconds * float64(time.Second)),
+                  Entries:   row.Entries,
+            }
+            if row.ProjectID != nil {
+                  if project, ok := projects[*row.ProjectID]; ok {
+                        item.Project = &project
+                  }
+            }
+            sheet.Rows = append(sheet.Rows, item)
+      }
+      return sheet, nil
+}
+
+// WriteTimesheetCSV はタイムシートを CSV で w に書き出します。
+func (m *TodoModel) WriteTimesheetCSV(w io.Writer, sheet Timesheet) error {
+      buf := bufio.NewWriter(w)
+      // Excel で開いたときに文字化けしないように BOM を付ける
+      if _, err := io.WriteString(buf, "\ufeff"); err != nil {
+            return err
+      }
+      writer := csv.NewWriter(buf)
+      if err := writer.Write(timesheetColumns); err != nil {
+            return err
+      }
+      for _, row := range m.ConvertTimesheetToOutput(sheet).Rows {
+            projectID := ""
+            if row.ProjectID != nil {
+                  projectID = strconv.FormatUint(uint64(*row.ProjectID), 10)
+            }
+            err := writer.Write([]string{
+                  row.WeekStart,
+                  strconv.FormatUint(uint64(row.User.ID), 10),
+                  row.User.Name,
+                  row.User.Email,
+                  projectID,
+                  row.ProjectName,
+                  strconv.Itoa(row.Minutes),
+                  fmt.Sprintf("%.2f", row.Hours),
+                  strconv.Itoa(row.Entries),
+            })
+            if err != nil {
+                  return err
+            }
+      }
+      writer.Flush()
+      if err := writer.Error(); err != nil {
+            return err
+      }
+      return buf.Flush()
+}
+
+// durationMinutes は時間を分に丸めます。
+func durationMinutes(d time.Duration) int {
+      return int(math.Round(d.Minutes()))
+}
+
+func (m *TodoModel) ConvertTimeEntryToOutput(entry TimeEntry) requests.TimeEntryOutput {
+      loc := m.location()
+      output := requests.TimeEntryOutput{
+            ID:          entry.ID,
+            WorkspaceID: entry.WorkspaceID,
+            TodoID:      entry.TodoID,
+            User:        convertUserToOutput(entry.User),
+            StartedAt:   entry.StartedAt.In(loc),
+            Running:     entry.EndedAt == nil,
+            Source:      entry.Source,
+            Note:        entry.Note,
+            CreatedAt:   entry.CreatedAt,
+      }
+      // 計測中のタイマーは今までの時間を返す
+      end := time.Now()
+      if entry.EndedAt != nil {
+            end = *entry.EndedAt
+            endedAt := entry.EndedAt.In(loc)
+            output.EndedAt = &endedAt
+      }
+      output.Minutes = durationMinutes(end.Sub(entry.StartedAt))
+      if entry.Todo != nil {
+            output.TodoTitle = entry.Todo.Title
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertTimeEntriesToOutput(entries []TimeEntry) []requests.TimeEntryOutput {
+      output := []requests.TimeEntryOutput{}
+      for _, entry := range entries {
+            output = append(output, m.ConvertTimeEntryToOutput(entry))
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertTimesheetToOutput(sheet Timesheet) requests.TimesheetOutput {
+      output := requests.TimesheetOutput{
+            From: sheet.From.Format(timesheetDateLayout),
+            To:   sheet.To.Format(timesheetDateLayout),
+            Rows: []requests.TimesheetRowOutput{},
+      }
+      var total time.Duration
+      for _, row := range sheet.Rows {
+            item := requests.TimesheetRowOutput{
+                  WeekStart: row.WeekStart.Format(timesheetDateLayout),
+                  User:      convertUserToOutput(row.User),
+                  Minutes:   durationMinutes(row.Duration),
+                  Hours:     math.Round(row.Duration.Hours()*100) / 100,
+                  Entries:   row.Entries,
+            }
+            if row.Project != nil {
+                  item.ProjectID = &row.Project.ID
+                  item.ProjectName = row.Project.Name
+            }
+            output.Rows = append(output.Rows, item)
+            total += row.Duration
+      }
+      output.TotalMinutes = durationMinutes(total)
+      return output
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"app/pkg/utils"
+	"app/requests"
+	"errors"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+      // DueToday は今日が期限の Todo に絞り込みます。
+      DueToday = "today"
+      // DueThisWeek は今週（月曜日から日曜日）が期限の Todo に絞り込みます。
+      DueThisWeek = "this_week"
+      // DueOverdue は期限を過ぎた未完了の Todo に絞り込みます。
+      DueOverdue = "overdue"
+)
+
+var (
+      // ErrInvalidTimeZone は IANA のタイムゾーン名（例: Asia/Tokyo）として読み込めない場合に返されます。
+      ErrInvalidTimeZone = errors.New("time_zone must be an IANA time zone name such as Asia/Tokyo")
+      // ErrInvalidDue は対応していない due が指定された場合に返されます。
+      ErrInvalidDue = errors.New("due must be today, this_week or overdue")
+)
+
+// DefaultTimeZone はタイムゾーンを設定していないユーザーのタイムゾーンです。環境変数 DEFAULT_TIME_ZONE で変更できます。
+func DefaultTimeZone() string {
+      if name := os.Getenv("DEFAULT_TIME_ZONE"); name != "" {
+            return name
+      }
+      return "Asia/Tokyo"
+}
+
+// LoadTimeZone は IANA のタイムゾーン名を読み込みます。
+func LoadTimeZone(name string) (*time.Location, error) {
+      // time.LoadLocation は "" や "Local" も受け付けてしまうので除外する
+      if name == "" || name == "Local" {
+            return nil, ErrInvalidTimeZone
+      }
+      loc, err := time.LoadLocation(name)
+      if err != nil {
+            return nil, ErrInvalidTimeZone
+      }
+      return loc, nil
+}
+
+// WithLocation は日時を loc で扱う TodoModel を返します。
+// 終日の期限の日付の決定、due の絞り込み、出力する日時のタイムゾーンに使います。
+func (m *TodoModel) WithLocation(loc *time.Location) *TodoModel {
+      local := *m
+      local.Location = loc
+      return &local
+}
+
+func (m *TodoModel) location() *time.Location {
+      if m.Location == nil {
+            return time.UTC
+      }
+      return m.Location
+}
+
+// GetUserLocation はユーザーが設定しているタイムゾーンを返します。
+func (m *TodoModel) GetUserLocation(userID uint) (*time.Location, error) {
+      var user User
+      if err := m.DB.Select("id", "time_zone").Where("id = ?", userID).First(&user).Error; err != nil {
+            return nil, err
+      }
+      if loc, err := LoadTimeZone(user.TimeZone); err == nil {
+            return loc, nil
+      }
+      return LoadTimeZone(DefaultTimeZone())
+}
+
+// normalizeDeadline は保存する期限を求めます。
+// 終日の期限は、loc での日付の 0 時（UTC）として保存し、どのタイムゾーンで見ても同じ日付になるようにします。
+func normalizeDeadline(deadline time.Time, allDay bool, loc *time.Location) time.Time {
+      if deadline.IsZero() || !allDay {
+            return deadline
+      }
+      year, month, day := deadline.In(loc).Date()
+      return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// localizeDeadline は保存されている期限を loc の日時にします。終日の期限は、同じ日付の loc での 0 時になります。
+func localizeDeadline(deadline time.Time, allDay bool, loc *time.Location) time.Time {
+      if deadline.IsZero() {
+            return deadline
+      }
+      if allDay {
+            year, month, day := deadline.UTC().Date()
+            return time.Date(year, month, day, 0, 0, 0, 0, loc)
+      }
+      return deadline.In(loc)
+}
+
+// filterByDue は loc での「今日」「今週」「期限切れ」で Todo を絞り込みます。
+// 終日の期限は日付で、時刻付きの期限は loc での日時の範囲で比較します。
+func filterByDue(db *gorm.DB, due string, loc *time.Location, now time.Time) (*gorm.DB, error) {
+      now = now.In(loc)
+      year, month, day := now.Date()
+      today := time.Date(year, month, day, 0, 0, 0, 0, loc)
+      todayDate := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+      var from, to, fromDate, toDate time.Time
+      switch due {
+      case "":
+            return db, nil
+      case DueToday:
+            from, to = today, today.AddDate(0, 0, 1)
+            fromDate, toDate = todayDate, todayDate.AddDate(0, 0, 1)
+      case DueThisWeek:
+            // 週は月曜日から始まる
+            offset := (int(now.Weekday()) + 6) % 7
+            from = today.AddDate(0, 0, -offset)
+            fromDate = todayDate.AddDate(0, 0, -offset)
+            to, toDate = from.AddDate(0, 0, 7), fromDate.AddDate(0, 0, 7)
+      case DueOverdue:
+            // 期限が設定されていない（ゼロ値の）Todo は除く
+            return db.Where("todos.deadline > ?", time.Time{}).
+                  Where("(todos.deadline_all_day AND todos.deadline < ?) OR (NOT todos.deadline_all_day AND todos.deadline < ?)", todayDate, now).
+                  Where("todos.status_id IN (SELECT id FROM statuses WHERE NOT is_done)"), nil
+      default:
+            return nil, ErrInvalidDue
+      }
+      return db.Where("(todos.deadline_all_day AND todos.deadline >= ? AND todos.deadline < ?) OR (NOT todos.deadline_all_day AND todos.deadline >= ? AND todos.deadline < ?)",
+            fromDate, toDate, from, to), nil
+}
+
+// InterpretedDeadline は自然言語で指定された期限をどう解釈したかです。クライアントが確認できるようにレスポンスで返します。
+type InterpretedDeadline struct {
+      Text     string    `json:"text"`
+      Deadline time.Time `json:"deadline"`
+      AllDay   bool      `json:"all_day"`
+}
+
+// interpretDeadline は「明日の15時」「tomorrow 5pm」のような期限を、m のタイムゾーンの現在日時を基準に解釈します。
+func (m *TodoModel) interpretDeadline(text string) (InterpretedDeadline, error) {
+      deadline, allDay, err := utils.ParseNaturalDeadline(text, time.Now().In(m.location()))
+      if err != nil {
+            return InterpretedDeadline{}, err
+      }
+      return InterpretedDeadline{Text: text, Deadline: deadline, AllDay: allDay}, nil
+}
+
+func convertInterpretedDeadlineToOutput(interpreted *InterpretedDeadline) *requests.InterpretedDeadlineOutput {
+      if interpreted == nil {
+            return nil
+      }
+      return &requests.InterpretedDeadlineOutput{
+            Text:     interpreted.Text,
+            Deadline: interpreted.Deadline,
+            AllDay:   interpreted.AllDay,
+      }
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"app/pkg/storage"
+	"app/pkg/utils"
 	"app/requests"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,6 +12,7 @@ import (
 	"github.com/go-ozzo/ozzo-validation/is"         // 追加
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BaseModel struct {
@@ -21,109 +25,659 @@ type BaseModel struct {
 }
  
 type Todo struct {
-      ID      uint   `gorm:"primary_key" json:"id"`
+      // BaseModel を埋め込むことで、Delete が論理削除（deleted_at に日時をセット）になります。
+      BaseModel
+      // 属するワークスペース（workspace.go を参照）
+      WorkspaceID uint `gorm:"not null;default:0;index" json:"workspace_id"`
       Title   string `gorm:"not null" json:"title"`
       Description string `json:"description"`
-      Category string `json:"category"`
       Deadline time.Time `json:"deadline"`
-      State bool `gorm:"not null" json:"state"`
-      Users    []*User `gorm:"many2many:user_todos;"`
+      // 終日の期限かどうか。終日の期限は日付の 0 時（UTC）として保存します（timezone.go を参照）。
+      DeadlineAllDay bool `gorm:"not null;default:false" json:"deadline_all_day"`
+      // 現在のステータス。State（完了/未完了）の代わりに使います。
+      StatusID uint `gorm:"index" json:"status_id"`
+      Status Status `json:"status"`
+      // 現在のステータスになった日時
+      StatusChangedAt *time.Time `json:"status_changed_at"`
+      // ボードの列の中での並び順。文字列の昇順に並べます（pkg/utils/rank.go を参照）。
+      Rank string `gorm:"not null;default:''" json:"rank"`
+      // 親タスクの ID。サブタスクでない場合は nil です。
+      ParentID *uint `gorm:"index" json:"parent_id"`
+      // 属するプロジェクトの ID。プロジェクトに属さない場合は nil です（project.go を参照）。
+      ProjectID *uint `gorm:"index" json:"project_id"`
+      // 優先度。0（P0、最も高い）〜 4（P4、最も低い）で、デフォルトは 2（P2）です。
+      Priority int `gorm:"not null;default:2" json:"priority"`
+      // 見積もり（分）。見積もっていない場合は nil です。
+      EstimateMinutes *int `json:"estimate_minutes"`
+      // 記録された時間の合計（計測中のタイマーを含む）。DB には保存せず、取得時に計算します（timeentry.go を参照）。
+      TimeSpent time.Duration `gorm:"-" json:"-"`
+      // 他のタスクの完了待ちかどうかと、待っている未完了の先行タスクの ID。DB には保存せず、取得時に計算します（dependency.go を参照）。
+      // 緊急度スコアの計算にも使います（urgency.go を参照）。
+      Blocked bool `gorm:"-" json:"-"`
+      BlockedBy []uint `gorm:"-" json:"-"`
+      // 繰り返しの Todo の場合、属する繰り返しの ID と、RRULE 上の何回目の日時か（recurrence.go を参照）
+      SeriesID *uint `gorm:"index" json:"series_id"`
+      OccurrenceAt *time.Time `json:"occurrence_at"`
+      // deadline_text で期限を指定した場合の解釈結果。DB には保存しません。
+      InterpretedDeadline *InterpretedDeadline `gorm:"-" json:"interpreted_deadline,omitempty"`
+      // 子孫タスクの完了状況。DB には保存せず、取得時に計算します（subtask.go を参照）。
+      Progress *TodoProgress `gorm:"-" json:"-"`
+      // 楽観的排他制御のためのバージョン番号。更新のたびに 1 ずつ増えます。
+      Version uint `gorm:"not null;default:1" json:"version"`
+      // Todo に関わっているユーザーと役割（assignee.go を参照）
+      Assignees []TodoAssignee `gorm:"foreignKey:TodoID" json:"assignees"`
+      ChecklistItems []ChecklistItem `json:"checklist_items"`
+      Tags []*Tag `gorm:"many2many:todo_tags;" json:"tags"`
 }
 
+// ErrVersionMismatch は更新・削除しようとした Todo が、他の誰かによって既に変更されていた場合に返されます。
+var ErrVersionMismatch = errors.New("todo has been modified by someone else")
+
+// ErrNotInTrash は、ゴミ箱に入っていない Todo を復元・完全削除しようとした場合に返されます。
+var ErrNotInTrash = errors.New("todo is not in the trash")
+
 type User struct {
       ID      uint   `gorm:"primary_key" json:"id"`
       Name   string `gorm:"not null" json:"name"`
       Email  string `gorm:"unique;not null" json:"email"`
       Password string `gorm:"not null" json:"password"`
+      // IANA のタイムゾーン名。期限の表示や「今日」「今週」の絞り込みに使います。
+      TimeZone string `gorm:"not null;default:'Asia/Tokyo'" json:"time_zone"`
+      // カレンダーのフィード用のトークンのハッシュ（calendar.go を参照）
+      CalendarTokenHash *string `gorm:"uniqueIndex" json:"-"`
 }
  
 type TodoModel struct {
       DB *gorm.DB
+      // 緊急度スコアの重み（urgency.go を参照）
+      Urgency UrgencyWeights
+      // 日時を扱うタイムゾーン。nil の場合は UTC です（timezone.go を参照）。
+      Location *time.Location
+      // 扱うワークスペース。0 の場合はワークスペースごとのデータを扱えません（workspace.go を参照）。
+      WorkspaceID uint
+      // 呼び出したユーザーの ID。0 の場合はプロジェクトの権限を確認しません（project.go を参照）。
+      UserID uint
+      // 添付ファイルの保存先（attachment.go を参照）
+      Storage storage.Storage
 }
 
 // NewTodoModel 関数は TodoModel のコンストラクタ関数です。この関数は、*gorm.DB 型の引数を受け取り、その引数を使って新しい TodoModel インスタンスを生成して返します。
 func NewTodoModel(db *gorm.DB) *TodoModel {
-      return &TodoModel{DB: db}
+      return &TodoModel{DB: db, Urgency: LoadUrgencyWeights()}
+}
+
+// preloadTodo は Todo と一緒に取得する関連データを指定します。
+// Todo に関連を追加した場合は、ここに Preload を追加してください。
+func preloadTodo(db *gorm.DB) *gorm.DB {
+      return db.Preload("Assignees", preloadAssignees).Preload("Status").Preload("Tags").Preload("ChecklistItems", func(db *gorm.DB) *gorm.DB {
+            return db.Order("position, id")
+      })
 }
 
-func (m *TodoModel) GetTodoAll() ([]Todo, error) {
+// GetTodoAll は query の条件に一致する Todo を返します。
+func (m *TodoModel) GetTodoAll(query requests.TodoListQuery) ([]Todo, error) {
       var todos []Todo
+      db, err := filterTodos(m.DB, query, m.location())
+      if err != nil {
+            return nil, err
+      }
       // m.DB.Find(&todos) は GORM を使用してデータベースからメモを検索します。検索結果は todos スライスに格納されます。
-      if err := m.DB.Preload("Users").Find(&todos).Error; err != nil {
+      if err := preloadTodo(db).Find(&todos).Error; err != nil {
+            return nil, err
+      }
+      if err := attachComputed(m.DB, todos); err != nil {
             return nil, err
       }
+      if query.Sort == TodoSortUrgency {
+            m.Urgency.sortByUrgency(todos, time.Now())
+      }
       fmt.Println(todos)
       return todos, nil
 }
  
+// filterTodos は一覧の絞り込み条件を適用します。絞り込み条件を追加する場合はここに追加してください。
+func filterTodos(db *gorm.DB, query requests.TodoListQuery, loc *time.Location) (*gorm.DB, error) {
+      switch query.Sort {
+      case "", TodoSortUrgency:
+            // urgency は計算値なので、取得後に並び替える
+      case TodoSortPriority:
+            db = db.Order("todos.priority").Order("todos.id")
+      case TodoSortDeadline:
+            // 期限の無い Todo は 0001-01-01 で保存しているので、期限のある Todo の後に並べる
+            db = db.Order("todos.deadline < '0002-01-01'").Order("todos.deadline").Order("todos.id")
+      default:
+            return nil, ErrInvalidTodoSort
+      }
+      db, err := filterByDue(db, query.Due, loc, time.Now())
+      if err != nil {
+            return nil, err
+      }
+      db = filterByProject(db, query.ProjectID, query.IncludeArchived)
+      return filterByTags(db, query.Tags, query.TagMatch)
+}
+ 
+// attachComputed は DB に保存しない Todo の項目（サブタスクの進み具合、完了待ちかどうか、記録された時間）を求めて設定します。
+// Todo を返す取得ではこれを呼んでください。
+func attachComputed(db *gorm.DB, todos []Todo) error {
+      if err := attachProgress(db, todos); err != nil {
+            return err
+      }
+      if err := attachBlocked(db, todos); err != nil {
+            return err
+      }
+      return attachTimeSpent(db, todos)
+}
+
 func (m *TodoModel) GetTodoByID(id uint) (Todo, error) {
       var todo Todo
       // First：指定されたモデルに基づいて最初のレコードを検索します。
       // Where: 指定された条件に基づいてレコードをフィルタリングします。
-      if err := m.DB.Preload("Users").Where("id = ?", id).First(&todo).Error; err != nil {
+      if err := preloadTodo(m.DB).Where("id = ?", id).First(&todo).Error; err != nil {
+            return Todo{}, err
+      }
+      todos := []Todo{todo}
+      if err := attachComputed(m.DB, todos); err != nil {
             return Todo{}, err
       }
-      return todo, nil
+      return todos[0], nil
 }
  
 func (m *TodoModel) CreateTodo(todo requests.CreateTodoInput) (Todo, error) {
       fmt.Printf("%+v\n", todo)
+      // 「明日の15時」のような期限は、deadline より優先する
+      var interpreted *InterpretedDeadline
+      if todo.DeadlineText != "" {
+            result, err := m.interpretDeadline(todo.DeadlineText)
+            if err != nil {
+                  return Todo{}, err
+            }
+            todo.Deadline = result.Deadline
+            todo.DeadlineAllDay = result.AllDay
+            interpreted = &result
+      }
       newTodo := Todo{
             Title:       todo.Title,
             Description: todo.Description,
-            Category:    todo.Category,
-            Deadline:    todo.Deadline,
-            State:       todo.State,
+            Deadline:    normalizeDeadline(todo.Deadline, todo.DeadlineAllDay, m.location()),
+            DeadlineAllDay: todo.DeadlineAllDay,
+            ParentID:    todo.ParentID,
+            Priority:    PriorityDefault,
+            Version:     1,
+            InterpretedDeadline: interpreted,
+      }
+      if todo.Priority != nil {
+            if err := validatePriority(*todo.Priority); err != nil {
+                  return Todo{}, err
+            }
+            newTodo.Priority = *todo.Priority
+      }
+      if todo.EstimateMinutes != nil {
+            estimate, err := validateEstimate(*todo.EstimateMinutes)
+            if err != nil {
+                  return Todo{}, err
+            }
+            newTodo.EstimateMinutes = estimate
       }
 
       relationUser,err := m.GetUserByEmail(todo.Email)
       if err != nil {
             return Todo{}, err
       }
-      if err := m.DB.Create(&newTodo).Error; err != nil {
+      newTodo.ProjectID = todo.ProjectID
+      if todo.ParentID != nil {
+            parent, err := m.GetTodoByID(*todo.ParentID)
+            if err != nil {
+                  return Todo{}, err
+            }
+            // サブタスクはプロジェクトの指定が無ければ親と同じプロジェクトにする
+            if newTodo.ProjectID == nil {
+                  newTodo.ProjectID = parent.ProjectID
+            }
+      }
+
+      // ステータスの指定が無ければデフォルトのステータスにする
+      if todo.StatusID != nil {
+            status, err := m.GetStatusByID(*todo.StatusID)
+            if err != nil {
+                  return Todo{}, err
+            }
+            newTodo.StatusID = status.ID
+      }
+
+      err = m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := checkWorkspaceMembers(tx, m.WorkspaceID, []uint{relationUser.ID}); err != nil {
+                  return err
+            }
+            if err := m.checkProjectTarget(tx, newTodo.ProjectID); err != nil {
+                  return err
+            }
+            if newTodo.Tags, err = findTags(tx, todo.TagIDs); err != nil {
+                  return err
+            }
+            // 作成したユーザーを持ち主にする
+            return createTodo(tx, &newTodo, []TodoAssignee{{UserID: relationUser.ID, Role: AssigneeRoleOwner}})
+      })
+      if err != nil {
             return Todo{}, err
       }
-      // 中間テーブルの関係を作成
-      if err := m.DB.Model(&newTodo).Association("Users").Append(&User{
-            ID: relationUser.ID, 
-            Email: relationUser.Email,
-            Name: relationUser.Name,
-            Password: relationUser.Password,
-            }); err != nil {
+      // 取得と同じ内容（サブタスクの進み具合などの計算値を含む）で返す
+      created, err := m.GetTodoByID(newTodo.ID)
+      if err != nil {
             return Todo{}, err
       }
-      return newTodo, nil
+      created.InterpretedDeadline = interpreted
+      return created, nil
+}
+
+// createTodo は Todo を作成し、ステータスの履歴と、関わっているユーザー（assignees と同じユーザーと役割）、Todo の履歴を記録します。
+// StatusID が 0 の場合はデフォルトのステータスになり、並び順はステータスの列の末尾になります。
+// WorkspaceID が 0 の場合は tx で指定されているワークスペースに作成します。
+func createTodo(tx *gorm.DB, newTodo *Todo, assignees []TodoAssignee) error {
+      if newTodo.WorkspaceID == 0 {
+            workspaceID, ok := workspaceFromContext(tx)
+            if !ok {
+                  return ErrWorkspaceRequired
+            }
+            newTodo.WorkspaceID = workspaceID
+      }
+      // AcrossWorkspaces で呼ばれた場合にも他のワークスペースのステータスにならないように、明示的に絞り込む
+      statuses := tx.Where("workspace_id = ?", newTodo.WorkspaceID)
+      var status Status
+      var err error
+      if newTodo.StatusID == 0 {
+            status, err = GetDefaultStatus(statuses)
+      } else {
+            err = statuses.Where("id = ?", newTodo.StatusID).First(&status).Error
+      }
+      if err != nil {
+            return err
+      }
+      now := time.Now()
+      newTodo.StatusID = status.ID
+      newTodo.StatusChangedAt = &now
+      if newTodo.Version == 0 {
+            newTodo.Version = 1
+      }
+
+      // 新しい Todo はステータスの列の末尾に並べる
+      last, err := statusColumn(newTodo.StatusID).lastRank(tx, 0)
+      if err != nil {
+            return err
+      }
+      if newTodo.Rank, err = utils.RankBetween(last, ""); err != nil {
+            return err
+      }
+
+      if err := tx.Create(newTodo).Error; err != nil {
+            return err
+      }
+      if err := tx.Create(&TodoStatusHistory{TodoID: newTodo.ID, StatusID: status.ID, EnteredAt: now}).Error; err != nil {
+            return err
+      }
+      if err := createAssignees(tx, newTodo, assignees); err != nil {
+            return err
+      }
+      newTodo.Status = status
+      return recordTodoCreated(tx, newTodo, assignees)
 }
  
-func (m *TodoModel) UpdateTodo(id uint, todo requests.UpdateTodoInput) (Todo, error) {
-      existingTodo, err := m.GetTodoByID(id)
+// UpdateTodo は version が一致する場合のみ Todo を更新します。
+// 一致しない場合は ErrVersionMismatch を返し、更新は行いません。
+func (m *TodoModel) UpdateTodo(id uint, version uint, todo requests.UpdateTodoInput) (Todo, error) {
+      if todo.Priority != nil {
+            if err := validatePriority(*todo.Priority); err != nil {
+                  return Todo{}, err
+            }
+      }
+      var estimate *int
+      if todo.EstimateMinutes != nil {
+            var err error
+            if estimate, err = validateEstimate(*todo.EstimateMinutes); err != nil {
+                  return Todo{}, err
+            }
+      }
+      var interpreted *InterpretedDeadline
+      if todo.DeadlineText != "" {
+            result, err := m.interpretDeadline(todo.DeadlineText)
+            if err != nil {
+                  return Todo{}, err
+            }
+            todo.Deadline = result.Deadline
+            todo.DeadlineAllDay = &result.AllDay
+            interpreted = &result
+      }
+      scope := todo.Scope
+      if scope == "" {
+            scope = RecurrenceScopeThis
+      }
+      if scope != RecurrenceScopeThis && scope != RecurrenceScopeFollowing && scope != RecurrenceScopeAll {
+            return Todo{}, ErrInvalidRecurrenceScope
+      }
+      var existingTodo Todo
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            // version の確認とインクリメントを 1 つの UPDATE で行うことで、同時更新による上書きを防ぎます。
+            result := tx.Model(&Todo{}).Where("id = ? AND version = ?", id, version).Update("version", gorm.Expr("version + 1"))
+            if result.Error != nil {
+                  return result.Error
+            }
+            if result.RowsAffected == 0 {
+                  // Todo 自体が存在しない場合は ErrRecordNotFound を返す
+                  if err := tx.Select("id").First(&Todo{}, id).Error; err != nil {
+                        return err
+                  }
+                  return ErrVersionMismatch
+            }
+
+            var current Todo
+            if err := tx.Select("id", "deadline", "deadline_all_day", "project_id", "series_id").Where("id = ?", id).First(&current).Error; err != nil {
+                  return err
+            }
+            if err := m.checkProjectWrite(tx, current.ProjectID); err != nil {
+                  return err
+            }
+            // 繰り返しの他の回にも反映する場合は、それらの変更も記録する
+            ids := []uint{id}
+            if scope != RecurrenceScopeThis && current.SeriesID != nil {
+                  var seriesIDs []uint
+                  if err := tx.Model(&Todo{}).Where("series_id = ?", *current.SeriesID).Pluck("id", &seriesIDs).Error; err != nil {
+                        return err
+                  }
+                  ids = append(ids, seriesIDs...)
+            }
+            audit, err := auditTodos(tx, TodoEventUpdated, ids...)
+            if err != nil {
+                  return err
+            }
+            // project_id が指定された場合はプロジェクトを移す（0 でプロジェクトから外す）
+            if todo.ProjectID != nil {
+                  var projectID *uint
+                  if *todo.ProjectID != 0 {
+                        projectID = todo.ProjectID
+                  }
+                  if err := m.checkProjectTarget(tx, projectID); err != nil {
+                        return err
+                  }
+                  if err := tx.Model(&Todo{}).Where("id = ?", id).Update("project_id", projectID).Error; err != nil {
+                        return err
+                  }
+            }
+            allDay := current.DeadlineAllDay
+            if todo.DeadlineAllDay != nil {
+                  allDay = *todo.DeadlineAllDay
+            }
+            deadline := todo.Deadline
+            // 終日かどうかだけを変更した場合も、今の期限を保存し直す
+            if deadline.IsZero() && allDay != current.DeadlineAllDay {
+                  deadline = current.Deadline
+            }
+
+            updatedTodo := Todo{
+                  Title:       todo.Title,
+                  Description: todo.Description,
+                  Deadline:    normalizeDeadline(deadline, allDay, m.location()),
+            }
+            if err := tx.Model(&Todo{}).Where("id = ?", id).Updates(updatedTodo).Error; err != nil {
+                  return err
+            }
+            if allDay != current.DeadlineAllDay {
+                  if err := tx.Model(&Todo{}).Where("id = ?", id).Update("deadline_all_day", allDay).Error; err != nil {
+                        return err
+                  }
+            }
+            // P0 は 0 なので、構造体の Updates では更新されない。指定された場合は個別に更新する
+            if todo.Priority != nil {
+                  if err := tx.Model(&Todo{}).Where("id = ?", id).Update("priority", *todo.Priority).Error; err != nil {
+                        return err
+                  }
+            }
+            // 見積もりは 0 で外せるよう、指定された場合は個別に更新する
+            if todo.EstimateMinutes != nil {
+                  if err := tx.Model(&Todo{}).Where("id = ?", id).Update("estimate_minutes", estimate).Error; err != nil {
+                        return err
+                  }
+            }
+            // tag_ids が指定された場合のみタグを置き換える
+            if todo.TagIDs != nil {
+                  if err := replaceTodoTags(tx, id, *todo.TagIDs); err != nil {
+                        return err
+                  }
+            }
+            if err := tx.Where("id = ?", id).First(&existingTodo).Error; err != nil {
+                  return err
+            }
+            // 繰り返しの Todo では、同じ繰り返しの他の回にも反映できる
+            if scope != RecurrenceScopeThis {
+                  if err := applyToSeries(tx, existingTodo, scope, todo); err != nil {
+                        return err
+                  }
+            }
+            // ステータスは許可された遷移かどうかを確認してから変更する
+            if todo.StatusID != nil {
+                  if err := changeTodoStatus(tx, &existingTodo, *todo.StatusID, time.Now()); err != nil {
+                        return err
+                  }
+            }
+            if err := audit.record(tx); err != nil {
+                  return err
+            }
+            return preloadTodo(tx).Where("id = ?", id).First(&existingTodo).Error
+      })
       if err != nil {
             return Todo{}, err
       }
-      updatedTodo := requests.UpdateTodoInput{
-            Title:       todo.Title,
-            Description: todo.Description,
-            Category:    todo.Category,
-            Deadline:    todo.Deadline,
-            State:       todo.State,
-      }
-      if err := m.DB.Model(&existingTodo).Updates(updatedTodo).Error; err != nil {
+      todos := []Todo{existingTodo}
+      if err := attachComputed(m.DB, todos); err != nil {
             return Todo{}, err
       }
+      existingTodo = todos[0]
+      existingTodo.InterpretedDeadline = interpreted
       return existingTodo, nil
 }
  
-func (m *TodoModel) DeleteTodo(id uint) error {
-      todo, err := m.GetTodoByID(id)
+// DeleteTodo は version が一致する場合のみ Todo をゴミ箱へ移動（論理削除）します。
+// サブタスクは mode（orphan / cascade / block）に従って処理します。
+// cascade でゴミ箱へ移動する子孫タスクは、RestoreTodo で一緒に戻せるように Todo と同じ削除日時にします。
+func (m *TodoModel) DeleteTodo(id uint, version uint, mode string) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", hierarchyLockKey).Error; err != nil {
+                  return err
+            }
+            deletedAt := time.Now()
+            tx = tx.Session(&gorm.Session{NowFunc: func() time.Time { return deletedAt }})
+            // 子タスクも mode によってゴミ箱へ移動したり親が外れたりするので、一緒に記録する
+            descendants, err := liveDescendantIDs(tx, id)
+            if err != nil {
+                  return err
+            }
+            audit, err := auditTodos(tx, TodoEventDeleted, append([]uint{id}, descendants...)...)
+            if err != nil {
+                  return err
+            }
+            if err := deleteSubtasks(tx, id, mode); err != nil {
+                  return err
+            }
+
+            result := tx.Where("id = ? AND version = ?", id, version).Delete(&Todo{})
+            if result.Error != nil {
+                  return result.Error
+            }
+            if result.RowsAffected == 0 {
+                  if err := tx.Select("id").First(&Todo{}, id).Error; err != nil {
+                        return err
+                  }
+                  return ErrVersionMismatch
+            }
+            return audit.record(tx)
+      })
+}
+
+// GetTrashedTodos はゴミ箱に入っている Todo を削除日時の新しい順に返します。
+func (m *TodoModel) GetTrashedTodos() ([]Todo, error) {
+      var todos []Todo
+      // Unscoped を付けると、論理削除されたレコードも検索対象になります。
+      if err := preloadTodo(m.DB.Unscoped()).Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&todos).Error; err != nil {
+            return nil, err
+      }
+      // 子孫タスクも一緒にゴミ箱に入っていることがあるため、進み具合なども論理削除されたレコードを含めて求めます
+      if err := attachComputed(m.DB.Unscoped(), todos); err != nil {
+            return nil, err
+      }
+      return todos, nil
+}
+
+// GetTrashedTodoByID はゴミ箱に入っている Todo を ID で取得します。
+func (m *TodoModel) GetTrashedTodoByID(id uint) (Todo, error) {
+      var todo Todo
+      if err := preloadTodo(m.DB.Unscoped()).Where("id = ?", id).First(&todo).Error; err != nil {
+            return Todo{}, err
+      }
+      if !todo.DeletedAt.Valid {
+            return Todo{}, ErrNotInTrash
+      }
+      todos := []Todo{todo}
+      if err := attachComputed(m.DB.Unscoped(), todos); err != nil {
+            return Todo{}, err
+      }
+      return todos[0], nil
+}
+
+// RestoreTodo はゴミ箱の Todo を元に戻します。
+// 親を削除したときに一緒にゴミ箱へ移動した（削除日時が同じ）子孫タスクも戻します。
+func (m *TodoModel) RestoreTodo(id uint) (Todo, error) {
+      trashed, err := m.GetTrashedTodoByID(id)
+      if err != nil {
+            return Todo{}, err
+      }
+      err = m.DB.Transaction(func(tx *gorm.DB) error {
+            descendants, err := trashedDescendantIDs(tx, id, trashed.DeletedAt.Time)
+            if err != nil {
+                  return err
+            }
+            ids := append([]uint{id}, descendants...)
+            audit, err := auditTodos(tx, TodoEventRestored, ids...)
+            if err != nil {
+                  return err
+            }
+            if err := tx.Unscoped().Model(&Todo{}).Where("id IN ? AND deleted_at IS NOT NULL", ids).Updates(map[string]interface{}{
+                  "deleted_at": nil,
+                  "version":    gorm.Expr("version + 1"),
+            }).Error; err != nil {
+                  return err
+            }
+            return audit.record(tx)
+      })
+      if err != nil {
+            return Todo{}, err
+      }
+      return m.GetTodoByID(id)
+}
+
+// DeleteTodoPermanently はゴミ箱の Todo を version が一致する場合のみ完全に削除します。
+func (m *TodoModel) DeleteTodoPermanently(id uint, version uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            var todo Todo
+            // 削除が終わるまで他のトランザクションから更新されないよう行ロックを取る
+            if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&todo).Error; err != nil {
+                  return err
+            }
+            if !todo.DeletedAt.Valid {
+                  return ErrNotInTrash
+            }
+            if todo.Version != version {
+                  return ErrVersionMismatch
+            }
+            return purgeTodos(tx, []uint{id})
+      })
+}
+
+// PurgeTrash は before より前にゴミ箱へ移動された Todo を完全に削除し、削除した件数を返します。
+func (m *TodoModel) PurgeTrash(before time.Time) (int, error) {
+      var ids []uint
+      if err := m.DB.Unscoped().Model(&Todo{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+            return 0, err
+      }
+      if len(ids) == 0 {
+            return 0, nil
+      }
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            return purgeTodos(tx, ids)
+      })
+      if err != nil {
+            return 0, err
+      }
+      return len(ids), nil
+}
+
+// purgeTodos は Todo と、それに紐づく中間テーブルのレコードを物理削除します。
+// Todo に紐づくテーブルを追加した場合は、ここにも削除処理を追加してください。
+// 履歴（TodoEvent）は監査のために削除せず、purged の履歴を追加します。
+func purgeTodos(tx *gorm.DB, ids []uint) error {
+      audit, err := auditTodos(tx, TodoEventPurged, ids...)
       if err != nil {
             return err
       }
-      return m.DB.Delete(&todo).Error
+      if err := tx.Exec("DELETE FROM user_todos WHERE todo_id IN ?", ids).Error; err != nil {
+            return err
+      }
+      if err := tx.Exec("DELETE FROM todo_tags WHERE todo_id IN ?", ids).Error; err != nil {
+            return err
+      }
+      if err := tx.Where("todo_id IN ?", ids).Delete(&TodoStatusHistory{}).Error; err != nil {
+            return err
+      }
+      if err := tx.Where("todo_id IN ?", ids).Delete(&ChecklistItem{}).Error; err != nil {
+            return err
+      }
+      if err := tx.Where("todo_id IN ?", ids).Delete(&ReminderDelivery{}).Error; err != nil {
+            return err
+      }
+      if err := purgeComments(tx, ids); err != nil {
+            return err
+      }
+      if err := purgeAttachments(tx, ids); err != nil {
+            return err
+      }
+      if err := tx.Where("todo_id IN ?", ids).Delete(&TimeEntry{}).Error; err != nil {
+            return err
+      }
+      // 依存関係は、待っている側・待たれている側のどちらが削除されても残さない
+      if err := tx.Where("todo_id IN ? OR depends_on_id IN ?", ids, ids).Delete(&TodoDependency{}).Error; err != nil {
+            return err
+      }
+      // ゴミ箱に残っている子タスクが、削除された親を指したままにならないようにする
+      if err := tx.Unscoped().Model(&Todo{}).Where("parent_id IN ?", ids).Update("parent_id", nil).Error; err != nil {
+            return err
+      }
+      if err := tx.Unscoped().Where("id IN ?", ids).Delete(&Todo{}).Error; err != nil {
+            return err
+      }
+      return audit.recordPurged(tx)
 }
 
 func (m *TodoModel) CreateUser(user requests.CreateUserInput) (User, error) {
       fmt.Printf("%+v\n", user)
 
+      newUser, err := m.buildUser(user)
+      if err != nil {
+            return User{}, err
+      }
+
+      // 新しいユーザーには個人用のワークスペースを作る
+      err = m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Create(&newUser).Error; err != nil {
+                  return err
+            }
+            _, err := createWorkspace(tx, newUser.ID, newUser.Name)
+            return err
+      })
+      if err != nil {
+            return User{}, err
+      }
+      return newUser, nil
+}
+
+// buildUser は作成するユーザーを入力から組み立てて検証します。
+func (m *TodoModel) buildUser(user requests.CreateUserInput) (User, error) {
       // 既存のユーザーが存在するか確認
       _, err := m.GetUserByEmail(user.Email)
       if err == nil {
@@ -135,14 +689,17 @@ func (m *TodoModel) CreateUser(user requests.CreateUserInput) (User, error) {
             Name:       user.Name,
             Email:      user.Email,
             Password:    user.Password,
+            TimeZone:   user.TimeZone,
       }
-
-      // バリデーション
-      if err := newUser.ValidateUser(); err != nil {
+      if newUser.TimeZone == "" {
+            newUser.TimeZone = DefaultTimeZone()
+      }
+      if _, err := LoadTimeZone(newUser.TimeZone); err != nil {
             return User{}, err
       }
 
-      if err := m.DB.Create(&newUser).Error; err != nil {
+      // バリデーション
+      if err := newUser.ValidateUser(); err != nil {
             return User{}, err
       }
       return newUser, nil
@@ -172,10 +729,16 @@ func (m *TodoModel) UpdateUser(email string, user requests.UpdateUserInput) (Use
       if err != nil {
             return User{}, err
       }
+      if user.TimeZone != "" {
+            if _, err := LoadTimeZone(user.TimeZone); err != nil {
+                  return User{}, err
+            }
+      }
       updatedUser := requests.UpdateUserInput{
             Name:       user.Name,
             Email: user.Email,
             Password:    user.Password,
+            TimeZone:   user.TimeZone,
       }
       if err := m.DB.Model(&existingUser).Updates(updatedUser).Error; err != nil {
             return User{}, err
@@ -183,12 +746,62 @@ func (m *TodoModel) UpdateUser(email string, user requests.UpdateUserInput) (Use
       return existingUser, nil
 }
 
+// GetUserByID は ID でユーザーを取得します。
+func (m *TodoModel) GetUserByID(id uint) (User, error) {
+      var user User
+      if err := m.DB.Where("id = ?", id).First(&user).Error; err != nil {
+            return User{}, err
+      }
+      return user, nil
+}
+
+// UpdateProfile はログインしているユーザー自身の名前とタイムゾーンを変更します。
+func (m *TodoModel) UpdateProfile(userID uint, input requests.UpdateProfileInput) (User, error) {
+      user, err := m.GetUserByID(userID)
+      if err != nil {
+            return User{}, err
+      }
+      updates := map[string]interface{}{}
+      if input.Name != "" {
+            user.Name = input.Name
+            if err := validation.Validate(user.Name, validation.Length(1, 255).Error("Name is too long")); err != nil {
+                  return User{}, err
+            }
+            updates["name"] = input.Name
+      }
+      if input.TimeZone != "" {
+            if _, err := LoadTimeZone(input.TimeZone); err != nil {
+                  return User{}, err
+            }
+            user.TimeZone = input.TimeZone
+            updates["time_zone"] = input.TimeZone
+      }
+      if len(updates) == 0 {
+            return user, nil
+      }
+      if err := m.DB.Model(&user).Updates(updates).Error; err != nil {
+            return User{}, err
+      }
+      return user, nil
+}
+
 func (m *TodoModel) DeleteUserByEmail(email string) error {
       user, err := m.GetUserByEmail(email)
       if err != nil {
             return err
       }
-      return m.DB.Delete(&user).Error
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Where("user_id = ?", user.ID).Delete(&WorkspaceMember{}).Error; err != nil {
+                  return err
+            }
+            if err := tx.Where("user_id = ?", user.ID).Delete(&ProjectMember{}).Error; err != nil {
+                  return err
+            }
+            if err := tx.Exec("DELETE FROM comment_mentions WHERE user_id = ?", user.ID).Error; err != nil {
+                  return err
+            }
+            return tx.Delete(&user).Error
+      })
 }
 
 func (user *User) ValidateUser() error {
@@ -226,22 +839,57 @@ func (m *TodoModel) VerifyPassword(user User, password string) error {
 }
 
 func (m *TodoModel) ConvertTodoToOutput(todo Todo) (requests.GetTodoOutput) {
+      // ゴミ箱に入っている Todo の場合のみ削除日時を返す
+      var deletedAt *time.Time
+      if todo.DeletedAt.Valid {
+            deletedAt = &todo.DeletedAt.Time
+      }
+      tags := []requests.TagOutput{}
+      for _, tag := range todo.Tags {
+            tags = append(tags, m.ConvertTagToOutput(*tag))
+      }
       var users []requests.AuthOutput
-      for _, user := range todo.Users {
-            users = append(users, requests.AuthOutput{
-                  ID:    user.ID,
-                  Name:  user.Name,
-                  Email: user.Email,
-            })
+      for _, assignee := range todo.Assignees {
+            users = append(users, m.ConvertAssigneeToOutput(assignee).User)
+      }
+      // 期限は呼び出したユーザーのタイムゾーンで返す
+      loc := m.location()
+      var occurrenceAt *time.Time
+      if todo.OccurrenceAt != nil {
+            local := localizeDeadline(*todo.OccurrenceAt, todo.DeadlineAllDay, loc)
+            occurrenceAt = &local
       }
       return requests.GetTodoOutput{
             ID:          todo.ID,
+            WorkspaceID: todo.WorkspaceID,
+            ProjectID:   todo.ProjectID,
             Title:       todo.Title,
             Description: todo.Description,
-            Category:    todo.Category,
-            Deadline:    todo.Deadline,
-            State:       todo.State,
+            Tags:        tags,
+            Deadline:    localizeDeadline(todo.Deadline, todo.DeadlineAllDay, loc),
+            DeadlineAllDay: todo.DeadlineAllDay,
+            // 互換性のため、完了扱いのステータスかどうかを state として返す
+            State:       todo.Status.IsDone,
+            Status:      m.ConvertStatusToOutput(todo.Status),
+            StatusChangedAt: todo.StatusChangedAt,
+            Rank:        todo.Rank,
+            ParentID:    todo.ParentID,
+            Priority:    todo.Priority,
+            SeriesID:    todo.SeriesID,
+            OccurrenceAt: occurrenceAt,
+            Urgency:     m.Urgency.UrgencyScore(todo, time.Now()),
+            InterpretedDeadline: convertInterpretedDeadlineToOutput(todo.InterpretedDeadline),
+            Progress:    convertProgressToOutput(todo.Progress),
+            EstimateMinutes: todo.EstimateMinutes,
+            TrackedMinutes: durationMinutes(todo.TimeSpent),
+            Blocked:     todo.Blocked,
+            BlockedBy:   append([]uint{}, todo.BlockedBy...),
+            Checklist:   m.ConvertChecklistToOutput(todo.ChecklistItems),
+            ChecklistSummary: convertChecklistSummaryToOutput(todo.ChecklistItems),
+            Version:     todo.Version,
+            DeletedAt:   deletedAt,
             Users:       users,
+            Assignees:   m.ConvertAssigneesToOutput(todo.Assignees),
       }
 }
 
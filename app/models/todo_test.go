@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"app/requests"
+)
+
+// cascade で削除した子孫タスクは、親を元に戻すと一緒に戻ること。先に別で削除していたものは戻らないこと
+func TestRestoreTodoCascade(t *testing.T) {
+      m, user := newTestModel(t)
+      create := func(title string, parentID *uint) Todo {
+            t.Helper()
+            todo, err := m.CreateTodo(requests.CreateTodoInput{Title: title, ParentID: parentID, Email: user.Email})
+            if err != nil {
+                  t.Fatal(err)
+            }
+            return todo
+      }
+      parent := create("親", nil)
+      child := create("子", &parent.ID)
+      grandchild := create("孫", &child.ID)
+      trashedBefore := create("先に削除した子", &parent.ID)
+
+      if err := m.DeleteTodo(trashedBefore.ID, trashedBefore.Version, SubtaskDeleteCascade); err != nil {
+            t.Fatal(err)
+      }
+      if err := m.DeleteTodo(parent.ID, parent.Version, SubtaskDeleteCascade); err != nil {
+            t.Fatal(err)
+      }
+      for _, id := range []uint{parent.ID, child.ID, grandchild.ID, trashedBefore.ID} {
+            if _, err := m.GetTrashedTodoByID(id); err != nil {
+                  t.Fatalf("todo %d is not in the trash: %v", id, err)
+            }
+      }
+
+      if _, err := m.RestoreTodo(parent.ID); err != nil {
+            t.Fatal(err)
+      }
+      for _, id := range []uint{parent.ID, child.ID, grandchild.ID} {
+            if _, err := m.GetTodoByID(id); err != nil {
+                  t.Errorf("todo %d was not restored: %v", id, err)
+            }
+      }
+      if _, err := m.GetTrashedTodoByID(trashedBefore.ID); err != nil {
+            t.Errorf("todo %d trashed by an earlier delete was restored: %v", trashedBefore.ID, err)
+      }
+}
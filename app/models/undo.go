@@ -0,0 +1,340 @@
+package models
+
+import (
+	"app/pkg/utils"
+	"app/requests"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TodoOperation は 1 回のリクエストで行われた Todo の変更のまとまりです。変更の内容は同じ OperationID の TodoEvent に記録します。
+// 取り消した場合も履歴は変更せず、UndoneAt を設定し、取り消しによる変更を新しい操作として記録します。
+type TodoOperation struct {
+      ID          string     `gorm:"primaryKey;size:32" json:"id"`
+      WorkspaceID uint       `gorm:"not null;index" json:"workspace_id"`
+      ActorID     *uint      `gorm:"index" json:"actor_id"`
+      UndoneAt    *time.Time `json:"undone_at"`
+      CreatedAt   time.Time  `json:"created_at"`
+      // 操作で記録された履歴。DB には保存しません。
+      Events []TodoEvent `gorm:"-" json:"events"`
+}
+
+// UndoResult は取り消しの結果です。
+type UndoResult struct {
+      // 取り消しによる変更の操作の ID。これを取り消すとやり直しになります。
+      OperationID *string
+      TodoIDs     []uint
+}
+
+type operationContextKey struct{}
+
+var (
+      // ErrUndoExpired は取り消せる時間（UndoWindow）を過ぎた操作を取り消そうとした場合に返されます。
+      ErrUndoExpired = errors.New("operation can no longer be undone")
+      // ErrAlreadyUndone は既に取り消した操作をもう一度取り消そうとした場合に返されます。
+      ErrAlreadyUndone = errors.New("operation has already been undone")
+      // ErrUndoConflict は操作の後に、同じ Todo の同じ項目が変更されている場合に返されます。
+      ErrUndoConflict = errors.New("todo has been changed since the operation")
+)
+
+// UndoWindow は操作を取り消せる時間です。UNDO_WINDOW_MINUTES で設定します（デフォルトは10分）。
+func UndoWindow() time.Duration {
+      return time.Duration(utils.GetEnvInt("UNDO_WINDOW_MINUTES", 10)) * time.Minute
+}
+
+// NewOperationID は操作の ID を作ります。
+func NewOperationID() (string, error) {
+      buf := make([]byte, 16)
+      if _, err := rand.Read(buf); err != nil {
+            return "", err
+      }
+      return hex.EncodeToString(buf), nil
+}
+
+// WithOperation は、この TodoModel で行う変更の履歴を operationID の操作として記録する TodoModel を返します。
+func (m *TodoModel) WithOperation(operationID string) *TodoModel {
+      local := *m
+      local.DB = m.DB.WithContext(context.WithValue(m.DB.Statement.Context, operationContextKey{}, operationID))
+      return &local
+}
+
+func operationFromContext(db *gorm.DB) *string {
+      id, ok := db.Statement.Context.Value(operationContextKey{}).(string)
+      if !ok || id == "" {
+            return nil
+      }
+      return &id
+}
+
+// GetUndoableOperations は呼び出したユーザーがこのワークスペースで行った操作のうち、まだ取り消せるものを新しい順に返します。
+func (m *TodoModel) GetUndoableOperations() ([]TodoOperation, error) {
+      var operations []TodoOperation
+      err := m.DB.Where("workspace_id = ? AND actor_id = ? AND undone_at IS NULL AND created_at > ?", m.WorkspaceID, m.UserID, time.Now().Add(-UndoWindow())).
+            Order("created_at DESC").Find(&operations).Error
+      if err != nil {
+            return nil, err
+      }
+      if len(operations) == 0 {
+            return operations, nil
+      }
+
+      ids := make([]string, 0, len(operations))
+      for _, operation := range operations {
+            ids = append(ids, operation.ID)
+      }
+      var events []TodoEvent
+      if err := m.DB.Preload("Actor").Where("operation_id IN ?", ids).Order("id").Find(&events).Error; err != nil {
+            return nil, err
+      }
+      byOperation := map[string][]TodoEvent{}
+      for _, event := range events {
+            byOperation[*event.OperationID] = append(byOperation[*event.OperationID], event)
+      }
+      for i := range operations {
+            operations[i].Events = byOperation[operations[i].ID]
+      }
+      return operations, nil
+}
+
+// UndoOperation は呼び出したユーザーが行った操作を取り消し、変更された Todo を操作の前の状態に戻します。
+// 操作の後に同じ項目が変更されている Todo が 1 つでもあれば ErrUndoConflict を返し、何も戻しません。
+// 作成の取り消しは Todo をゴミ箱へ移動します。
+func (m *TodoModel) UndoOperation(operationID string) (UndoResult, error) {
+      var result UndoResult
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var operation TodoOperation
+            err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+                  Where("id = ? AND workspace_id = ? AND actor_id = ?", operationID, m.WorkspaceID, m.UserID).
+                  First(&operation).Error
+            if err != nil {
+                  return err
+            }
+            if operation.UndoneAt != nil {
+                  return ErrAlreadyUndone
+            }
+            if time.Since(operation.CreatedAt) > UndoWindow() {
+                  return ErrUndoExpired
+            }
+
+            var events []TodoEvent
+            if err := tx.Where("operation_id = ?", operation.ID).Order("id DESC").Find(&events).Error; err != nil {
+                  return err
+            }
+            ids := []uint{}
+            for _, event := range events {
+                  ids = append(ids, event.TodoID)
+            }
+            ids = uniqueIDs(ids)
+            audit, err := auditTodos(tx, TodoEventUndone, ids...)
+            if err != nil {
+                  return err
+            }
+            // 後に記録したものから順に戻す
+            reparented := []uint{}
+            for _, event := range events {
+                  if err := m.revertTodoEvent(tx, event); err != nil {
+                        return err
+                  }
+                  if _, ok := event.Changes["parent_id"]; ok && event.Action != TodoEventCreated {
+                        reparented = append(reparented, event.TodoID)
+                  }
+            }
+            // 親を戻した Todo について、親がゴミ箱に入っていないか、循環していないかをすべて戻した後で確認する
+            for _, id := range uniqueIDs(reparented) {
+                  var todo Todo
+                  if err := tx.Select("id", "parent_id").Where("id = ?", id).Find(&todo).Error; err != nil {
+                        return err
+                  }
+                  if todo.ID == 0 || todo.ParentID == nil {
+                        continue
+                  }
+                  if err := checkParent(tx, id, *todo.ParentID); errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrTodoCycle) {
+                        return fmt.Errorf("%w: todo %d cannot be moved back under todo %d", ErrUndoConflict, id, *todo.ParentID)
+                  } else if err != nil {
+                        return err
+                  }
+            }
+
+            if err := tx.Model(&operation).Update("undone_at", time.Now()).Error; err != nil {
+                  return err
+            }
+            if err := audit.record(tx); err != nil {
+                  return err
+            }
+            result = UndoResult{OperationID: operationFromContext(tx), TodoIDs: ids}
+            return nil
+      })
+      if err != nil {
+            return UndoResult{}, err
+      }
+      return result, nil
+}
+
+// revertTodoEvent は event で変更された項目を変更前の値に戻します。
+// 現在の値が event の変更後の値と異なる項目がある場合は ErrUndoConflict を返します。
+func (m *TodoModel) revertTodoEvent(tx *gorm.DB, event TodoEvent) error {
+      snapshots, err := snapshotTodos(tx, []uint{event.TodoID})
+      if err != nil {
+            return err
+      }
+      current, ok := snapshots[event.TodoID]
+      if !ok {
+            return fmt.Errorf("%w: todo %d no longer exists", ErrUndoConflict, event.TodoID)
+      }
+      values, err := snapshotValues(current)
+      if err != nil {
+            return err
+      }
+      beforeValues := map[string]interface{}{}
+      for field, change := range event.Changes {
+            if !reflect.DeepEqual(values[field], change.After) {
+                  return fmt.Errorf("%w: %s of todo %d", ErrUndoConflict, field, event.TodoID)
+            }
+            beforeValues[field] = change.Before
+      }
+      if err := m.checkProjectWrite(tx, current.ProjectID); err != nil {
+            return err
+      }
+
+      // 作成の取り消しは、作成前の値に戻すのではなくゴミ箱へ移動する
+      if event.Action == TodoEventCreated {
+            children, err := liveDescendantIDs(tx, event.TodoID)
+            if err != nil {
+                  return err
+            }
+            if len(children) > 0 {
+                  return fmt.Errorf("%w: todo %d has subtasks", ErrUndoConflict, event.TodoID)
+            }
+            return tx.Where("id = ?", event.TodoID).Delete(&Todo{}).Error
+      }
+
+      target := current
+      data, err := json.Marshal(beforeValues)
+      if err != nil {
+            return err
+      }
+      if err := json.Unmarshal(data, &target); err != nil {
+            return err
+      }
+
+      updates := map[string]interface{}{"version": gorm.Expr("version + 1")}
+      for field := range event.Changes {
+            switch field {
+            case "title":
+                  updates["title"] = target.Title
+            case "description":
+                  updates["description"] = target.Description
+            case "deadline":
+                  updates["deadline"] = target.Deadline
+            case "deadline_all_day":
+                  updates["deadline_all_day"] = target.DeadlineAllDay
+            case "priority":
+                  updates["priority"] = target.Priority
+            case "estimate_minutes":
+                  updates["estimate_minutes"] = target.EstimateMinutes
+            case "parent_id":
+                  updates["parent_id"] = target.ParentID
+            case "project_id":
+                  if err := m.checkProjectTarget(tx, target.ProjectID); err != nil {
+                        return err
+                  }
+                  updates["project_id"] = target.ProjectID
+            case "deleted":
+                  if target.Deleted {
+                        updates["deleted_at"] = time.Now()
+                  } else {
+                        updates["deleted_at"] = nil
+                  }
+            case "status_id":
+                  if err := restoreTodoStatus(tx, event.TodoID, target.StatusID); err != nil {
+                        return err
+                  }
+            case "tag_ids":
+                  err := replaceTodoTags(tx, event.TodoID, target.TagIDs)
+                  if errors.Is(err, gorm.ErrRecordNotFound) {
+                        return fmt.Errorf("%w: a tag of todo %d has been deleted", ErrUndoConflict, event.TodoID)
+                  }
+                  if err != nil {
+                        return err
+                  }
+            case "assignees":
+                  if err := restoreTodoAssignees(tx, event.TodoID, target.Assignees); err != nil {
+                        return err
+                  }
+            case "depends_on_ids":
+                  if err := replaceTodoDependencies(tx, event.TodoID, target.DependsOnIDs); err != nil {
+                        return err
+                  }
+            }
+      }
+      return tx.Unscoped().Model(&Todo{}).Where("id = ?", event.TodoID).Updates(updates).Error
+}
+
+// restoreTodoStatus は取り消しのために Todo のステータスを戻し、ステータスの履歴を記録します。
+// 元の状態に戻すだけなので、遷移できるかどうかは確認しません。
+func restoreTodoStatus(tx *gorm.DB, todoID uint, statusID uint) error {
+      var status Status
+      err := tx.Where("id = ?", statusID).First(&status).Error
+      if errors.Is(err, gorm.ErrRecordNotFound) {
+            return fmt.Errorf("%w: the previous status of todo %d has been deleted", ErrUndoConflict, todoID)
+      }
+      if err != nil {
+            return err
+      }
+      now := time.Now()
+      if err := tx.Unscoped().Model(&Todo{}).Where("id = ?", todoID).Updates(map[string]interface{}{
+            "status_id":         statusID,
+            "status_changed_at": now,
+      }).Error; err != nil {
+            return err
+      }
+      if err := tx.Model(&TodoStatusHistory{}).Where("todo_id = ? AND left_at IS NULL", todoID).Update("left_at", now).Error; err != nil {
+            return err
+      }
+      return tx.Create(&TodoStatusHistory{TodoID: todoID, StatusID: statusID, EnteredAt: now}).Error
+}
+
+// restoreTodoAssignees は取り消しのために Todo に関わるユーザーを assignees に戻します。
+func restoreTodoAssignees(tx *gorm.DB, todoID uint, assignees []assigneeSnapshot) error {
+      if err := tx.Where("todo_id = ?", todoID).Delete(&TodoAssignee{}).Error; err != nil {
+            return err
+      }
+      if len(assignees) == 0 {
+            return nil
+      }
+      rows := make([]TodoAssignee, 0, len(assignees))
+      for _, assignee := range assignees {
+            rows = append(rows, TodoAssignee{TodoID: todoID, UserID: assignee.UserID, Role: assignee.Role})
+      }
+      return tx.Omit("User").Create(&rows).Error
+}
+
+func (m *TodoModel) ConvertTodoOperationToOutput(operation TodoOperation) requests.TodoOperationOutput {
+      return requests.TodoOperationOutput{
+            ID:        operation.ID,
+            Events:    m.ConvertTodoEventsToOutput(operation.Events),
+            CreatedAt: operation.CreatedAt,
+            ExpiresAt: operation.CreatedAt.Add(UndoWindow()),
+      }
+}
+
+func (m *TodoModel) ConvertTodoOperationsToOutput(operations []TodoOperation) []requests.TodoOperationOutput {
+      output := []requests.TodoOperationOutput{}
+      for _, operation := range operations {
+            output = append(output, m.ConvertTodoOperationToOutput(operation))
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertUndoResultToOutput(result UndoResult) requests.UndoOutput {
+      return requests.UndoOutput{OperationID: result.OperationID, TodoIDs: result.TodoIDs}
+}
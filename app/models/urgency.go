@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"app/pkg/utils"
+)
+
+const (
+      // PriorityHighest は最も高い優先度（P0）です。
+      PriorityHighest = 0
+      // PriorityLowest は最も低い優先度（P4）です。
+      PriorityLowest = 4
+      // PriorityDefault は優先度を指定しなかった場合の優先度（P2）です。
+      PriorityDefault = 2
+)
+
+const (
+      // TodoSortUrgency は緊急度スコアの高い順に並べます。
+      TodoSortUrgency = "urgency"
+      // TodoSortPriority は優先度の高い順に並べます。
+      TodoSortPriority = "priority"
+      // TodoSortDeadline は期限の早い順に並べます。
+      TodoSortDeadline = "deadline"
+)
+
+var (
+      // ErrInvalidPriority は 0〜4 以外の優先度が指定された場合に返されます。
+      ErrInvalidPriority = errors.New("priority must be between 0 (P0) and 4 (P4)")
+      // ErrInvalidTodoSort は対応していない並び順が指定された場合に返されます。
+      ErrInvalidTodoSort = errors.New("sort must be urgency, priority or deadline")
+)
+
+// UrgencyWeights は緊急度スコアの計算に使う重みです。
+type UrgencyWeights struct {
+      // 優先度が高いほど加点
+      Priority float64
+      // 期限が近いほど加点（期限切れは満点）
+      Deadline float64
+      // 作成から時間がたっているほど加点
+      Age float64
+      // 他のタスクの完了待ちの場合に加算（通常はマイナスの値）
+      Blocked float64
+      // この日数より先の期限は加点しない
+      DeadlineHorizonDays float64
+      // この日数で Age の加点が満点になる
+      AgeHorizonDays float64
+}
+
+// LoadUrgencyWeights は環境変数 URGENCY_WEIGHT_* から重みを読み込みます。
+func LoadUrgencyWeights() UrgencyWeights {
+      return UrgencyWeights{
+            Priority:            utils.GetEnvFloat("URGENCY_WEIGHT_PRIORITY", 50),
+            Deadline:            utils.GetEnvFloat("URGENCY_WEIGHT_DEADLINE", 40),
+            Age:                 utils.GetEnvFloat("URGENCY_WEIGHT_AGE", 10),
+            Blocked:             utils.GetEnvFloat("URGENCY_WEIGHT_BLOCKED", -30),
+            DeadlineHorizonDays: utils.GetEnvFloat("URGENCY_DEADLINE_HORIZON_DAYS", 14),
+            AgeHorizonDays:      utils.GetEnvFloat("URGENCY_AGE_HORIZON_DAYS", 30),
+      }
+}
+
+// UrgencyScore は優先度・期限までの近さ・経過日数・完了待ちかどうかから緊急度スコアを計算します。
+// 完了扱いのステータスの Todo は 0 になります。
+func (w UrgencyWeights) UrgencyScore(todo Todo, now time.Time) float64 {
+      if todo.Status.IsDone {
+            return 0
+      }
+
+      score := w.Priority * float64(PriorityLowest-todo.Priority) / float64(PriorityLowest-PriorityHighest)
+
+      if !todo.Deadline.IsZero() && w.DeadlineHorizonDays > 0 {
+            daysLeft := todo.Deadline.Sub(now).Hours() / 24
+            score += w.Deadline * clamp01(1-daysLeft/w.DeadlineHorizonDays)
+      }
+
+      if !todo.CreatedAt.IsZero() && w.AgeHorizonDays > 0 {
+            ageDays := now.Sub(todo.CreatedAt).Hours() / 24
+            score += w.Age * clamp01(ageDays/w.AgeHorizonDays)
+      }
+
+      if todo.Blocked {
+            score += w.Blocked
+      }
+      return math.Round(score*100) / 100
+}
+
+// sortByUrgency は緊急度スコアの高い順に並び替えます。同じスコアの場合は期限の早い順で、sort=deadline と同じく期限の無い Todo は後にします。
+func (w UrgencyWeights) sortByUrgency(todos []Todo, now time.Time) {
+      scores := make(map[uint]float64, len(todos))
+      for _, todo := range todos {
+            scores[todo.ID] = w.UrgencyScore(todo, now)
+      }
+      sort.SliceStable(todos, func(i, j int) bool {
+            if scores[todos[i].ID] != scores[todos[j].ID] {
+                  return scores[todos[i].ID] > scores[todos[j].ID]
+            }
+            if todos[i].Deadline.IsZero() != todos[j].Deadline.IsZero() {
+                  return !todos[i].Deadline.IsZero()
+            }
+            return todos[i].Deadline.Before(todos[j].Deadline)
+      })
+}
+
+func validatePriority(priority int) error {
+      if priority < PriorityHighest || priority > PriorityLowest {
+            return ErrInvalidPriority
+      }
+      return nil
+}
+
+func clamp01(value float64) float64 {
+      return math.Max(0, math.Min(1, value))
+}
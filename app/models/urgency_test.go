@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// 同じスコアの Todo は期限の早い順で、期限の無い Todo は最後になること
+func TestSortByUrgencyTieBreak(t *testing.T) {
+      now := time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC)
+      todo := func(id uint, deadline time.Time) Todo {
+            var todo Todo
+            todo.ID = id
+            todo.Deadline = deadline
+            return todo
+      }
+      todos := []Todo{
+            todo(1, time.Time{}),
+            todo(2, now.AddDate(0, 0, 3)),
+            todo(3, time.Time{}),
+            todo(4, now.AddDate(0, 0, 1)),
+      }
+      // 重みがすべて 0 なので、どの Todo もスコアは同じ
+      UrgencyWeights{}.sortByUrgency(todos, now)
+
+      want := []uint{4, 2, 1, 3}
+      for i, todo := range todos {
+            if todo.ID != want[i] {
+                  t.Fatalf("order = %v, want %v", todoIDs(todos), want)
+            }
+      }
+}
+
+func todoIDs(todos []Todo) []uint {
+      ids := make([]uint, len(todos))
+      for i, todo := range todos {
+            ids[i] = todo.ID
+      }
+      return ids
+}
@@ -0,0 +1,595 @@
+package models
+
+import (
+	"app/requests"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+      // WorkspaceRoleOwner はワークスペースの持ち主です。ワークスペースを削除できます。
+      WorkspaceRoleOwner = "owner"
+      // WorkspaceRoleAdmin はワークスペースの名前やメンバーを管理できます。
+      WorkspaceRoleAdmin = "admin"
+      // WorkspaceRoleMember は Todo やタグ、ステータスを扱えます。
+      WorkspaceRoleMember = "member"
+)
+
+// Workspace はチームごとの Todo の置き場所です。Todo、タグ、ステータス、ボードはワークスペースごとに分かれています。
+type Workspace struct {
+      ID        uint      `gorm:"primary_key" json:"id"`
+      Name      string    `gorm:"not null" json:"name"`
+      CreatedAt time.Time `json:"created_at"`
+      UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkspaceMember はワークスペースのメンバーと役割です。
+type WorkspaceMember struct {
+      WorkspaceID uint      `gorm:"primaryKey;autoIncrement:false" json:"workspace_id"`
+      UserID      uint      `gorm:"primaryKey;autoIncrement:false;index" json:"user_id"`
+      Role        string    `gorm:"not null;default:'member'" json:"role"`
+      Workspace   Workspace `json:"workspace"`
+      User        User      `json:"user"`
+      CreatedAt   time.Time `json:"created_at"`
+}
+
+var (
+      // ErrWorkspaceRequired はワークスペースを指定せずにワークスペースごとのデータを扱おうとした場合に返されます。
+      ErrWorkspaceRequired = errors.New("workspace is required")
+      // ErrCrossWorkspace は他のワークスペースのデータを使おうとした場合に返されます。
+      ErrCrossWorkspace = errors.New("cannot use data of another workspace")
+      // ErrNotWorkspaceMember は呼び出したユーザーがワークスペースのメンバーでない場合に返されます。
+      ErrNotWorkspaceMember = errors.New("you are not a member of the workspace")
+      // ErrWorkspaceForbidden はワークスペースの役割が足りない場合に返されます。
+      ErrWorkspaceForbidden = errors.New("your workspace role does not allow this operation")
+      // ErrInvalidWorkspaceRole は対応していない役割が指定された場合に返されます。
+      ErrInvalidWorkspaceRole = errors.New("role must be owner, admin or member")
+      // ErrLastWorkspaceOwner は持ち主がいなくなる変更をしようとした場合に返されます。
+      ErrLastWorkspaceOwner = errors.New("workspace must have at least one owner")
+      // ErrWorkspaceNotEmpty は Todo が残っているワークスペースを削除しようとした場合に返されます。
+      ErrWorkspaceNotEmpty = errors.New("workspace still has todos")
+      // ErrAssigneeNotMember は Todo のワークスペースのメンバーでないユーザーを担当者にしようとした場合に返されます。
+      ErrAssigneeNotMember = errors.New("user is not a member of the workspace")
+      // ErrAlreadyWorkspaceMember は既にメンバーのユーザーを追加しようとした場合に返されます。
+      ErrAlreadyWorkspaceMember = errors.New("user is already a member of the workspace")
+      // ErrNoWorkspace はどのワークスペースにも参加していないユーザーが、ワークスペースを指定せずにワークスペースごとのデータを扱おうとした場合に返されます。
+      ErrNoWorkspace = errors.New("you are not a member of any workspace, create one with POST /api/workspaces")
+)
+
+// workspaceScoped はワークスペースごとに分かれているモデルです。
+// これらのモデルの読み書きには、RegisterWorkspaceScope で登録したコールバックが自動でワークスペースの条件を付けます。
+type workspaceScoped interface {
+      workspaceScoped()
+}
+
+func (Todo) workspaceScoped()             {}
+func (Status) workspaceScoped()           {}
+func (Tag) workspaceScoped()              {}
+func (Board) workspaceScoped()            {}
+func (RecurrenceSeries) workspaceScoped() {}
+func (Project) workspaceScoped()          {}
+func (TimeEntry) workspaceScoped()        {}
+
+type workspaceContextKey struct{}
+type acrossWorkspacesContextKey struct{}
+
+// RegisterWorkspaceScope は、ワークスペースごとに分かれているモデルの読み書きを、
+// WithWorkspace で指定したワークスペースに限定するコールバックを登録します。
+// ワークスペースが指定されていない場合は ErrWorkspaceRequired になるので、ハンドラーで指定し忘れても他のチームのデータは見えません。
+// 生の SQL（Raw、Exec）には条件を付けないので、ワークスペースで絞り込んだ ID に対してだけ使ってください。
+func RegisterWorkspaceScope(db *gorm.DB) error {
+      callbacks := db.Callback()
+      if err := callbacks.Query().Before("gorm:query").Register("workspace:query", scopeWorkspace); err != nil {
+            return err
+      }
+      if err := callbacks.Row().Before("gorm:row").Register("workspace:row", scopeWorkspace); err != nil {
+            return err
+      }
+      if err := callbacks.Update().Before("gorm:update").Register("workspace:update", scopeWorkspace); err != nil {
+            return err
+      }
+      if err := callbacks.Delete().Before("gorm:delete").Register("workspace:delete", scopeWorkspace); err != nil {
+            return err
+      }
+      return callbacks.Create().Before("gorm:create").Register("workspace:create", assignWorkspace)
+}
+
+// WithWorkspace は workspaceID のワークスペースのデータだけを扱う TodoModel を返します。
+func (m *TodoModel) WithWorkspace(workspaceID uint) *TodoModel {
+      local := *m
+      local.WorkspaceID = workspaceID
+      local.DB = withWorkspace(m.DB, workspaceID)
+      return &local
+}
+
+// AcrossWorkspaces はすべてのワークスペースのデータを扱う TodoModel を返します。
+// 定期実行するジョブのように、特定のユーザーのリクエストではない処理に使います。
+func (m *TodoModel) AcrossWorkspaces() *TodoModel {
+      local := *m
+      local.WorkspaceID = 0
+      local.DB = acrossWorkspaces(m.DB)
+      return &local
+}
+
+func withWorkspace(db *gorm.DB, workspaceID uint) *gorm.DB {
+      return db.WithContext(context.WithValue(db.Statement.Context, workspaceContextKey{}, workspaceID))
+}
+
+// acrossWorkspaces は db（トランザクションの中のものも）で、ワークスペースの条件を付けずに読み書きするようにします。
+func acrossWorkspaces(db *gorm.DB) *gorm.DB {
+      return db.WithContext(context.WithValue(db.Statement.Context, acrossWorkspacesContextKey{}, true))
+}
+
+func workspaceFromContext(db *gorm.DB) (uint, bool) {
+      id, ok := db.Statement.Context.Value(workspaceContextKey{}).(uint)
+      return id, ok
+}
+
+func isWorkspaceScoped(db *gorm.DB) bool {
+      if db.Statement.Schema == nil {
+            return false
+      }
+      _, ok := reflect.New(db.Statement.Schema.ModelType).Interface().(workspaceScoped)
+      return ok
+}
+
+func isAcrossWorkspaces(db *gorm.DB) bool {
+      across, _ := db.Statement.Context.Value(acrossWorkspacesContextKey{}).(bool)
+      return across
+}
+
+// scopeWorkspace は読み込み・更新・削除の条件にワークスペースを加えます。
+func scopeWorkspace(db *gorm.DB) {
+      if db.Error != nil || db.Statement.SQL.Len() > 0 || !isWorkspaceScoped(db) || isAcrossWorkspaces(db) {
+            return
+      }
+      id, ok := workspaceFromContext(db)
+      if !ok {
+            db.AddError(ErrWorkspaceRequired)
+            return
+      }
+      db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+            clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "workspace_id"}, Value: id},
+      }})
+}
+
+// assignWorkspace は作成するレコードにワークスペースを設定します。既に他のワークスペースが設定されている場合はエラーにします。
+func assignWorkspace(db *gorm.DB) {
+      if db.Error != nil || !isWorkspaceScoped(db) {
+            return
+      }
+      field := db.Statement.Schema.LookUpField("WorkspaceID")
+      if field == nil {
+            return
+      }
+      ctx := db.Statement.Context
+      id, hasWorkspace := workspaceFromContext(db)
+      assign := func(value reflect.Value) {
+            current, zero := field.ValueOf(ctx, value)
+            switch {
+            case !zero:
+                  if hasWorkspace && current.(uint) != id {
+                        db.AddError(ErrCrossWorkspace)
+                  }
+            case hasWorkspace:
+                  if err := field.Set(ctx, value, id); err != nil {
+                        db.AddError(err)
+                  }
+            default:
+                  // AcrossWorkspaces で作成する場合も、ワークスペースは呼び出し側で設定しておく必要がある
+                  db.AddError(ErrWorkspaceRequired)
+            }
+      }
+
+      value := reflect.Indirect(db.Statement.ReflectValue)
+      switch value.Kind() {
+      case reflect.Slice, reflect.Array:
+            for i := 0; i < value.Len(); i++ {
+                  assign(reflect.Indirect(value.Index(i)))
+            }
+      case reflect.Struct:
+            assign(value)
+      }
+}
+
+// GetWorkspaces は userID のユーザーが参加しているワークスペースを、参加した順に返します。
+func (m *TodoModel) GetWorkspaces(userID uint) ([]WorkspaceMember, error) {
+      var members []WorkspaceMember
+      if err := m.DB.Preload("Workspace").Where("user_id = ?", userID).Order("created_at, workspace_id").Find(&members).Error; err != nil {
+            return nil, err
+      }
+      return members, nil
+}
+
+// GetWorkspaceMember は userID のユーザーの workspaceID のワークスペースでの役割を返します。メンバーでない場合は ErrNotWorkspaceMember を返します。
+func (m *TodoModel) GetWorkspaceMember(workspaceID uint, userID uint) (WorkspaceMember, error) {
+      var member WorkspaceMember
+      err := m.DB.Preload("Workspace").Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error
+      if errors.Is(err, gorm.ErrRecordNotFound) {
+            return WorkspaceMember{}, ErrNotWorkspaceMember
+      }
+      if err != nil {
+            return WorkspaceMember{}, err
+      }
+      return member, nil
+}
+
+// GetDefaultWorkspaceMember は、ワークスペースが指定されていない場合に使う、ユーザーが最初に参加したワークスペースを返します。
+func (m *TodoModel) GetDefaultWorkspaceMember(userID uint) (WorkspaceMember, error) {
+      members, err := m.GetWorkspaces(userID)
+      if err != nil {
+            return WorkspaceMember{}, err
+      }
+      if len(members) == 0 {
+            return WorkspaceMember{}, ErrNoWorkspace
+      }
+      return members[0], nil
+}
+
+// CreateWorkspace はワークスペースを作成します。作成したユーザーが持ち主になり、デフォルトのステータスが作成されます。
+func (m *TodoModel) CreateWorkspace(userID uint, input requests.WorkspaceInput) (WorkspaceMember, error) {
+      var member WorkspaceMember
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            var err error
+            member, err = createWorkspace(tx, userID, strings.TrimSpace(input.Name))
+            return err
+      })
+      if err != nil {
+            return WorkspaceMember{}, err
+      }
+      return member, nil
+}
+
+func createWorkspace(tx *gorm.DB, userID uint, name string) (WorkspaceMember, error) {
+      workspace := Workspace{Name: name}
+      if err := tx.Create(&workspace).Error; err != nil {
+            return WorkspaceMember{}, err
+      }
+      member := WorkspaceMember{WorkspaceID: workspace.ID, UserID: userID, Role: WorkspaceRoleOwner}
+      if err := tx.Omit("Workspace", "User").Create(&member).Error; err != nil {
+            return WorkspaceMember{}, err
+      }
+      if err := EnsureDefaultStatuses(withWorkspace(tx, workspace.ID), workspace.ID); err != nil {
+            return WorkspaceMember{}, err
+      }
+      member.Workspace = workspace
+      return member, nil
+}
+
+// ensurePersonalWorkspace は、ワークスペースから外れてどのワークスペースにも参加していないユーザーに、個人用のワークスペースを作ります。
+// 参加しているワークスペースが無いユーザーを、他のユーザーと同じワークスペースに入れないためです。
+func ensurePersonalWorkspace(tx *gorm.DB, userIDs ...uint) error {
+      for _, userID := range userIDs {
+            var count int64
+            if err := tx.Model(&WorkspaceMember{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+                  return err
+            }
+            if count > 0 {
+                  continue
+            }
+            var user User
+            if err := tx.Select("id", "name").Where("id = ?", userID).First(&user).Error; err != nil {
+                  return err
+            }
+            if _, err := createWorkspace(tx, user.ID, user.Name); err != nil {
+                  return err
+            }
+      }
+      return nil
+}
+
+// UpdateWorkspace はワークスペースの名前を変更します。管理者以上の役割が必要です。
+func (m *TodoModel) UpdateWorkspace(workspaceID uint, callerID uint, input requests.WorkspaceInput) (Workspace, error) {
+      if err := m.requireWorkspaceRole(m.DB, workspaceID, callerID, WorkspaceRoleOwner, WorkspaceRoleAdmin); err != nil {
+            return Workspace{}, err
+      }
+      var workspace Workspace
+      if err := m.DB.Where("id = ?", workspaceID).First(&workspace).Error; err != nil {
+            return Workspace{}, err
+      }
+      workspace.Name = strings.TrimSpace(input.Name)
+      if err := m.DB.Save(&workspace).Error; err != nil {
+            return Workspace{}, err
+      }
+      return workspace, nil
+}
+
+// DeleteWorkspace はワークスペースを削除します。持ち主の役割が必要で、Todo（ゴミ箱を含む）が残っている場合は削除できません。
+func (m *TodoModel) DeleteWorkspace(workspaceID uint, callerID uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := m.requireWorkspaceRole(tx, workspaceID, callerID, WorkspaceRoleOwner); err != nil {
+                  return err
+            }
+            scoped := withWorkspace(tx, workspaceID)
+            var count int64
+            if err := scoped.Unscoped().Model(&Todo{}).Count(&count).Error; err != nil {
+                  return err
+            }
+            if count > 0 {
+                  return ErrWorkspaceNotEmpty
+            }
+
+            var statusIDs []uint
+            if err := scoped.Model(&Status{}).Pluck("id", &statusIDs).Error; err != nil {
+                  return err
+            }
+            if len(statusIDs) > 0 {
+                  if err := tx.Exec("DELETE FROM status_transitions WHERE from_status_id IN ? OR to_status_id IN ?", statusIDs, statusIDs).Error; err != nil {
+                        return err
+                  }
+            }
+            if err := tx.Exec("DELETE FROM project_members WHERE project_id IN (SELECT id FROM projects WHERE workspace_id = ?)", workspaceID).Error; err != nil {
+                  return err
+            }
+            for _, model := range []interface{}{&Status{}, &Tag{}, &Board{}, &RecurrenceSeries{}, &Project{}} {
+                  if err := scoped.Where("workspace_id = ?", workspaceID).Delete(model).Error; err != nil {
+                        return err
+                  }
+            }
+            if err := tx.Where("workspace_id = ?", workspaceID).Delete(&WorkspaceInvitation{}).Error; err != nil {
+                  return err
+            }
+            var userIDs []uint
+            if err := tx.Model(&WorkspaceMember{}).Where("workspace_id = ?", workspaceID).Pluck("user_id", &userIDs).Error; err != nil {
+                  return err
+            }
+            if err := tx.Where("workspace_id = ?", workspaceID).Delete(&WorkspaceMember{}).Error; err != nil {
+                  return err
+            }
+            if err := tx.Where("id = ?", workspaceID).Delete(&Workspace{}).Error; err != nil {
+                  return err
+            }
+            return ensurePersonalWorkspace(tx, userIDs...)
+      })
+}
+
+// GetWorkspaceMembers はワークスペースのメンバーを返します。呼び出したユーザーがメンバーである必要があります。
+func (m *TodoModel) GetWorkspaceMembers(workspaceID uint, callerID uint) ([]WorkspaceMember, error) {
+      if _, err := m.GetWorkspaceMember(workspaceID, callerID); err != nil {
+            return nil, err
+      }
+      var members []WorkspaceMember
+      if err := m.DB.Preload("User").Where("workspace_id = ?", workspaceID).Order("created_at, user_id").Find(&members).Error; err != nil {
+            return nil, err
+      }
+      return members, nil
+}
+
+// AddWorkspaceMember は既存のユーザーをワークスペースに追加します。管理者以上の役割が必要で、持ち主を追加できるのは持ち主だけです。
+func (m *TodoModel) AddWorkspaceMember(workspaceID uint, callerID uint, input requests.WorkspaceMemberInput) (WorkspaceMember, error) {
+      var member WorkspaceMember
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            role := input.Role
+            if role == "" {
+                  role = WorkspaceRoleMember
+            }
+            if err := m.checkGrantWorkspaceRole(tx, workspaceID, callerID, role); err != nil {
+                  return err
+            }
+
+            var user User
+            switch {
+            case input.UserID != 0:
+                  if err := tx.Where("id = ?", input.UserID).First(&user).Error; err != nil {
+                        return err
+                  }
+            case input.Email != "":
+                  if err := tx.Where("email = ?", input.Email).First(&user).Error; err != nil {
+                        return err
+                  }
+            default:
+                  return ErrAssigneeUserRequired
+            }
+            var count int64
+            if err := tx.Model(&WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", workspaceID, user.ID).Count(&count).Error; err != nil {
+                  return err
+            }
+            if count > 0 {
+                  return ErrAlreadyWorkspaceMember
+            }
+
+            member = WorkspaceMember{WorkspaceID: workspaceID, UserID: user.ID, Role: role}
+            if err := tx.Omit("Workspace", "User").Create(&member).Error; err != nil {
+                  return err
+            }
+            member.User = user
+            return nil
+      })
+      if err != nil {
+            return WorkspaceMember{}, err
+      }
+      return member, nil
+}
+
+// UpdateWorkspaceMember はメンバーの役割を変更します。管理者以上の役割が必要で、持ち主の役割を変えられるのは持ち主だけです。
+func (m *TodoModel) UpdateWorkspaceMember(workspaceID uint, callerID uint, userID uint, role string) (WorkspaceMember, error) {
+      var member WorkspaceMember
+      err := m.DB.Transaction(func(tx *gorm.DB) error {
+            if err := m.checkGrantWorkspaceRole(tx, workspaceID, callerID, role); err != nil {
+                  return err
+            }
+            if err := tx.Preload("User").Clauses(clause.Locking{Strength: "UPDATE"}).
+                  Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+                  return err
+            }
+            if member.Role == WorkspaceRoleOwner && role != WorkspaceRoleOwner {
+                  if err := m.requireWorkspaceRole(tx, workspaceID, callerID, WorkspaceRoleOwner); err != nil {
+                        return err
+                  }
+                  if err := checkOtherWorkspaceOwner(tx, workspaceID, userID); err != nil {
+                        return err
+                  }
+            }
+            member.Role = role
+            return tx.Model(&WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Update("role", role).Error
+      })
+      if err != nil {
+            return WorkspaceMember{}, err
+      }
+      return member, nil
+}
+
+// RemoveWorkspaceMember はメンバーをワークスペースから外します。自分で抜ける場合を除き、管理者以上の役割が必要です。
+// 外したユーザーは、ワークスペースの Todo の担当者・ウォッチャーとプロジェクトのメンバーからも外れます。持ち主になっている Todo はそのままです。
+func (m *TodoModel) RemoveWorkspaceMember(workspaceID uint, callerID uint, userID uint) error {
+      return m.DB.Transaction(func(tx *gorm.DB) error {
+            if callerID != userID {
+                  if err := m.requireWorkspaceRole(tx, workspaceID, callerID, WorkspaceRoleOwner, WorkspaceRoleAdmin); err != nil {
+                        return err
+                  }
+            }
+            var member WorkspaceMember
+            if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+                  Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+                  return err
+            }
+            if member.Role == WorkspaceRoleOwner {
+                  if callerID != userID {
+                        if err := m.requireWorkspaceRole(tx, workspaceID, callerID, WorkspaceRoleOwner); err != nil {
+                              return err
+                        }
+                  }
+                  if err := checkOtherWorkspaceOwner(tx, workspaceID, userID); err != nil {
+                        return err
+                  }
+            }
+            // 外れる担当者を Todo の履歴に残す
+            var todoIDs []uint
+            if err := tx.Raw(`SELECT todo_id FROM user_todos WHERE user_id = ? AND role <> ?
+                  AND todo_id IN (SELECT id FROM todos WHERE workspace_id = ?)`, userID, AssigneeRoleOwner, workspaceID).Scan(&todoIDs).Error; err != nil {
+                  return err
+            }
+            scoped := withActor(withWorkspace(tx, workspaceID), callerID)
+            audit, err := auditTodos(scoped, TodoEventAssigneesChanged, todoIDs...)
+            if err != nil {
+                  return err
+            }
+            if err := tx.Exec("DELETE FROM user_todos WHERE user_id = ? AND role <> ? AND todo_id IN ?", userID, AssigneeRoleOwner, append(todoIDs, 0)).Error; err != nil {
+                  return err
+            }
+            if len(todoIDs) > 0 {
+                  if err := tx.Exec("UPDATE todos SET version = version + 1 WHERE id IN ?", todoIDs).Error; err != nil {
+                        return err
+                  }
+            }
+            if err := audit.record(scoped); err != nil {
+                  return err
+            }
+            if err := tx.Exec("DELETE FROM project_members WHERE user_id = ? AND project_id IN (SELECT id FROM projects WHERE workspace_id = ?)", userID, workspaceID).Error; err != nil {
+                  return err
+            }
+            if err := tx.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Delete(&WorkspaceMember{}).Error; err != nil {
+                  return err
+            }
+            return ensurePersonalWorkspace(tx, userID)
+      })
+}
+
+// requireWorkspaceRole は callerID のユーザーが roles のいずれかの役割でワークスペースに参加していることを確認します。
+func (m *TodoModel) requireWorkspaceRole(tx *gorm.DB, workspaceID uint, callerID uint, roles ...string) error {
+      var member WorkspaceMember
+      err := tx.Where("workspace_id = ? AND user_id = ?", workspaceID, callerID).First(&member).Error
+      if errors.Is(err, gorm.ErrRecordNotFound) {
+            return ErrNotWorkspaceMember
+      }
+      if err != nil {
+            return err
+      }
+      for _, role := range roles {
+            if member.Role == role {
+                  return nil
+            }
+      }
+      return ErrWorkspaceForbidden
+}
+
+// checkGrantWorkspaceRole は callerID のユーザーが role を与えられるかを確認します。持ち主を与えられるのは持ち主だけです。
+func (m *TodoModel) checkGrantWorkspaceRole(tx *gorm.DB, workspaceID uint, callerID uint, role string) error {
+      if err := validateWorkspaceRole(role); err != nil {
+            return err
+      }
+      if role == WorkspaceRoleOwner {
+            return m.requireWorkspaceRole(tx, workspaceID, callerID, WorkspaceRoleOwner)
+      }
+      return m.requireWorkspaceRole(tx, workspaceID, callerID, WorkspaceRoleOwner, WorkspaceRoleAdmin)
+}
+
+func checkOtherWorkspaceOwner(tx *gorm.DB, workspaceID uint, userID uint) error {
+      var count int64
+      if err := tx.Model(&WorkspaceMember{}).Where("workspace_id = ? AND user_id <> ? AND role = ?", workspaceID, userID, WorkspaceRoleOwner).Count(&count).Error; err != nil {
+            return err
+      }
+      if count == 0 {
+            return ErrLastWorkspaceOwner
+      }
+      return nil
+}
+
+// checkWorkspaceMembers は userIDs のユーザーがすべてワークスペースのメンバーであることを確認します。
+func checkWorkspaceMembers(tx *gorm.DB, workspaceID uint, userIDs []uint) error {
+      userIDs = uniqueIDs(userIDs)
+      if len(userIDs) == 0 {
+            return nil
+      }
+      var count int64
+      if err := tx.Model(&WorkspaceMember{}).Where("workspace_id = ? AND user_id IN ?", workspaceID, userIDs).Count(&count).Error; err != nil {
+            return err
+      }
+      if int(count) != len(userIDs) {
+            return ErrAssigneeNotMember
+      }
+      return nil
+}
+
+func validateWorkspaceRole(role string) error {
+      switch role {
+      case WorkspaceRoleOwner, WorkspaceRoleAdmin, WorkspaceRoleMember:
+            return nil
+      default:
+            return ErrInvalidWorkspaceRole
+      }
+}
+
+func (m *TodoModel) ConvertWorkspaceToOutput(member WorkspaceMember) requests.WorkspaceOutput {
+      return requests.WorkspaceOutput{
+            ID:        member.Workspace.ID,
+            Name:      member.Workspace.Name,
+            Role:      member.Role,
+            CreatedAt: member.Workspace.CreatedAt,
+      }
+}
+
+func (m *TodoModel) ConvertWorkspacesToOutput(members []WorkspaceMember) []requests.WorkspaceOutput {
+      output := []requests.WorkspaceOutput{}
+      for _, member := range members {
+            output = append(output, m.ConvertWorkspaceToOutput(member))
+      }
+      return output
+}
+
+func (m *TodoModel) ConvertWorkspaceMemberToOutput(member WorkspaceMember) requests.WorkspaceMemberOutput {
+      return requests.WorkspaceMemberOutput{
+            User: requests.AuthOutput{
+                  ID:       member.User.ID,
+                  Name:     member.User.Name,
+                  Email:    member.User.Email,
+                  TimeZone: member.User.TimeZone,
+            },
+            Role:     member.Role,
+            JoinedAt: member.CreatedAt,
+      }
+}
+
+func (m *TodoModel) ConvertWorkspaceMembersToOutput(members []WorkspaceMember) []requests.WorkspaceMemberOutput {
+      output := []requests.WorkspaceMemberOutput{}
+      for _, member := range members {
+            output = append(output, m.ConvertWorkspaceMemberToOutput(member))
+      }
+      return output
+}
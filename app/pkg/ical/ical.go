@@ -0,0 +1,110 @@
+// Package ical は iCalendar（RFC 5545）形式の読み書きを行います。
+package ical
+
+import (
+	"strings"
+	"time"
+)
+
+// Property は VTODO などのコンポーネントの 1 行（プロパティ）です。
+// Params には "VALUE=DATE" のようなパラメータを入れます。
+type Property struct {
+	Name   string
+	Params []string
+	Value  string
+}
+
+// Component は VCALENDAR、VTODO、VEVENT などのコンポーネントです。
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// Add はプロパティを追加します。value はそのまま出力するので、テキストの場合は AddText を使ってください。
+func (c *Component) Add(name string, value string, params ...string) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// AddText はテキストのプロパティを、カンマやセミコロン、改行をエスケープして追加します。
+func (c *Component) AddText(name string, value string) {
+	c.Add(name, EscapeText(value))
+}
+
+// AddTime は日時のプロパティを UTC で追加します。
+func (c *Component) AddTime(name string, t time.Time) {
+	c.Add(name, t.UTC().Format("20060102T150405Z"))
+}
+
+// AddDate は日付だけのプロパティ（終日）を追加します。
+func (c *Component) AddDate(name string, t time.Time) {
+	c.Add(name, t.Format("20060102"), "VALUE=DATE")
+}
+
+// Get は name のプロパティを最初に見つかったものから返します。
+func (c *Component) Get(name string) (Property, bool) {
+	for _, property := range c.Properties {
+		if property.Name == name {
+			return property, true
+		}
+	}
+	return Property{}, false
+}
+
+// Encode は iCalendar 形式の文字列にします。改行は CRLF で、75 オクテットを超える行は折り返します。
+func (c *Component) Encode() string {
+	var b strings.Builder
+	c.encode(&b)
+	return b.String()
+}
+
+func (c *Component) encode(b *strings.Builder) {
+	writeLine(b, "BEGIN:"+c.Name)
+	for _, property := range c.Properties {
+		line := property.Name
+		for _, param := range property.Params {
+			line += ";" + param
+		}
+		writeLine(b, line+":"+property.Value)
+	}
+	for _, child := range c.Components {
+		child.encode(b)
+	}
+	writeLine(b, "END:"+c.Name)
+}
+
+// writeLine は 75 オクテットごとに行を折り返して書き込みます。マルチバイト文字の途中では折り返しません。
+func writeLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// 2 行目以降は先頭の空白の分だけ短くする
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+// EscapeText は TEXT 型の値をエスケープします。
+func EscapeText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// UnescapeText は EscapeText の逆です。
+func UnescapeText(value string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(value)
+}
@@ -0,0 +1,118 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain text", "plain text"},
+		{"a,b;c", `a\,b\;c`},
+		{`C:\path`, `C:\\path`},
+		{"line1\nline2", `line1\nline2`},
+		{"line1\r\nline2", `line1\nline2`},
+		{"牛乳を買う、卵も", "牛乳を買う、卵も"},
+		{`\n is not a newline`, `\\n is not a newline`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := EscapeText(tt.value); got != tt.want {
+				t.Errorf("EscapeText(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+			want := strings.ReplaceAll(tt.value, "\r\n", "\n")
+			if got := UnescapeText(EscapeText(tt.value)); got != want {
+				t.Errorf("UnescapeText(EscapeText(%q)) = %q, want %q", tt.value, got, want)
+			}
+		})
+	}
+
+	if got := UnescapeText(`line1\Nline2`); got != "line1\nline2" {
+		t.Errorf("UnescapeText with \\N = %q", got)
+	}
+}
+
+func TestWriteLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:牛乳を買う"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"76 octets", "SUMMARY:" + strings.Repeat("a", 68)},
+		{"long ASCII", "DESCRIPTION:" + strings.Repeat("abcdefghij", 30)},
+		{"multibyte", "SUMMARY:" + strings.Repeat("あいうえお", 20)},
+		{"multibyte after an odd prefix", "DESCRIPTION:x" + strings.Repeat("日本語のテキスト", 15)},
+		{"4-byte runes", "SUMMARY:" + strings.Repeat("🍣", 40)},
+		{"mixed", "SUMMARY:" + strings.Repeat("aあ🍣", 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeLine(&b, tt.line)
+			out := b.String()
+
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output does not end with CRLF: %q", out)
+			}
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			unfolded := lines[0]
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets: %q", i, len(line), line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a multibyte character: %q", i, line)
+				}
+				if i > 0 {
+					if !strings.HasPrefix(line, " ") {
+						t.Errorf("continuation line %d does not start with a space: %q", i, line)
+					}
+					unfolded += line[1:]
+				}
+			}
+			if unfolded != tt.line {
+				t.Errorf("unfolded = %q, want %q", unfolded, tt.line)
+			}
+			if len(tt.line) <= 75 && len(lines) != 1 {
+				t.Errorf("a line of %d octets was folded", len(tt.line))
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	calendar := NewComponent("VCALENDAR")
+	calendar.Add("VERSION", "2.0")
+	todo := NewComponent("VTODO")
+	todo.Add("UID", "todo-1@example.com")
+	todo.AddText("SUMMARY", "買い物; 牛乳, 卵")
+	todo.AddTime("DTSTAMP", time.Date(2024, 5, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60)))
+	todo.AddDate("DUE", time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC))
+	calendar.Components = append(calendar.Components, todo)
+
+	want := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:todo-1@example.com\r\n" +
+		`SUMMARY:買い物\; 牛乳\, 卵` + "\r\n" +
+		"DTSTAMP:20240501T000000Z\r\n" +
+		"DUE;VALUE=DATE:20240502\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+	if got := calendar.Encode(); got != want {
+		t.Errorf("Encode() = %q, want %q", got, want)
+	}
+
+	if property, ok := todo.Get("DUE"); !ok || property.Value != "20240502" {
+		t.Errorf("Get(\"DUE\") = %+v, %v", property, ok)
+	}
+	if _, ok := todo.Get("LOCATION"); ok {
+		t.Error("Get(\"LOCATION\") found a property that was not added")
+	}
+}
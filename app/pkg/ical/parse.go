@@ -0,0 +1,105 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInvalidCalendar は iCalendar として読み込めない場合に返されます。
+var ErrInvalidCalendar = errors.New("invalid icalendar")
+
+// Parse は iCalendar 形式を読み込み、最上位のコンポーネント（通常は VCALENDAR）を返します。
+func Parse(r io.Reader) (*Component, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *Component
+	var stack []*Component
+	for i, line := range lines {
+		property, err := parseProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidCalendar, i+1, err)
+		}
+		switch property.Name {
+		case "BEGIN":
+			component := NewComponent(strings.ToUpper(property.Value))
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, component)
+			} else if root == nil {
+				root = component
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(property.Value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalidCalendar, i+1, property.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: property outside of a component", ErrInvalidCalendar, i+1)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, property)
+		}
+	}
+	if root == nil || len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing BEGIN or END", ErrInvalidCalendar)
+	}
+	return root, nil
+}
+
+// unfoldLines は折り返された行（空白またはタブで始まる行）を前の行につなげます。
+func unfoldLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseProperty は "DUE;VALUE=DATE:20240501" のような行を読み込みます。
+// パラメータの値に引用符で囲まれたコロンが含まれる場合も扱えます。
+func parseProperty(line string) (Property, error) {
+	inQuote := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case c == ':' && !inQuote:
+			parts := strings.Split(line[:i], ";")
+			return Property{
+				Name:   strings.ToUpper(parts[0]),
+				Params: parts[1:],
+				Value:  line[i+1:],
+			}, nil
+		}
+	}
+	return Property{}, fmt.Errorf("missing ':' in %q", line)
+}
+
+// Param は "VALUE=DATE" のようなパラメータから name の値を返します。
+func (p Property) Param(name string) string {
+	for _, param := range p.Params {
+		key, value, ok := strings.Cut(param, "=")
+		if ok && strings.EqualFold(key, name) {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
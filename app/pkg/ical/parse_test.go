@@ -0,0 +1,116 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:todo-1@example.com\r\n" +
+		`SUMMARY:買い物\; 牛乳\, 卵` + "\r\n" +
+		"DESCRIPTION:一行目\\n二行目の説明がとても長いので折り返さ\r\n" +
+		" れています\r\n" +
+		"\tタブでも続きます\r\n" +
+		"due;VALUE=DATE:20240502\r\n" +
+		`ATTENDEE;CN="Suzuki: Taro";ROLE=REQ-PARTICIPANT:mailto:taro@example.com` + "\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:event-1@example.com\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	calendar, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if calendar.Name != "VCALENDAR" || len(calendar.Components) != 2 {
+		t.Fatalf("calendar = %s with %d components", calendar.Name, len(calendar.Components))
+	}
+	todo := calendar.Components[0]
+	if todo.Name != "VTODO" || calendar.Components[1].Name != "VEVENT" {
+		t.Fatalf("components = %s, %s", todo.Name, calendar.Components[1].Name)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"UID", "todo-1@example.com"},
+		{"SUMMARY", "買い物; 牛乳, 卵"},
+		{"DESCRIPTION", "一行目\n二行目の説明がとても長いので折り返されていますタブでも続きます"},
+		{"DUE", "20240502"},
+		{"ATTENDEE", "mailto:taro@example.com"},
+	}
+	for _, tt := range tests {
+		property, ok := todo.Get(tt.name)
+		if !ok {
+			t.Errorf("%s is missing", tt.name)
+			continue
+		}
+		if got := UnescapeText(property.Value); got != tt.value {
+			t.Errorf("%s = %q, want %q", tt.name, got, tt.value)
+		}
+	}
+
+	due, _ := todo.Get("DUE")
+	if got := due.Param("value"); got != "DATE" {
+		t.Errorf("DUE VALUE = %q, want %q", got, "DATE")
+	}
+	attendee, _ := todo.Get("ATTENDEE")
+	if got := attendee.Param("CN"); got != "Suzuki: Taro" {
+		t.Errorf("ATTENDEE CN = %q, want %q", got, "Suzuki: Taro")
+	}
+	if got := attendee.Param("DELEGATED-TO"); got != "" {
+		t.Errorf("missing parameter = %q, want empty", got)
+	}
+}
+
+// Encode で書き出したものを Parse で読み戻せること
+func TestParseRoundTrip(t *testing.T) {
+	summary := strings.Repeat("長い件名、セミコロン; と改行\nを含む ", 10)
+	calendar := NewComponent("VCALENDAR")
+	todo := NewComponent("VTODO")
+	todo.Add("UID", "todo-1@example.com")
+	todo.AddText("SUMMARY", summary)
+	calendar.Components = append(calendar.Components, todo)
+
+	parsed, err := Parse(strings.NewReader(calendar.Encode()))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(parsed.Components) != 1 {
+		t.Fatalf("parsed %d components, want 1", len(parsed.Components))
+	}
+	property, ok := parsed.Components[0].Get("SUMMARY")
+	if !ok {
+		t.Fatal("SUMMARY is missing")
+	}
+	if got := UnescapeText(property.Value); got != summary {
+		t.Errorf("SUMMARY = %q, want %q", got, summary)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"missing END", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"},
+		{"mismatched END", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR\r\n"},
+		{"END without BEGIN", "END:VCALENDAR\r\n"},
+		{"property outside of a component", "VERSION:2.0\r\nBEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"},
+		{"missing colon", "BEGIN:VCALENDAR\r\nVERSION 2.0\r\nEND:VCALENDAR\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.input)); !errors.Is(err, ErrInvalidCalendar) {
+				t.Errorf("Parse error = %v, want ErrInvalidCalendar", err)
+			}
+		})
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 
 	"app/pkg/utils"
 )
 
+// UserIDKey は認証したユーザーの ID を gin.Context に保存するときのキーです。
+const UserIDKey = "user_id"
+
 func AuthMiddleware(c *gin.Context) {
 	tokenString, err := c.Cookie("token")
 	if err != nil {
@@ -18,7 +22,7 @@ func AuthMiddleware(c *gin.Context) {
 		return
 	}
 
-	_, err = utils.ParseToken(tokenString)
+	token, err := utils.ParseToken(tokenString)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"message": "Invalid token",
@@ -27,5 +31,17 @@ func AuthMiddleware(c *gin.Context) {
 		return
 	}
 
+	// JSON の数値は float64 として読み込まれる
+	claims, ok := token.Claims.(jwt.MapClaims)
+	userID, hasUserID := claims["user_id"].(float64)
+	if !ok || !hasUserID {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "Invalid token",
+		})
+		c.Abort()
+		return
+	}
+	c.Set(UserIDKey, uint(userID))
+
 	c.Next()
 }
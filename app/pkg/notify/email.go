@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// EmailChannel は SMTP でリマインダーのメールを送ります。
+type EmailChannel struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+// NewEmailChannelFromEnv は環境変数 SMTP_HOST、SMTP_PORT、SMTP_USER、SMTP_PASSWORD、SMTP_FROM から EmailChannel を作成します。
+// SMTP_HOST が設定されていない場合は nil を返します。
+func NewEmailChannelFromEnv() *EmailChannel {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USER"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return &EmailChannel{
+		Addr: net.JoinHostPort(host, port),
+		Auth: auth,
+		From: os.Getenv("SMTP_FROM"),
+	}
+}
+
+func (e *EmailChannel) Send(ctx context.Context, reminder Reminder) error {
+	subject := fmt.Sprintf("【リマインダー】%s の期限が近づいています", reminder.Title)
+	deadline := reminder.Deadline.Format("2006-01-02 15:04 MST")
+	if reminder.DeadlineAllDay {
+		deadline = reminder.Deadline.Format("2006-01-02") + "（終日）"
+	}
+	body := fmt.Sprintf("%s の期限は %s です。\r\n", reminder.Title, deadline)
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", e.From)
+	fmt.Fprintf(&message, "To: %s\r\n", reminder.Target)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	message.WriteString(body)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(e.Addr, e.Auth, e.From, []string{reminder.Target}, []byte(message.String()))
+}
@@ -0,0 +1,43 @@
+// Package notify は期限のリマインダーなどの通知を送るチャネル（メール、Webhook など）を提供します。
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrChannelNotConfigured は設定されていないチャネルで送ろうとした場合に返されます。
+var ErrChannelNotConfigured = errors.New("notification channel is not configured")
+
+// Reminder は期限が近づいた Todo のリマインダーです。
+type Reminder struct {
+	TodoID uint
+	Title  string
+	// 受け取るユーザーのタイムゾーンでの期限
+	Deadline time.Time
+	// 期限が日付だけ（終日）かどうか
+	DeadlineAllDay bool
+	// 期限の何分前のリマインダーか
+	Before time.Duration
+	UserID uint
+	// 送り先（メールアドレスや Webhook の URL）
+	Target string
+}
+
+// Channel はリマインダーを送る手段です。新しいチャネルを追加する場合はこのインターフェースを実装して Channels に登録してください。
+type Channel interface {
+	Send(ctx context.Context, reminder Reminder) error
+}
+
+// Channels はチャネル名（email、webhook など）とチャネルの対応です。
+type Channels map[string]Channel
+
+// Send は name のチャネルでリマインダーを送ります。
+func (c Channels) Send(ctx context.Context, name string, reminder Reminder) error {
+	channel, ok := c[name]
+	if !ok || channel == nil {
+		return ErrChannelNotConfigured
+	}
+	return channel.Send(ctx, reminder)
+}
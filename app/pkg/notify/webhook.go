@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookAddressNotAllowed は Webhook の送り先がループバック、プライベート、リンクローカルなどの内部のアドレスの場合に返されます。
+// サーバーから内部のサービス（クラウドのメタデータなど）にリクエストを送らせないためです。
+var ErrWebhookAddressNotAllowed = errors.New("webhook target must not be a loopback, private or link-local address")
+
+// carrierGradeNAT は ISP などが内部で使うアドレス（RFC 6598）です。
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebhookChannel はリマインダーを JSON で Webhook の URL に POST します。
+// Secret が設定されている場合は、本文の HMAC-SHA256 を X-Todo-Signature ヘッダに付けます。
+// 内部のアドレスには、AllowedHosts に含まれるホストを除いて送りません。
+type WebhookChannel struct {
+	Client       *http.Client
+	Secret       string
+	AllowedHosts []string
+}
+
+func NewWebhookChannel(secret string) *WebhookChannel {
+	w := &WebhookChannel{
+		Secret:       secret,
+		AllowedHosts: WebhookAllowedHosts(),
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	w.Client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// プロキシを経由すると接続先のアドレスを確認できないので使わない
+			Proxy: nil,
+			DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				host, _, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				if hostAllowed(host, w.AllowedHosts) {
+					return dialer.DialContext(ctx, network, addr)
+				}
+				// 名前解決した後の接続先のアドレスを確認する（リダイレクトや DNS の書き換えにも対応するため）
+				guarded := *dialer
+				guarded.Control = func(network string, address string, c syscall.RawConn) error {
+					host, _, err := net.SplitHostPort(address)
+					if err != nil {
+						return err
+					}
+					if !PublicIP(net.ParseIP(host)) {
+						return ErrWebhookAddressNotAllowed
+					}
+					return nil
+				}
+				return guarded.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+	return w
+}
+
+// WebhookAllowedHosts は環境変数 WEBHOOK_ALLOWED_HOSTS（カンマ区切り）のホストを返します。
+// これらのホストには、内部のアドレスであっても Webhook を送ります。
+func WebhookAllowedHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("WEBHOOK_ALLOWED_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// CheckWebhookTarget は target のホストを名前解決し、内部のアドレスが含まれる場合は ErrWebhookAddressNotAllowed を返します。
+// allowedHosts に含まれるホストは確認しません。
+func CheckWebhookTarget(ctx context.Context, target *url.URL, allowedHosts []string) error {
+	host := target.Hostname()
+	if hostAllowed(host, allowedHosts) {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !PublicIP(addr.IP) {
+			return ErrWebhookAddressNotAllowed
+		}
+	}
+	return nil
+}
+
+// PublicIP は ip がインターネット上の（内部のものではない）アドレスかを返します。
+func PublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || carrierGradeNAT.Contains(ip))
+}
+
+func hostAllowed(host string, allowedHosts []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range allowedHosts {
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+type webhookPayload struct {
+	Event          string    `json:"event"`
+	TodoID         uint      `json:"todo_id"`
+	Title          string    `json:"title"`
+	Deadline       time.Time `json:"deadline"`
+	DeadlineAllDay bool      `json:"deadline_all_day"`
+	BeforeMinutes  int       `json:"before_minutes"`
+	UserID         uint      `json:"user_id"`
+}
+
+func (w *WebhookChannel) Send(ctx context.Context, reminder Reminder) error {
+	body, err := json.Marshal(webhookPayload{
+		Event:          "todo.reminder",
+		TodoID:         reminder.TodoID,
+		Title:          reminder.Title,
+		Deadline:       reminder.Deadline,
+		DeadlineAllDay: reminder.DeadlineAllDay,
+		BeforeMinutes:  int(reminder.Before / time.Minute),
+		UserID:         reminder.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reminder.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Todo-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
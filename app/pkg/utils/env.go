@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+)
+
+// GetEnvInt は環境変数を整数として読み込みます。未設定または不正な値の場合は defaultValue を返します。
+func GetEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetEnvFloat は環境変数を小数として読み込みます。未設定または不正な値の場合は defaultValue を返します。
+func GetEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ETag はリソースの ID と version から強い ETag を生成します。更新のレスポンスなど、version だけを伝える場合に使います。
+func ETag(id uint, version uint) string {
+	return fmt.Sprintf(`"%d-%d"`, id, version)
+}
+
+// RepresentationETag は ETag にレスポンスの本文のハッシュを加えた強い ETag を生成します。
+// 完了待ちかどうかや緊急度のように version を上げずに変わる値や、タイムゾーンごとの期限の表し方の違いも別の ETag になります。
+func RepresentationETag(id uint, version uint, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%d-%d-%s"`, id, version, hex.EncodeToString(sum[:8]))
+}
+
+// MatchETag は If-Match / If-None-Match ヘッダの値に etag が含まれているかを判定します。
+// weak が false の場合は強い比較（W/ 付きの ETag は一致しない）を行います。
+func MatchETag(header string, etag string, weak bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchVersionETag は If-Match ヘッダの値に、id と version が一致する強い ETag（ETag または RepresentationETag）が含まれているかを判定します。
+// 更新の前提条件は version だけで判定するので、計算で求める値が変わっただけでは一致しなくなりません。
+func MatchVersionETag(header string, id uint, version uint) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+	etag := ETag(id, version)
+	prefix := strings.TrimSuffix(etag, `"`) + "-"
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etag || (strings.HasPrefix(candidate, prefix) && strings.HasSuffix(candidate, `"`)) {
+			return true
+		}
+	}
+	return false
+}
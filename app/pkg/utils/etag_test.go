@@ -0,0 +1,64 @@
+package utils
+
+import "testing"
+
+func TestRepresentationETag(t *testing.T) {
+	a := RepresentationETag(12, 3, []byte(`{"data":{"blocked":false}}`))
+	b := RepresentationETag(12, 3, []byte(`{"data":{"blocked":true}}`))
+	if a == b {
+		t.Errorf("different bodies share the ETag %s", a)
+	}
+	if a != RepresentationETag(12, 3, []byte(`{"data":{"blocked":false}}`)) {
+		t.Error("the same body produced different ETags")
+	}
+	if !MatchETag(a, a, false) {
+		t.Errorf("%s does not strongly match itself", a)
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{"", `"1-2"`, true, false},
+		{"*", `"1-2"`, false, true},
+		{`"1-2"`, `"1-2"`, false, true},
+		{`"1-3"`, `"1-2"`, false, false},
+		{`"1-1", "1-2"`, `"1-2"`, false, true},
+		{`W/"1-2"`, `"1-2"`, false, false},
+		{`W/"1-2"`, `"1-2"`, true, true},
+	}
+	for _, tt := range tests {
+		if got := MatchETag(tt.header, tt.etag, tt.weak); got != tt.want {
+			t.Errorf("MatchETag(%q, %q, %v) = %v, want %v", tt.header, tt.etag, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestMatchVersionETag(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"*", true},
+		{`"12-3"`, true},
+		{RepresentationETag(12, 3, []byte("body")), true},
+		{`"12-4"`, false},
+		{RepresentationETag(12, 4, []byte("body")), false},
+		{`"12-30"`, false},
+		{`"12-30-abcd"`, false},
+		{`"112-3"`, false},
+		{`W/"12-3"`, false},
+		{`W/"12-3-abcd"`, false},
+		{`"1-1", "12-3-abcd"`, true},
+	}
+	for _, tt := range tests {
+		if got := MatchVersionETag(tt.header, 12, 3); got != tt.want {
+			t.Errorf("MatchVersionETag(%q, 12, 3) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// mentionPattern は @ の直前が文字・数字・@ でない（メールアドレスの途中ではない）ものを拾う
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([\p{L}\p{N}_.+\-]+(?:@[\p{L}\p{N}\-]+(?:\.[\p{L}\p{N}\-]+)+)?)`)
+	// Markdown のコード（``` のブロックと ` で囲んだ部分）の中の @ はメンションとして扱わない
+	fencedCodePattern = regexp.MustCompile("(?s)```.*?(```|$)")
+	inlineCodePattern = regexp.MustCompile("`[^`\n]*`")
+)
+
+// ParseMentions は Markdown の本文から @name や @user@example.com のようなメンションを取り出します。
+// 同じメンションは 1 回だけ返し、末尾の . は文末の句点として取り除きます。
+func ParseMentions(body string) []string {
+	body = fencedCodePattern.ReplaceAllString(body, " ")
+	body = inlineCodePattern.ReplaceAllString(body, " ")
+
+	mentions := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		mention := strings.TrimRight(match[1], ".")
+		key := strings.ToLower(mention)
+		if mention == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		mentions = append(mentions, mention)
+	}
+	return mentions
+}
@@ -0,0 +1,301 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnrecognizedDeadline は ParseNaturalDeadline で解釈できない文字列が含まれていた場合に返されます。
+var ErrUnrecognizedDeadline = errors.New("could not understand the deadline")
+
+// naturalDate は解釈の途中経過です。
+type naturalDate struct {
+	now     time.Time
+	date    time.Time
+	dateSet bool
+	hour    int
+	minute  int
+	timeSet bool
+	// 「3時間後」のように日時がそのまま決まった場合
+	exact    time.Time
+	exactSet bool
+}
+
+type naturalRule struct {
+	pattern *regexp.Regexp
+	apply   func(d *naturalDate, m []string) error
+}
+
+var japaneseWeekdays = map[string]time.Weekday{
+	"日": time.Sunday, "月": time.Monday, "火": time.Tuesday, "水": time.Wednesday,
+	"木": time.Thursday, "金": time.Friday, "土": time.Saturday,
+}
+
+var englishWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// 「朝」「夕方」のように時間帯だけを指定した場合の時刻
+var partsOfDay = map[string]int{
+	"朝": 9, "morning": 9,
+	"昼": 12, "正午": 12, "noon": 12,
+	"夕方": 17, "evening": 18,
+	"夜": 20, "今夜": 20, "tonight": 20,
+}
+
+// naturalRules は上から順に試します。長い表現を先に置いて、「明後日」が「明日」として解釈されないようにしています。
+var naturalRules = []naturalRule{
+	// 2024-05-01、2024/5/1
+	{regexp.MustCompile(`(\d{4})[-/](\d{1,2})[-/](\d{1,2})`), func(d *naturalDate, m []string) error {
+		return d.setYMD(atoi(m[1]), atoi(m[2]), atoi(m[3]))
+	}},
+	// 2024年5月1日、5月1日
+	{regexp.MustCompile(`(?:(\d{4})年)?(\d{1,2})月(\d{1,2})日`), func(d *naturalDate, m []string) error {
+		if m[1] != "" {
+			return d.setYMD(atoi(m[1]), atoi(m[2]), atoi(m[3]))
+		}
+		return d.setMonthDay(atoi(m[2]), atoi(m[3]))
+	}},
+	// 5/1
+	{regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})\b`), func(d *naturalDate, m []string) error {
+		return d.setMonthDay(atoi(m[1]), atoi(m[2]))
+	}},
+	// 3日後、2週間後、1か月後、3時間後、30分後
+	{regexp.MustCompile(`(\d+)\s*(日|週間|か月|ヶ月|ヵ月|カ月|時間|分)後`), func(d *naturalDate, m []string) error {
+		return d.addRelative(atoi(m[1]), m[2])
+	}},
+	// in 3 days、in 2 weeks、in 1 month、in 3 hours、in 30 minutes
+	{regexp.MustCompile(`\bin\s+(\d+)\s*(days?|weeks?|months?|hours?|hrs?|minutes?|mins?)\b`), func(d *naturalDate, m []string) error {
+		return d.addRelative(atoi(m[1]), m[2])
+	}},
+	{regexp.MustCompile(`明々後日|しあさって`), func(d *naturalDate, m []string) error {
+		return d.setDate(d.today().AddDate(0, 0, 3))
+	}},
+	{regexp.MustCompile(`明後日|あさって|\bday after tomorrow\b`), func(d *naturalDate, m []string) error {
+		return d.setDate(d.today().AddDate(0, 0, 2))
+	}},
+	{regexp.MustCompile(`明日|あした|あす|\btomorrow\b`), func(d *naturalDate, m []string) error {
+		return d.setDate(d.today().AddDate(0, 0, 1))
+	}},
+	{regexp.MustCompile(`今日|きょう|本日|\btoday\b`), func(d *naturalDate, m []string) error {
+		return d.setDate(d.today())
+	}},
+	// 今週金曜、来週の月曜日、再来週水曜
+	{regexp.MustCompile(`(今週|来週|再来週)?の?([月火水木金土日])曜日?`), func(d *naturalDate, m []string) error {
+		return d.setWeekday(japaneseWeekdays[m[2]], map[string]int{"": -1, "今週": 0, "来週": 1, "再来週": 2}[m[1]])
+	}},
+	// friday、this friday、next fri
+	{regexp.MustCompile(`\b(?:(this|next)\s+)?(sun(?:day)?|mon(?:day)?|tue(?:s|sday)?|wed(?:nesday)?|thu(?:rs?|rsday)?|fri(?:day)?|sat(?:urday)?)\b`), func(d *naturalDate, m []string) error {
+		return d.setWeekday(englishWeekdays[m[2][:3]], map[string]int{"": -1, "this": 0, "next": 1}[m[1]])
+	}},
+	{regexp.MustCompile(`来週|\bnext week\b`), func(d *naturalDate, m []string) error {
+		return d.setDate(d.weekStart().AddDate(0, 0, 7))
+	}},
+	{regexp.MustCompile(`来月|\bnext month\b`), func(d *naturalDate, m []string) error {
+		today := d.today()
+		return d.setDate(time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()))
+	}},
+	{regexp.MustCompile(`月末|\bend of (?:the )?month\b`), func(d *naturalDate, m []string) error {
+		today := d.today()
+		return d.setDate(time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location()))
+	}},
+	// 午後3時、15時30分、9時半
+	{regexp.MustCompile(`(午前|午後)?(\d{1,2})時(?:(\d{1,2})分|(半))?`), func(d *naturalDate, m []string) error {
+		hour, minute := atoi(m[2]), atoi(m[3])
+		if m[4] != "" {
+			minute = 30
+		}
+		if m[1] == "午後" && hour < 12 {
+			hour += 12
+		}
+		if m[1] == "午前" && hour == 12 {
+			hour = 0
+		}
+		return d.setTime(hour, minute)
+	}},
+	// 17:00、5:30pm
+	{regexp.MustCompile(`\b(\d{1,2}):(\d{2})\s*(am|pm)?\b`), func(d *naturalDate, m []string) error {
+		return d.setTime(meridiem(atoi(m[1]), m[3]), atoi(m[2]))
+	}},
+	// 5pm
+	{regexp.MustCompile(`\b(\d{1,2})\s*(am|pm)\b`), func(d *naturalDate, m []string) error {
+		return d.setTime(meridiem(atoi(m[1]), m[2]), 0)
+	}},
+	{regexp.MustCompile(`今夜|\btonight\b`), func(d *naturalDate, m []string) error {
+		if err := d.setDate(d.today()); err != nil {
+			return err
+		}
+		return d.setTime(partsOfDay["今夜"], 0)
+	}},
+	{regexp.MustCompile(`正午|夕方|朝|昼|夜|\b(?:noon|morning|evening)\b`), func(d *naturalDate, m []string) error {
+		return d.setTime(partsOfDay[m[0]], 0)
+	}},
+}
+
+// naturalFiller は日時の表現の間に入っていても無視する語です。
+var naturalFiller = regexp.MustCompile(`までに|まで|の|に|、|,|\b(?:at|on|by|due)\b|\s`)
+
+// ParseNaturalDeadline は「明日の15時」「来週金曜」「tomorrow 5pm」「in 3 days」のような日本語・英語の期限を、now を基準に解釈します。
+// 時刻を含まない場合は、その日付の終日の期限（allDay が true）になります。日時は now のタイムゾーンで解釈します。
+func ParseNaturalDeadline(text string, now time.Time) (deadline time.Time, allDay bool, err error) {
+	s := normalizeNaturalText(text)
+	d := &naturalDate{now: now}
+
+	matched := false
+	for _, rule := range naturalRules {
+		loc := rule.pattern.FindStringSubmatchIndex(s)
+		if loc == nil {
+			continue
+		}
+		m := make([]string, len(loc)/2)
+		for i := range m {
+			if loc[2*i] >= 0 {
+				m[i] = s[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		if err := rule.apply(d, m); err != nil {
+			return time.Time{}, false, err
+		}
+		s = s[:loc[0]] + " " + s[loc[1]:]
+		matched = true
+	}
+	if rest := strings.TrimSpace(naturalFiller.ReplaceAllString(s, "")); !matched || rest != "" {
+		return time.Time{}, false, fmt.Errorf("%w: %q", ErrUnrecognizedDeadline, text)
+	}
+
+	if d.exactSet {
+		return d.exact, false, nil
+	}
+	date := d.date
+	if !d.dateSet {
+		date = d.today()
+	}
+	if !d.timeSet {
+		return date, true, nil
+	}
+	deadline = time.Date(date.Year(), date.Month(), date.Day(), d.hour, d.minute, 0, 0, now.Location())
+	// 「5pm」のように時刻だけを指定して、その時刻を過ぎていれば明日にする
+	if !d.dateSet && deadline.Before(now) {
+		deadline = deadline.AddDate(0, 0, 1)
+	}
+	return deadline, false, nil
+}
+
+// normalizeNaturalText は全角の数字や記号を半角にして、英字を小文字にします。
+func normalizeNaturalText(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '０' && r <= '９':
+			return r - '０' + '0'
+		case r == '：':
+			return ':'
+		case r == '／':
+			return '/'
+		case r == '　':
+			return ' '
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(text)))
+}
+
+func (d *naturalDate) today() time.Time {
+	year, month, day := d.now.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, d.now.Location())
+}
+
+// weekStart は今週の月曜日を返します。
+func (d *naturalDate) weekStart() time.Time {
+	offset := (int(d.now.Weekday()) + 6) % 7
+	return d.today().AddDate(0, 0, -offset)
+}
+
+func (d *naturalDate) setDate(date time.Time) error {
+	if d.dateSet || d.exactSet {
+		return fmt.Errorf("%w: more than one date", ErrUnrecognizedDeadline)
+	}
+	d.date = date
+	d.dateSet = true
+	return nil
+}
+
+func (d *naturalDate) setYMD(year int, month int, day int) error {
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, d.now.Location())
+	if month < 1 || month > 12 || date.Day() != day {
+		return fmt.Errorf("%w: invalid date", ErrUnrecognizedDeadline)
+	}
+	return d.setDate(date)
+}
+
+// setMonthDay は年を省略した日付を設定します。今日より前の日付の場合は来年にします。
+func (d *naturalDate) setMonthDay(month int, day int) error {
+	year := d.now.Year()
+	if time.Date(year, time.Month(month), day, 0, 0, 0, 0, d.now.Location()).Before(d.today()) {
+		year++
+	}
+	return d.setYMD(year, month, day)
+}
+
+// setWeekday は曜日を設定します。weeksAhead が -1 の場合は今日以降で最初のその曜日、0 以上の場合は weeksAhead 週後のその曜日です。
+func (d *naturalDate) setWeekday(weekday time.Weekday, weeksAhead int) error {
+	if weeksAhead < 0 {
+		days := (int(weekday) - int(d.now.Weekday()) + 7) % 7
+		return d.setDate(d.today().AddDate(0, 0, days))
+	}
+	offset := (int(weekday) + 6) % 7
+	return d.setDate(d.weekStart().AddDate(0, 0, weeksAhead*7+offset))
+}
+
+func (d *naturalDate) setTime(hour int, minute int) error {
+	if d.timeSet || d.exactSet {
+		return fmt.Errorf("%w: more than one time", ErrUnrecognizedDeadline)
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return fmt.Errorf("%w: invalid time", ErrUnrecognizedDeadline)
+	}
+	d.hour, d.minute = hour, minute
+	d.timeSet = true
+	return nil
+}
+
+func (d *naturalDate) addRelative(n int, unit string) error {
+	switch {
+	case unit == "時間" || strings.HasPrefix(unit, "h"):
+		if d.dateSet || d.timeSet {
+			return fmt.Errorf("%w: more than one time", ErrUnrecognizedDeadline)
+		}
+		d.exact, d.exactSet = d.now.Add(time.Duration(n)*time.Hour).Truncate(time.Minute), true
+		return nil
+	case unit == "分" || strings.HasPrefix(unit, "min"):
+		if d.dateSet || d.timeSet {
+			return fmt.Errorf("%w: more than one time", ErrUnrecognizedDeadline)
+		}
+		d.exact, d.exactSet = d.now.Add(time.Duration(n)*time.Minute).Truncate(time.Minute), true
+		return nil
+	case unit == "日" || strings.HasPrefix(unit, "day"):
+		return d.setDate(d.today().AddDate(0, 0, n))
+	case unit == "週間" || strings.HasPrefix(unit, "week"):
+		return d.setDate(d.today().AddDate(0, 0, 7*n))
+	default:
+		return d.setDate(d.today().AddDate(0, n, 0))
+	}
+}
+
+func meridiem(hour int, suffix string) int {
+	switch {
+	case suffix == "pm" && hour < 12:
+		return hour + 12
+	case suffix == "am" && hour == 12:
+		return 0
+	}
+	return hour
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
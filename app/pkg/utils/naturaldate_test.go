@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseNaturalDeadline(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	// 2024-05-15 は水曜日
+	now := time.Date(2024, 5, 15, 10, 30, 45, 0, jst)
+	at := func(year int, month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, jst)
+	}
+
+	tests := []struct {
+		text   string
+		want   time.Time
+		allDay bool
+	}{
+		// 日本語
+		{"明日の15時", at(2024, 5, 16, 15, 0), false},
+		{"明日の15時までに", at(2024, 5, 16, 15, 0), false},
+		{"明日の１５：００", at(2024, 5, 16, 15, 0), false},
+		{"来週金曜", at(2024, 5, 24, 0, 0), true},
+		{"来週の金曜日", at(2024, 5, 24, 0, 0), true},
+		{"再来週水曜", at(2024, 5, 29, 0, 0), true},
+		{"今週月曜", at(2024, 5, 13, 0, 0), true},
+		{"金曜", at(2024, 5, 17, 0, 0), true},
+		{"水曜", at(2024, 5, 15, 0, 0), true},
+		{"今日", at(2024, 5, 15, 0, 0), true},
+		{"明後日の朝", at(2024, 5, 17, 9, 0), false},
+		{"しあさって", at(2024, 5, 18, 0, 0), true},
+		{"3日後", at(2024, 5, 18, 0, 0), true},
+		{"2週間後", at(2024, 5, 29, 0, 0), true},
+		{"1か月後", at(2024, 6, 15, 0, 0), true},
+		{"3時間後", at(2024, 5, 15, 13, 30), false},
+		{"30分後", at(2024, 5, 15, 11, 0), false},
+		{"来月", at(2024, 6, 1, 0, 0), true},
+		{"月末", at(2024, 5, 31, 0, 0), true},
+		{"5月20日", at(2024, 5, 20, 0, 0), true},
+		{"5月1日", at(2025, 5, 1, 0, 0), true},
+		{"2024年6月1日 9時半", at(2024, 6, 1, 9, 30), false},
+		{"午後3時", at(2024, 5, 15, 15, 0), false},
+		{"午前12時", at(2024, 5, 16, 0, 0), false},
+		{"明日の午前12時", at(2024, 5, 16, 0, 0), false},
+		{"午後12時", at(2024, 5, 15, 12, 0), false},
+		{"9時", at(2024, 5, 16, 9, 0), false},
+		{"15時30分", at(2024, 5, 15, 15, 30), false},
+		{"今夜", at(2024, 5, 15, 20, 0), false},
+		// 英語
+		{"tomorrow 5pm", at(2024, 5, 16, 17, 0), false},
+		{"Tomorrow at 5:30 PM", at(2024, 5, 16, 17, 30), false},
+		{"in 3 days", at(2024, 5, 18, 0, 0), true},
+		{"in 1 week", at(2024, 5, 22, 0, 0), true},
+		{"in 2 hours", at(2024, 5, 15, 12, 30), false},
+		{"in 15 mins", at(2024, 5, 15, 10, 45), false},
+		{"friday", at(2024, 5, 17, 0, 0), true},
+		{"next fri", at(2024, 5, 24, 0, 0), true},
+		{"by next friday 17:00", at(2024, 5, 24, 17, 0), false},
+		{"next week", at(2024, 5, 20, 0, 0), true},
+		{"end of month", at(2024, 5, 31, 0, 0), true},
+		{"12am", at(2024, 5, 16, 0, 0), false},
+		{"12pm", at(2024, 5, 15, 12, 0), false},
+		{"tonight", at(2024, 5, 15, 20, 0), false},
+		// 日付の書式
+		{"2024-06-01", at(2024, 6, 1, 0, 0), true},
+		{"2024/6/1 17:00", at(2024, 6, 1, 17, 0), false},
+		{"5/20", at(2024, 5, 20, 0, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, allDay, err := ParseNaturalDeadline(tt.text, now)
+			if err != nil {
+				t.Fatalf("ParseNaturalDeadline(%q) returned error: %v", tt.text, err)
+			}
+			if !got.Equal(tt.want) || allDay != tt.allDay {
+				t.Errorf("ParseNaturalDeadline(%q) = %v, %v; want %v, %v", tt.text, got, allDay, tt.want, tt.allDay)
+			}
+			if got.Location() != jst {
+				t.Errorf("ParseNaturalDeadline(%q) location = %v, want %v", tt.text, got.Location(), jst)
+			}
+		})
+	}
+}
+
+func TestParseNaturalDeadlineErrors(t *testing.T) {
+	now := time.Date(2024, 5, 15, 10, 30, 0, 0, time.UTC)
+	tests := []string{
+		"",
+		"someday",
+		"明日 foo",
+		"明日 明後日",
+		"15時 17時",
+		"25時",
+		"25:00",
+		"2024-02-30",
+		"13/1",
+		"3時間後 15時",
+	}
+	for _, text := range tests {
+		t.Run(text, func(t *testing.T) {
+			if _, _, err := ParseNaturalDeadline(text, now); !errors.Is(err, ErrUnrecognizedDeadline) {
+				t.Errorf("ParseNaturalDeadline(%q) error = %v, want ErrUnrecognizedDeadline", text, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// rankDigits は並び順（rank）に使う文字です。文字コード順に並んでいるので、文字列の比較がそのまま並び順になります。
+// DB で並び替えるときは照合順序の影響を受けないよう `COLLATE "C"` を付けてください。
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const rankBase = len(rankDigits)
+
+// ErrInvalidRankRange は RankBetween に a >= b の範囲が渡された場合に返されます。
+var ErrInvalidRankRange = errors.New("rank a must be less than rank b")
+
+// RankBetween は a と b の間に並ぶ rank を返します（fractional indexing）。
+// a が空文字の場合は先頭、b が空文字の場合は末尾として扱います。
+func RankBetween(a string, b string) (string, error) {
+	if b != "" && a >= b {
+		return "", ErrInvalidRankRange
+	}
+	if !validRank(a) || !validRank(b) {
+		return "", errors.New("invalid rank")
+	}
+	return rankMidpoint(a, b), nil
+}
+
+// RankSequence は n 個の rank を、間に十分な余裕を持たせて昇順に生成します。
+// 既存のデータに rank を振り直すときに使います。
+func RankSequence(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	// n+1 個の区間に分けられるだけの桁数を求める
+	width := 1
+	capacity := rankBase
+	for capacity <= n+1 {
+		width++
+		capacity *= rankBase
+	}
+	step := capacity / (n + 1)
+
+	ranks := make([]string, n)
+	for i := 0; i < n; i++ {
+		ranks[i] = strings.TrimRight(formatRank((i+1)*step, width), "0")
+	}
+	return ranks
+}
+
+func rankMidpoint(a string, b string) string {
+	if b != "" {
+		// 共通の接頭辞はそのまま残して、残りの部分で中間を求める
+		n := 0
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + rankMidpoint(rest, b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(rankDigits, a[0])
+	}
+	digitB := rankBase
+	if b != "" {
+		digitB = strings.IndexByte(rankDigits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		// 末尾に追加する場合は 1 つ後ろの文字にして、桁が増えるのを遅らせる
+		if b == "" && a != "" {
+			return string(rankDigits[digitA+1])
+		}
+		return string(rankDigits[(digitA+digitB)/2])
+	}
+
+	// 隣り合う文字の場合は桁を増やす
+	if b != "" && len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(rankDigits[digitA]) + rankMidpoint(rest, "")
+}
+
+func rankDigitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return rankDigits[0]
+}
+
+// validRank は rank が使える文字だけでできていて、末尾が "0" でないかを確認します。
+// 末尾が "0" だと、その直前に入る rank を作れないことがあるためです。
+func validRank(rank string) bool {
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(rankDigits, rank[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(rank, "0")
+}
+
+func formatRank(value int, width int) string {
+	digits := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		digits[i] = rankDigits[value%rankBase]
+		value /= rankBase
+	}
+	return string(digits)
+}
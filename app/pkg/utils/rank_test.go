@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{"empty column", "", "", "V"},
+		{"append", "V", "", "W"},
+		{"prepend", "", "V", "F"},
+		{"midpoint", "A", "Z", "M"},
+		{"adjacent digits", "A", "B", "AV"},
+		{"common prefix", "VA", "VC", "VB"},
+		{"before a longer rank", "", "01", "00V"},
+		{"after the last digit", "z", "", "zV"},
+		{"prefix and its extension", "V", "V1", "V0V"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RankBetween(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("RankBetween(%q, %q) returned error: %v", tt.a, tt.b, err)
+			}
+			if got != tt.want {
+				t.Errorf("RankBetween(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+			if !validRank(got) || got <= tt.a || (tt.b != "" && got >= tt.b) {
+				t.Errorf("RankBetween(%q, %q) = %q, which does not sort between them", tt.a, tt.b, got)
+			}
+		})
+	}
+}
+
+func TestRankBetweenErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		rangeErr bool
+	}{
+		{"reversed", "B", "A", true},
+		{"equal", "V", "V", true},
+		{"invalid character", "A-", "", false},
+		{"trailing zero", "A0", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RankBetween(tt.a, tt.b)
+			if err == nil {
+				t.Fatalf("RankBetween(%q, %q) returned no error", tt.a, tt.b)
+			}
+			if errors.Is(err, ErrInvalidRankRange) != tt.rangeErr {
+				t.Errorf("RankBetween(%q, %q) error = %v", tt.a, tt.b, err)
+			}
+		})
+	}
+}
+
+// 同じ位置に何度も挿入しても、並び順どおりの rank が作れること
+func TestRankBetweenRepeatedInsert(t *testing.T) {
+	a, b := "A", "B"
+	for i := 0; i < 200; i++ {
+		mid, err := RankBetween(a, b)
+		if err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+		if mid <= a || mid >= b {
+			t.Fatalf("insert %d: %q is not between %q and %q", i, mid, a, b)
+		}
+		b = mid
+	}
+
+	last := ""
+	for i := 0; i < 200; i++ {
+		next, err := RankBetween(last, "")
+		if err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+		if next <= last {
+			t.Fatalf("append %d: %q is not after %q", i, next, last)
+		}
+		last = next
+	}
+}
+
+func TestRankSequence(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 61, 62, 1000} {
+		ranks := RankSequence(n)
+		if len(ranks) != n {
+			t.Fatalf("RankSequence(%d) returned %d ranks", n, len(ranks))
+		}
+		for i, rank := range ranks {
+			if !validRank(rank) {
+				t.Errorf("RankSequence(%d)[%d] = %q, which is not a valid rank", n, i, rank)
+			}
+			if i > 0 && rank <= ranks[i-1] {
+				t.Errorf("RankSequence(%d)[%d] = %q is not after %q", n, i, rank, ranks[i-1])
+			}
+		}
+		// 振り直した後も、先頭・末尾・間に挿入できること
+		if n > 0 {
+			if _, err := RankBetween("", ranks[0]); err != nil {
+				t.Errorf("RankSequence(%d): cannot insert before the first rank: %v", n, err)
+			}
+			if _, err := RankBetween(ranks[n-1], ""); err != nil {
+				t.Errorf("RankSequence(%d): cannot insert after the last rank: %v", n, err)
+			}
+		}
+		if n > 1 {
+			if _, err := RankBetween(ranks[0], ranks[1]); err != nil {
+				t.Errorf("RankSequence(%d): cannot insert between the first two ranks: %v", n, err)
+			}
+		}
+	}
+}
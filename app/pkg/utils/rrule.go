@@ -0,0 +1,317 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRULE の FREQ のうち、対応しているもの
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// maxRRulePeriods は次の日時を探すときにたどる期間（日・週・月）の上限です。
+// 条件に一致する日が無いルール（例: BYMONTHDAY=31 と BYDAY=MO の組み合わせ）で無限ループしないようにします。
+const maxRRulePeriods = 10000
+
+// ErrInvalidRRule は RRULE の書式が正しくない、または対応していない場合に返されます。
+var ErrInvalidRRule = errors.New("invalid rrule")
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ByDay は BYDAY の 1 要素です。N は月の中の何番目か（1MO = 第 1 月曜、-1FR = 最終金曜）で、0 は毎週を表します。
+type ByDay struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// RRule は RFC 5545 の繰り返しルールのうち、FREQ（DAILY / WEEKLY / MONTHLY）、INTERVAL、BYDAY、BYMONTHDAY、COUNT、UNTIL に対応したものです。
+type RRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []ByDay
+	ByMonthDay []int
+	Count      int
+	Until      time.Time
+}
+
+// ParseRRule は "FREQ=WEEKLY;BYDAY=MO,WE" のような RRULE を解析します。先頭の "RRULE:" は省略できます。
+func ParseRRule(value string) (RRule, error) {
+	rule := RRule{Interval: 1}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return RRule{}, fmt.Errorf("%w: empty", ErrInvalidRRule)
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return RRule{}, fmt.Errorf("%w: %q", ErrInvalidRRule, part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+			if rule.Freq != FreqDaily && rule.Freq != FreqWeekly && rule.Freq != FreqMonthly {
+				return RRule{}, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalidRRule, val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err != nil || rule.Interval < 1 {
+				return RRule{}, fmt.Errorf("%w: INTERVAL must be a positive integer", ErrInvalidRRule)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+			if err != nil || rule.Count < 1 {
+				return RRule{}, fmt.Errorf("%w: COUNT must be a positive integer", ErrInvalidRRule)
+			}
+		case "UNTIL":
+			rule.Until, err = ParseICalTime(val)
+			if err != nil {
+				return RRule{}, fmt.Errorf("%w: %v", ErrInvalidRRule, err)
+			}
+		case "BYDAY":
+			for _, code := range strings.Split(strings.ToUpper(val), ",") {
+				day, err := parseByDay(code)
+				if err != nil {
+					return RRule{}, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, err := strconv.Atoi(code)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return RRule{}, fmt.Errorf("%w: BYMONTHDAY %s", ErrInvalidRRule, code)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, day)
+			}
+		case "WKST":
+			// 週の始まりは月曜日として扱う
+		default:
+			return RRule{}, fmt.Errorf("%w: unsupported %s", ErrInvalidRRule, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return RRule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRRule)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return RRule{}, fmt.Errorf("%w: COUNT and UNTIL cannot be used together", ErrInvalidRRule)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != FreqMonthly {
+			return RRule{}, fmt.Errorf("%w: numbered BYDAY is only allowed with FREQ=MONTHLY", ErrInvalidRRule)
+		}
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq == FreqWeekly {
+		return RRule{}, fmt.Errorf("%w: BYMONTHDAY is not allowed with FREQ=WEEKLY", ErrInvalidRRule)
+	}
+	return rule, nil
+}
+
+func parseByDay(code string) (ByDay, error) {
+	if len(code) < 2 {
+		return ByDay{}, fmt.Errorf("%w: BYDAY %s", ErrInvalidRRule, code)
+	}
+	weekday, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return ByDay{}, fmt.Errorf("%w: BYDAY %s", ErrInvalidRRule, code)
+	}
+	day := ByDay{Weekday: weekday}
+	if prefix := code[:len(code)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return ByDay{}, fmt.Errorf("%w: BYDAY %s", ErrInvalidRRule, code)
+		}
+		day.N = n
+	}
+	return day, nil
+}
+
+// String は RRULE の文字列表現を返します（先頭の "RRULE:" は付けません）。
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			codes[i] = weekdayCode(day.Weekday)
+			if day.N != 0 {
+				codes[i] = strconv.Itoa(day.N) + codes[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+FormatICalTime(r.Until))
+	}
+	return strings.Join(parts, ";")
+}
+
+func weekdayCode(weekday time.Weekday) string {
+	for code, day := range weekdayCodes {
+		if day == weekday {
+			return code
+		}
+	}
+	return ""
+}
+
+// Next は dtstart から始まる繰り返しのうち、after より後で exdates に含まれない最初の日時を返します。
+// 繰り返しが終わっている場合は false を返します。
+func (r RRule) Next(dtstart time.Time, after time.Time, exdates []time.Time) (time.Time, bool) {
+	excluded := map[int64]bool{}
+	for _, exdate := range exdates {
+		excluded[exdate.Unix()] = true
+	}
+
+	// COUNT は EXDATE で除外する前の回数で数える（RFC 5545）
+	count := 0
+	for period := 0; period < maxRRulePeriods; period++ {
+		for _, occurrence := range r.candidates(dtstart, period) {
+			if occurrence.Before(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && occurrence.After(r.Until) {
+				return time.Time{}, false
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if occurrence.After(after) && !excluded[occurrence.Unix()] {
+				return occurrence, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// candidates は period 番目の期間（日・週・月）に含まれる日時を昇順で返します。時刻は dtstart と同じです。
+func (r RRule) candidates(dtstart time.Time, period int) []time.Time {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case FreqDaily:
+		day := dtstart.AddDate(0, 0, period*r.Interval)
+		if r.matchesByDay(day) && r.matchesByMonthDay(day) {
+			days = append(days, day)
+		}
+	case FreqWeekly:
+		// 週の始まり（月曜日）から 7 日分を調べる
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		monday := dtstart.AddDate(0, 0, period*r.Interval*7-offset)
+		for i := 0; i < 7; i++ {
+			day := at(monday.Year(), monday.Month(), monday.Day()+i)
+			if len(r.ByDay) == 0 {
+				if day.Weekday() == dtstart.Weekday() {
+					days = append(days, day)
+				}
+			} else if r.matchesByDay(day) {
+				days = append(days, day)
+			}
+		}
+	case FreqMonthly:
+		first := at(dtstart.Year(), dtstart.Month()+time.Month(period*r.Interval), 1)
+		daysInMonth := first.AddDate(0, 1, -1).Day()
+		for d := 1; d <= daysInMonth; d++ {
+			day := at(first.Year(), first.Month(), d)
+			switch {
+			case len(r.ByMonthDay) > 0:
+				if r.matchesByMonthDay(day) && r.matchesByDay(day) {
+					days = append(days, day)
+				}
+			case len(r.ByDay) > 0:
+				if r.matchesByDay(day) {
+					days = append(days, day)
+				}
+			default:
+				// 31 日のように、その月に存在しない日はスキップする（RFC 5545）
+				if d == dtstart.Day() {
+					days = append(days, day)
+				}
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+func (r RRule) matchesByDay(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	for _, byDay := range r.ByDay {
+		if byDay.Weekday != day.Weekday() {
+			continue
+		}
+		switch {
+		case byDay.N == 0:
+			return true
+		case byDay.N > 0 && (day.Day()-1)/7+1 == byDay.N:
+			return true
+		case byDay.N < 0 && (daysInMonth-day.Day())/7+1 == -byDay.N:
+			return true
+		}
+	}
+	return false
+}
+
+func (r RRule) matchesByMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	for _, monthDay := range r.ByMonthDay {
+		if monthDay == day.Day() || (monthDay < 0 && daysInMonth+monthDay+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseICalTime は "20240131T090000Z" や "20240131" のような iCalendar の日時を解析します。
+func ParseICalTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date-time %q", value)
+}
+
+// FormatICalTime は日時を "20240131T090000Z" の形式（UTC）にします。
+func FormatICalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// occurrences は dtstart から順に、最大 n 件の日時を返します。
+func occurrences(rule RRule, dtstart time.Time, exdates []time.Time, n int) []time.Time {
+	var result []time.Time
+	after := dtstart.Add(-time.Second)
+	for len(result) < n {
+		next, ok := rule.Next(dtstart, after, exdates)
+		if !ok {
+			break
+		}
+		result = append(result, next)
+		after = next
+	}
+	return result
+}
+
+func TestRRuleNext(t *testing.T) {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 9, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		exdates []time.Time
+		want    []time.Time
+	}{
+		{
+			name:    "daily",
+			rule:    "FREQ=DAILY",
+			dtstart: date(1, 30),
+			want:    []time.Time{date(1, 30), date(1, 31), date(2, 1)},
+		},
+		{
+			name:    "daily with BYDAY",
+			rule:    "FREQ=DAILY;BYDAY=MO,FR",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 1), date(1, 5), date(1, 8), date(1, 12)},
+		},
+		{
+			name:    "weekly BYDAY",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 1), date(1, 3), date(1, 8), date(1, 10)},
+		},
+		{
+			name:    "weekly BYDAY skips days before dtstart",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE",
+			dtstart: date(1, 2),
+			want:    []time.Time{date(1, 3), date(1, 8), date(1, 10)},
+		},
+		{
+			name:    "weekly without BYDAY uses the dtstart weekday",
+			rule:    "FREQ=WEEKLY",
+			dtstart: date(1, 4),
+			want:    []time.Time{date(1, 4), date(1, 11), date(1, 18)},
+		},
+		{
+			name:    "every other week",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 1), date(1, 15), date(1, 29)},
+		},
+		{
+			name:    "monthly first Monday",
+			rule:    "FREQ=MONTHLY;BYDAY=1MO",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 1), date(2, 5), date(3, 4)},
+		},
+		{
+			name:    "monthly last Friday",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 26), date(2, 23), date(3, 29), date(4, 26)},
+		},
+		{
+			name:    "monthly on the 31st skips short months",
+			rule:    "FREQ=MONTHLY",
+			dtstart: date(1, 31),
+			want:    []time.Time{date(1, 31), date(3, 31), date(5, 31), date(7, 31), date(8, 31)},
+		},
+		{
+			name:    "monthly BYMONTHDAY=31 skips short months",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=31",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 31), date(3, 31), date(5, 31)},
+		},
+		{
+			name:    "monthly last day",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 31), date(2, 29), date(3, 31), date(4, 30)},
+		},
+		{
+			name:    "COUNT",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 1), date(1, 2), date(1, 3)},
+		},
+		{
+			name:    "COUNT includes excluded dates",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: date(1, 1),
+			exdates: []time.Time{date(1, 2)},
+			want:    []time.Time{date(1, 1), date(1, 3)},
+		},
+		{
+			name:    "UNTIL",
+			rule:    "FREQ=DAILY;UNTIL=20240104T090000Z",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 1), date(1, 2), date(1, 3), date(1, 4)},
+		},
+		{
+			name:    "UNTIL before the next occurrence",
+			rule:    "FREQ=WEEKLY;BYDAY=MO;UNTIL=20240114T000000Z",
+			dtstart: date(1, 1),
+			want:    []time.Time{date(1, 1), date(1, 8)},
+		},
+		{
+			name:    "EXDATE",
+			rule:    "FREQ=WEEKLY;BYDAY=MO",
+			dtstart: date(1, 1),
+			exdates: []time.Time{date(1, 8)},
+			want:    []time.Time{date(1, 1), date(1, 15), date(1, 22)},
+		},
+		{
+			name:    "no matching day",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=1MO",
+			dtstart: date(1, 1),
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q) returned error: %v", tt.rule, err)
+			}
+			got := occurrences(rule, tt.dtstart, tt.exdates, len(tt.want)+1)
+			if rule.Count == 0 && rule.Until.IsZero() && len(got) > len(tt.want) {
+				got = got[:len(tt.want)]
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,WE", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"freq=weekly;byday=mo;wkst=MO", "FREQ=WEEKLY;BYDAY=MO"},
+		{"FREQ=WEEKLY;INTERVAL=1;BYDAY=FR", "FREQ=WEEKLY;BYDAY=FR"},
+		{"FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR", "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1;COUNT=6", "FREQ=MONTHLY;BYMONTHDAY=1,-1;COUNT=6"},
+		{"FREQ=DAILY;UNTIL=20241231T150000Z", "FREQ=DAILY;UNTIL=20241231T150000Z"},
+		{"FREQ=DAILY;UNTIL=20241231", "FREQ=DAILY;UNTIL=20241231T000000Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rule, err := ParseRRule(tt.value)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q) returned error: %v", tt.value, err)
+			}
+			if got := rule.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			// String の結果をもう一度解析しても同じルールになること
+			again, err := ParseRRule(rule.String())
+			if err != nil {
+				t.Fatalf("ParseRRule(%q) returned error: %v", rule.String(), err)
+			}
+			if again.String() != rule.String() {
+				t.Errorf("round trip = %q, want %q", again.String(), rule.String())
+			}
+		})
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"RRULE:",
+		"BYDAY=MO",
+		"FREQ=YEARLY",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;COUNT=3;UNTIL=20241231T000000Z",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		// BYSETPOS には対応していないので、-1FR のように BYDAY の番号で指定する
+		"FREQ=MONTHLY;BYDAY=FR;BYSETPOS=-1",
+		"FREQ=DAILY;",
+		"FREQ",
+	}
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			if _, err := ParseRRule(value); !errors.Is(err, ErrInvalidRRule) {
+				t.Errorf("ParseRRule(%q) error = %v, want ErrInvalidRRule", value, err)
+			}
+		})
+	}
+}
+
+func TestICalTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"20240131T090000Z", time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{"20240131T090000", time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{"20240131", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseICalTime(tt.value)
+		if err != nil {
+			t.Fatalf("ParseICalTime(%q) returned error: %v", tt.value, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseICalTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	if _, err := ParseICalTime("2024-01-31"); err == nil {
+		t.Error("ParseICalTime(\"2024-01-31\") returned no error")
+	}
+
+	jst := time.FixedZone("JST", 9*60*60)
+	if got := FormatICalTime(time.Date(2024, 2, 1, 0, 30, 0, 0, jst)); got != "20240131T153000Z" {
+		t.Errorf("FormatICalTime = %q, want %q", got, "20240131T153000Z")
+	}
+}
@@ -40,4 +40,34 @@ func ParseToken(tokenString string) (*jwt.Token, error) {
 		return nil, err
 	}
 	return token, nil
-}
\ No newline at end of file
+}
+
+// GenerateInvitationToken はワークスペース の招待リンクに使う、expiresAt まで有効な署名付きトークンを作ります。
+// ログイン用のトークンとは user_id を持たない点で区別されるので、招待リンクのトークンではログインできません。
+func GenerateInvitationToken(invitationID uint, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"invitation_id": invitationID,
+		"exp":           expiresAt.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+}
+
+// ParseInvitationToken は招待リンクのトークンを検証し、招待の ID を返します。
+// 期限が切れている場合は jwt.ErrTokenExpired を含むエラーを返します。
+func ParseInvitationToken(tokenString string) (uint, error) {
+	token, err := ParseToken(tokenString)
+	if err != nil {
+		return 0, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, jwt.ErrTokenInvalidClaims
+	}
+	// JSON の数値は float64 として読み込まれる
+	id, ok := claims["invitation_id"].(float64)
+	if !ok || id <= 0 {
+		return 0, jwt.ErrTokenInvalidClaims
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,177 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := ColumnName(tt.index); got != tt.want {
+			t.Errorf("ColumnName(%d) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{`<a href="x">&</a>`, "&lt;a href=&#34;x&#34;&gt;&amp;&lt;/a&gt;"},
+		{"it's", "it&#39;s"},
+		{"牛乳を買う", "牛乳を買う"},
+		{"line1\nline2", "line1&#xA;line2"},
+		{"bell\x07", "bell�"},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.value); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// sheet は sheet1.xml を読み込むための構造です。
+type sheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R    string `xml:"r,attr"`
+			T    string `xml:"t,attr"`
+			V    string `xml:"v"`
+			Text string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, `Todos & "tasks"`)
+	if err != nil {
+		t.Fatalf("NewWriter returned error: %v", err)
+	}
+	rows := [][]interface{}{
+		{"ID", "Title", "Done", "Priority"},
+		{1, "牛乳を買う <2本>", false, 2.5},
+		{uint(2), "", true, nil},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row...); err != nil {
+			t.Fatalf("WriteRow returned error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a zip file: %v", err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		body, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		parts[f.Name] = body
+	}
+
+	for _, name := range []string{
+		"[Content_Types].xml",
+		"_rels/.rels",
+		"xl/_rels/workbook.xml.rels",
+		"xl/workbook.xml",
+		"xl/worksheets/sheet1.xml",
+	} {
+		body, ok := parts[name]
+		if !ok {
+			t.Errorf("%s is missing", name)
+			continue
+		}
+		// どの部品も整形式の XML であること
+		decoder := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s is not well-formed XML: %v", name, err)
+				break
+			}
+		}
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &workbook); err != nil {
+		t.Fatalf("unmarshal workbook: %v", err)
+	}
+	if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != `Todos & "tasks"` {
+		t.Errorf("sheets = %+v", workbook.Sheets)
+	}
+
+	var got sheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &got); err != nil {
+		t.Fatalf("unmarshal sheet: %v", err)
+	}
+	if len(got.Rows) != len(rows) {
+		t.Fatalf("sheet has %d rows, want %d", len(got.Rows), len(rows))
+	}
+
+	type cell struct{ r, t, value string }
+	want := [][]cell{
+		{{"A1", "inlineStr", "ID"}, {"B1", "inlineStr", "Title"}, {"C1", "inlineStr", "Done"}, {"D1", "inlineStr", "Priority"}},
+		{{"A2", "", "1"}, {"B2", "inlineStr", "牛乳を買う <2本>"}, {"C2", "b", "0"}, {"D2", "", "2.5"}},
+		// 空文字と nil のセルは出力しない
+		{{"A3", "", "2"}, {"C3", "b", "1"}},
+	}
+	for i, row := range got.Rows {
+		if row.R != i+1 {
+			t.Errorf("row %d has r=%d", i, row.R)
+		}
+		if len(row.Cells) != len(want[i]) {
+			t.Errorf("row %d has %d cells, want %d", i+1, len(row.Cells), len(want[i]))
+			continue
+		}
+		for j, c := range row.Cells {
+			value := c.V
+			if c.T == "inlineStr" {
+				value = c.Text
+			}
+			if c.R != want[i][j].r || c.T != want[i][j].t || value != want[i][j].value {
+				t.Errorf("cell %s = {t=%q value=%q}, want %+v", c.R, c.T, value, want[i][j])
+			}
+		}
+	}
+
+	if !strings.Contains(string(parts["xl/worksheets/sheet1.xml"]), `xml:space="preserve"`) {
+		t.Error("inline strings do not preserve whitespace")
+	}
+}
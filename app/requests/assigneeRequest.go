@@ -0,0 +1,20 @@
+package requests
+
+type AssigneeOutput struct {
+      User AuthOutput `json:"user"`
+      // owner（持ち主）、assignee（担当者）、watcher（ウォッチャー）
+      Role string `json:"role"`
+}
+
+type AssigneeInput struct {
+      // user_id または email のどちらかでユーザーを指定する
+      UserID uint `json:"user_id"`
+      Email string `json:"email"`
+      // owner、assignee、watcher のいずれか。省略した場合は assignee
+      Role string `json:"role"`
+}
+
+type SetAssigneesInput struct {
+      // 持ち主（owner）をちょうど 1 人含める
+      Assignees []AssigneeInput `json:"assignees" binding:"required"`
+}
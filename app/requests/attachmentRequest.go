@@ -0,0 +1,18 @@
+package requests
+
+import "time"
+
+type AttachmentOutput struct {
+      ID uint `json:"id"`
+      TodoID uint `json:"todo_id"`
+      Uploader AuthOutput `json:"uploader"`
+      Filename string `json:"filename"`
+      // ファイルの中身から判定した MIME タイプ
+      ContentType string `json:"content_type"`
+      // バイト数
+      Size int64 `json:"size"`
+      // ダウンロード用の署名付き URL。url_expires_at を過ぎると使えないので、取得し直す
+      URL string `json:"url"`
+      URLExpiresAt time.Time `json:"url_expires_at"`
+      CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package requests
+
+type CreateBoardInput struct {
+      Name string `json:"name" binding:"required"`
+      // "status"（デフォルト）または "tag"
+      GroupBy string `json:"group_by"`
+}
+
+type BoardOutput struct {
+      ID uint `json:"id"`
+      Name string `json:"name"`
+      GroupBy string `json:"group_by"`
+      Columns []BoardColumnOutput `json:"columns,omitempty"`
+}
+
+type BoardColumnOutput struct {
+      Key string `json:"key"`
+      Name string `json:"name"`
+      StatusID *uint `json:"status_id,omitempty"`
+      TagID *uint `json:"tag_id,omitempty"`
+      Todos []GetTodoOutput `json:"todos"`
+}
+
+type MoveTodoInput struct {
+      // 移動先の列。省略した場合は今の列の中で並び替える
+      StatusID *uint `json:"status_id"`
+      // タグの列へ移動する場合は移動先のタグと、移動元のタグ（外す場合）を指定する
+      TagID *uint `json:"tag_id"`
+      FromTagID *uint `json:"from_tag_id"`
+      // この Todo の直後に移動する
+      AfterID *uint `json:"after_id"`
+      // この Todo の直前に移動する
+      BeforeID *uint `json:"before_id"`
+}
@@ -0,0 +1,28 @@
+package requests
+
+type ChecklistItemOutput struct {
+      ID uint `json:"id"`
+      Text string `json:"text"`
+      Done bool `json:"done"`
+      Position int `json:"position"`
+}
+
+type ChecklistSummaryOutput struct {
+      Done int `json:"done"`
+      Total int `json:"total"`
+}
+
+type CreateChecklistItemInput struct {
+      Text string `json:"text" binding:"required"`
+      Done bool `json:"done"`
+}
+
+type UpdateChecklistItemInput struct {
+      Text string `json:"text"`
+      Done *bool `json:"done"`
+}
+
+type ReorderChecklistInput struct {
+      // 並び替え後の順番で、すべての項目の ID を指定する
+      ItemIDs []uint `json:"item_ids" binding:"required"`
+}
@@ -0,0 +1,37 @@
+package requests
+
+import "time"
+
+type CommentOutput struct {
+      ID uint `json:"id"`
+      TodoID uint `json:"todo_id"`
+      // 返信の場合は返信先のコメントの ID
+      ParentID *uint `json:"parent_id"`
+      Author AuthOutput `json:"author"`
+      // Markdown。削除されたコメントの場合は空
+      Body string `json:"body"`
+      Mentions []AuthOutput `json:"mentions"`
+      Edited bool `json:"edited"`
+      EditedAt *time.Time `json:"edited_at"`
+      // 返信が残っているため、削除後も一覧に残っているコメント
+      Deleted bool `json:"deleted"`
+      CreatedAt time.Time `json:"created_at"`
+      Replies []CommentOutput `json:"replies,omitempty"`
+}
+
+type CreateCommentInput struct {
+      // Markdown。@name や @user@example.com でワークスペースのメンバーにメンションできる
+      Body string `json:"body" binding:"required"`
+      // 返信する場合は返信先のコメントの ID
+      ParentID *uint `json:"parent_id"`
+}
+
+type UpdateCommentInput struct {
+      Body string `json:"body" binding:"required"`
+}
+
+type CommentRevisionOutput struct {
+      Body string `json:"body"`
+      EditedBy AuthOutput `json:"edited_by"`
+      EditedAt time.Time `json:"edited_at"`
+}
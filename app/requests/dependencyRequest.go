@@ -0,0 +1,42 @@
+package requests
+
+import "time"
+
+type AddTodoDependencyInput struct {
+      // 完了を待つ Todo（先行タスク）の ID。同じワークスペースの Todo である必要がある
+      DependsOnID uint `json:"depends_on_id" binding:"required"`
+}
+
+type TodoDependenciesOutput struct {
+      // 完了を待っている Todo（先行タスク）
+      DependsOn []GetTodoOutput `json:"depends_on"`
+      // この Todo の完了を待っている Todo（後続タスク）
+      Blocking []GetTodoOutput `json:"blocking"`
+}
+
+type CriticalPathOutput struct {
+      ProjectID uint `json:"project_id"`
+      // 予定の開始日時。今日とプロジェクトの開始日の遅いほう
+      Start time.Time `json:"start"`
+      // すべての Todo が終わる見込みの日時
+      Finish time.Time `json:"finish"`
+      // finish がプロジェクトの終了日を過ぎている場合 true
+      Overrun bool `json:"overrun"`
+      // 全体の終わりを決めている Todo。先行タスクから順に並ぶ
+      Path []ScheduledTodoOutput `json:"path"`
+      // 完了していないすべての Todo。先行タスクが後続タスクより前に並ぶ
+      Todos []ScheduledTodoOutput `json:"todos"`
+}
+
+type ScheduledTodoOutput struct {
+      GetTodoOutput
+      EarliestStart time.Time `json:"earliest_start"`
+      EarliestFinish time.Time `json:"earliest_finish"`
+      LatestFinish time.Time `json:"latest_finish"`
+      // 全体の終わりを遅らせずに遅れられる時間
+      SlackHours float64 `json:"slack_hours"`
+      // slack_hours が 0 の場合 true
+      Critical bool `json:"critical"`
+      // 先行タスクを待つと期限に間に合わない見込みの場合（期限切れを含む）true
+      Late bool `json:"late"`
+}
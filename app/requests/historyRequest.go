@@ -0,0 +1,22 @@
+package requests
+
+import "time"
+
+type TodoEventOutput struct {
+      ID uint `json:"id"`
+      TodoID uint `json:"todo_id"`
+      // POST /api/undo/:operation_id で取り消すときの ID
+      OperationID *string `json:"operation_id"`
+      // 操作したユーザー。繰り返しの自動作成など、ユーザーの操作でない場合は null
+      Actor *AuthOutput `json:"actor"`
+      // created、updated、status_changed、moved、parent_changed、tags_changed、assignees_changed、dependencies_changed、deleted、restored、undone
+      Action string `json:"action"`
+      // 項目名（title、deadline、tag_ids など）ごとの変更前と変更後の値
+      Changes map[string]FieldChangeOutput `json:"changes"`
+      CreatedAt time.Time `json:"created_at"`
+}
+
+type FieldChangeOutput struct {
+      Before interface{} `json:"before"`
+      After interface{} `json:"after"`
+}
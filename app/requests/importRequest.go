@@ -0,0 +1,35 @@
+package requests
+
+import "time"
+
+type ImportRowErrorOutput struct {
+      // CSV の場合はファイル上の行番号、JSON と ICS の場合は何件目か
+      Row int `json:"row"`
+      Field string `json:"field"`
+      Message string `json:"message"`
+}
+
+type ImportJobOutput struct {
+      ID uint `json:"id"`
+      Format string `json:"format"`
+      // pending、running、succeeded、failed
+      Status string `json:"status"`
+      Total int `json:"total"`
+      Processed int `json:"processed"`
+      Error string `json:"error,omitempty"`
+      CreatedAt time.Time `json:"created_at"`
+      FinishedAt *time.Time `json:"finished_at"`
+}
+
+type ImportResultOutput struct {
+      Total int `json:"total"`
+      // 誤りの無かった件数
+      Valid int `json:"valid"`
+      Created int `json:"created"`
+      DryRun bool `json:"dry_run"`
+      Errors []ImportRowErrorOutput `json:"errors"`
+      // 同期的に取り込んだ場合のみ返す
+      Todos []GetTodoOutput `json:"todos,omitempty"`
+      // バックグラウンドで取り込む場合のみ返す。進捗は GET /api/todos/import/:job_id で確認できる
+      Job *ImportJobOutput `json:"job,omitempty"`
+}
@@ -0,0 +1,32 @@
+package requests
+
+import "time"
+
+type InvitationInput struct {
+      Email string `json:"email" binding:"required,email"`
+      // owner、admin、member のいずれか。省略した場合は member
+      Role string `json:"role"`
+}
+
+type InvitationSignUpInput struct {
+      Name string `json:"name" binding:"required"`
+      Password string `json:"password" binding:"required"`
+      // IANA のタイムゾーン名（例: Asia/Tokyo）。省略した場合は DEFAULT_TIME_ZONE
+      TimeZone string `json:"time_zone"`
+}
+
+type InvitationOutput struct {
+      ID uint `json:"id"`
+      WorkspaceID uint `json:"workspace_id"`
+      WorkspaceName string `json:"workspace_name"`
+      Email string `json:"email"`
+      Role string `json:"role"`
+      // pending、accepted、declined、revoked、expired のいずれか
+      Status string `json:"status"`
+      InvitedBy AuthOutput `json:"invited_by"`
+      ExpiresAt time.Time `json:"expires_at"`
+      RespondedAt *time.Time `json:"responded_at"`
+      CreatedAt time.Time `json:"created_at"`
+      // 招待リンク。作成したときのレスポンスにだけ含まれる
+      Link string `json:"link,omitempty"`
+}
@@ -0,0 +1,16 @@
+package requests
+
+// PageQuery はページ分けして返す一覧の ?page=1&per_page=20 です。
+type PageQuery struct {
+      // 1 から数える。省略した場合は 1
+      Page int `form:"page"`
+      // 省略した場合は 20、最大 100
+      PerPage int `form:"per_page"`
+}
+
+type PageOutput struct {
+      Page int `json:"page"`
+      PerPage int `json:"per_page"`
+      Total int64 `json:"total"`
+      TotalPages int `json:"total_pages"`
+}
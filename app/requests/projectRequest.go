@@ -0,0 +1,75 @@
+package requests
+
+import "time"
+
+type ProjectOutput struct {
+      ID uint `json:"id"`
+      Name string `json:"name"`
+      Description string `json:"description"`
+      Color string `json:"color"`
+      Archived bool `json:"archived"`
+      ArchivedAt *time.Time `json:"archived_at"`
+      // YYYY-MM-DD
+      StartDate *string `json:"start_date"`
+      EndDate *string `json:"end_date"`
+      CreatedAt time.Time `json:"created_at"`
+      UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProjectListQuery は GET /projects の絞り込み条件です。
+type ProjectListQuery struct {
+      // true の場合はアーカイブしたプロジェクトも返す
+      IncludeArchived bool `form:"include_archived"`
+}
+
+type CreateProjectInput struct {
+      Name string `json:"name" binding:"required"`
+      Description string `json:"description"`
+      Color string `json:"color"`
+      // YYYY-MM-DD
+      StartDate string `json:"start_date"`
+      EndDate string `json:"end_date"`
+}
+
+// UpdateProjectInput は指定した項目だけを変更する。開始日・終了日は空文字列で未設定に戻す
+type UpdateProjectInput struct {
+      Name string `json:"name"`
+      Description *string `json:"description"`
+      Color *string `json:"color"`
+      StartDate *string `json:"start_date"`
+      EndDate *string `json:"end_date"`
+}
+
+type ProjectStatsOutput struct {
+      Total int `json:"total"`
+      Done int `json:"done"`
+      Open int `json:"open"`
+      // 完了していない Todo のうち、期限を過ぎているもの
+      Overdue int `json:"overdue"`
+      // 完了した割合（0〜100）
+      Percent int `json:"percent"`
+      ByStatus []ProjectStatusCountOutput `json:"by_status"`
+}
+
+type ProjectStatusCountOutput struct {
+      Status StatusOutput `json:"status"`
+      Count int `json:"count"`
+}
+
+type ProjectMemberOutput struct {
+      User AuthOutput `json:"user"`
+      Role string `json:"role"`
+      JoinedAt time.Time `json:"joined_at"`
+}
+
+type ProjectMemberInput struct {
+      // user_id または email のどちらかでユーザーを指定する。ワークスペースのメンバーである必要がある
+      UserID uint `json:"user_id"`
+      Email string `json:"email"`
+      // manager または member。省略した場合は member
+      Role string `json:"role"`
+}
+
+type ProjectMemberRoleInput struct {
+      Role string `json:"role" binding:"required"`
+}
@@ -0,0 +1,25 @@
+package requests
+
+import "time"
+
+type RecurrenceOutput struct {
+      ID uint `json:"id"`
+      RRule string `json:"rrule"`
+      DTStart time.Time `json:"dtstart"`
+      ExDates []time.Time `json:"exdates"`
+      Generate string `json:"generate"`
+      // 次に作成される回の日時。繰り返しが終わっている場合は null
+      Next *time.Time `json:"next"`
+}
+
+type SetRecurrenceInput struct {
+      // RFC 5545 の RRULE（例: FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10）
+      RRule string `json:"rrule" binding:"required"`
+      // 除外する回の日時。指定した場合のみ置き換える
+      ExDates []time.Time `json:"exdates"`
+      // on_complete（完了したら次回を作成、デフォルト）または schedule（期限が近づいたら作成）
+      Generate string `json:"generate"`
+      // 既に繰り返しの Todo の場合の変更範囲。this（この回のみ、デフォルト）、following（この回以降）、all（すべての回）
+      // UpdateTodoInput.Scope と同じ値で、デフォルトも同じ
+      Scope string `json:"scope"`
+}
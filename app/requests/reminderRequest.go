@@ -0,0 +1,17 @@
+package requests
+
+type ReminderRuleOutput struct {
+      ID uint `json:"id"`
+      BeforeMinutes int `json:"before_minutes"`
+      Channel string `json:"channel"`
+      Target string `json:"target"`
+}
+
+type ReminderRuleInput struct {
+      // 期限の何分前に通知するか（例: 1 日前なら 1440）
+      BeforeMinutes int `json:"before_minutes" binding:"required,min=1"`
+      // email または webhook
+      Channel string `json:"channel" binding:"required"`
+      // email の場合は送り先のメールアドレス（省略した場合は自分のメールアドレス）、webhook の場合は URL
+      Target string `json:"target"`
+}
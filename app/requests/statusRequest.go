@@ -0,0 +1,45 @@
+package requests
+
+import "time"
+
+type StatusOutput struct {
+      ID uint `json:"id"`
+      Name string `json:"name"`
+      Color string `json:"color"`
+      Position int `json:"position"`
+      IsDefault bool `json:"is_default"`
+      IsDone bool `json:"is_done"`
+      TransitionIDs []uint `json:"transition_ids,omitempty"`
+}
+
+type CreateStatusInput struct {
+      Name string `json:"name" binding:"required"`
+      Color string `json:"color"`
+      Position int `json:"position"`
+      IsDefault bool `json:"is_default"`
+      IsDone bool `json:"is_done"`
+}
+
+type UpdateStatusInput struct {
+      Name string `json:"name"`
+      Color string `json:"color"`
+      Position *int `json:"position"`
+      IsDefault *bool `json:"is_default"`
+      IsDone *bool `json:"is_done"`
+}
+
+type SetStatusTransitionsInput struct {
+      // 遷移先のステータス ID。空にすると、どのステータスにも遷移できるようになる
+      StatusIDs []uint `json:"status_ids"`
+}
+
+type ChangeTodoStatusInput struct {
+      StatusID uint `json:"status_id" binding:"required"`
+}
+
+type TodoStatusHistoryOutput struct {
+      StatusID uint `json:"status_id"`
+      StatusName string `json:"status_name"`
+      EnteredAt time.Time `json:"entered_at"`
+      LeftAt *time.Time `json:"left_at"`
+}
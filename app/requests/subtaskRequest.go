@@ -0,0 +1,17 @@
+package requests
+
+type ProgressOutput struct {
+      Done int `json:"done"`
+      Total int `json:"total"`
+      Percent int `json:"percent"`
+}
+
+type TodoTreeOutput struct {
+      GetTodoOutput
+      Children []TodoTreeOutput `json:"children"`
+}
+
+type SetTodoParentInput struct {
+      // null を指定すると親を外す
+      ParentID *uint `json:"parent_id"`
+}
@@ -0,0 +1,26 @@
+package requests
+
+type TagOutput struct {
+      ID uint `json:"id"`
+      Name string `json:"name"`
+      Color string `json:"color"`
+}
+
+type CreateTagInput struct {
+      Name string `json:"name" binding:"required"`
+      Color string `json:"color"`
+}
+
+type UpdateTagInput struct {
+      Name string `json:"name"`
+      Color string `json:"color"`
+}
+
+type MergeTagsInput struct {
+      // このタグにまとめられて削除されるタグの ID
+      SourceIDs []uint `json:"source_ids" binding:"required"`
+}
+
+type SetTodoTagsInput struct {
+      TagIDs []uint `json:"tag_ids"`
+}
@@ -0,0 +1,68 @@
+package requests
+
+import "time"
+
+type CreateTimeEntryInput struct {
+      StartedAt time.Time `json:"started_at" binding:"required"`
+      // ended_at か minutes のどちらかを指定する
+      EndedAt *time.Time `json:"ended_at"`
+      Minutes *int `json:"minutes"`
+      Note string `json:"note"`
+}
+
+type UpdateTimeEntryInput struct {
+      // 指定した項目だけを変更する。ended_at か minutes を指定すると、計測中のタイマーはその日時で止まる
+      StartedAt *time.Time `json:"started_at"`
+      EndedAt *time.Time `json:"ended_at"`
+      Minutes *int `json:"minutes"`
+      Note *string `json:"note"`
+}
+
+type TimeEntryOutput struct {
+      ID uint `json:"id"`
+      WorkspaceID uint `json:"workspace_id"`
+      TodoID uint `json:"todo_id"`
+      // GET /api/timer などで、Todo も読み込んだ場合のみ返す
+      TodoTitle string `json:"todo_title,omitempty"`
+      User AuthOutput `json:"user"`
+      StartedAt time.Time `json:"started_at"`
+      // 計測中のタイマーは null
+      EndedAt *time.Time `json:"ended_at"`
+      Running bool `json:"running"`
+      // 計測中のタイマーは今までの時間
+      Minutes int `json:"minutes"`
+      // timer または manual
+      Source string `json:"source"`
+      Note string `json:"note"`
+      CreatedAt time.Time `json:"created_at"`
+}
+
+// TimesheetQuery は GET /api/timesheet?from=2024-04-01&to=2024-04-30&user_id=2&project_id=1&format=csv です。
+type TimesheetQuery struct {
+      // 呼び出したユーザーのタイムゾーンの日付（to の日を含む）。省略した場合は 4 週間前の週の初めから今日まで
+      From string `form:"from"`
+      To string `form:"to"`
+      // ワークスペースの持ち主・管理者以外は、自分の ID だけ指定できる
+      UserID uint `form:"user_id"`
+      ProjectID uint `form:"project_id"`
+}
+
+type TimesheetOutput struct {
+      From string `json:"from"`
+      To string `json:"to"`
+      TotalMinutes int `json:"total_minutes"`
+      Rows []TimesheetRowOutput `json:"rows"`
+}
+
+type TimesheetRowOutput struct {
+      // 週の初めの月曜日
+      WeekStart string `json:"week_start"`
+      User AuthOutput `json:"user"`
+      // プロジェクトに属さない Todo の場合は null
+      ProjectID *uint `json:"project_id"`
+      ProjectName string `json:"project_name"`
+      Minutes int `json:"minutes"`
+      Hours float64 `json:"hours"`
+      // 集計した記録の件数
+      Entries int `json:"entries"`
+}
@@ -4,20 +4,86 @@ import "time"
 
 type GetTodoOutput struct {
       ID uint `json:"id"`
+      WorkspaceID uint `json:"workspace_id"`
+      ProjectID *uint `json:"project_id"`
       Title string `json:"title"`
       Description string `json:"description"`
-      Category string `json:"category"`
+      Tags []TagOutput `json:"tags"`
+      // 呼び出したユーザーのタイムゾーンの日時。終日の期限は、その日付の 0 時
       Deadline time.Time `json:"deadline"`
+      DeadlineAllDay bool `json:"deadline_all_day"`
+      // deadline_text で期限を指定した場合のみ、どう解釈したかを返す
+      InterpretedDeadline *InterpretedDeadlineOutput `json:"interpreted_deadline,omitempty"`
       State bool `json:"state"`
+      Status StatusOutput `json:"status"`
+      StatusChangedAt *time.Time `json:"status_changed_at"`
+      Rank string `json:"rank"`
+      ParentID *uint `json:"parent_id"`
+      // 0（P0）〜 4（P4）
+      Priority int `json:"priority"`
+      // 優先度・期限・経過日数などから計算した緊急度スコア。大きいほど急ぐ
+      Urgency float64 `json:"urgency"`
+      // 繰り返しの Todo の場合のみ返す
+      SeriesID *uint `json:"series_id,omitempty"`
+      OccurrenceAt *time.Time `json:"occurrence_at,omitempty"`
+      // サブタスクがある場合のみ返す
+      Progress *ProgressOutput `json:"progress,omitempty"`
+      // 見積もり（分）と、記録された時間の合計（分、計測中のタイマーを含む）
+      EstimateMinutes *int `json:"estimate_minutes"`
+      TrackedMinutes int `json:"tracked_minutes"`
+      // 完了していない先行タスクがある場合 true。blocked_by はその ID
+      Blocked bool `json:"blocked"`
+      BlockedBy []uint `json:"blocked_by"`
+      Checklist []ChecklistItemOutput `json:"checklist"`
+      ChecklistSummary ChecklistSummaryOutput `json:"checklist_summary"`
+      Version uint `json:"version"`
+      DeletedAt *time.Time `json:"deleted_at,omitempty"`
+      // 互換性のため、役割に関係なく関わっているユーザーをすべて返す
       Users []AuthOutput `json:"users"`
+      Assignees []AssigneeOutput `json:"assignees"`
+}
+
+type InterpretedDeadlineOutput struct {
+      Text string `json:"text"`
+      Deadline time.Time `json:"deadline"`
+      AllDay bool `json:"all_day"`
+}
+
+// TodoListQuery は GET /todos の絞り込み条件です。
+type TodoListQuery struct {
+      // ?tag=1&tag=2 のように複数指定できる
+      Tags []uint `form:"tag"`
+      // any（いずれかのタグ、デフォルト）または all（すべてのタグ）
+      TagMatch string `form:"tag_match"`
+      // urgency（緊急度の高い順）、priority（優先度の高い順）、deadline（期限の早い順）
+      Sort string `form:"sort"`
+      // today（今日）、this_week（今週）、overdue（期限切れ）。呼び出したユーザーのタイムゾーンで判定する
+      Due string `form:"due"`
+      // 指定したプロジェクトの Todo に絞り込む（アーカイブしたプロジェクトも指定できる）
+      ProjectID uint `form:"project_id"`
+      // true の場合はアーカイブしたプロジェクトの Todo も返す
+      IncludeArchived bool `form:"include_archived"`
 }
 
 type CreateTodoInput struct {
       Title string `json:"title" binding:"required"`
       Description string `json:"description"`
-      Category string `json:"category"`
+      TagIDs []uint `json:"tag_ids"`
       Deadline time.Time `json:"deadline"`
-      State bool `json:"state"`
+      // true の場合は deadline の日付（呼び出したユーザーのタイムゾーン）だけを使う
+      DeadlineAllDay bool `json:"deadline_all_day"`
+      // 「明日の15時」「来週金曜」「tomorrow 5pm」「in 3 days」のような期限。指定した場合は deadline より優先する
+      DeadlineText string `json:"deadline_text"`
+      // 省略した場合はデフォルトのステータスになる
+      StatusID *uint `json:"status_id"`
+      // サブタスクとして作成する場合は親の ID を指定する
+      ParentID *uint `json:"parent_id"`
+      // 属するプロジェクト。サブタスクで省略した場合は親と同じプロジェクト
+      ProjectID *uint `json:"project_id"`
+      // 0（P0）〜 4（P4）。省略した場合は 2（P2）
+      Priority *int `json:"priority"`
+      // 見積もり（分）
+      EstimateMinutes *int `json:"estimate_minutes"`
       Email string `json:"email" binding:"required"`
 }
  
@@ -25,21 +91,42 @@ type UpdateTodoInput struct {
       ID uint `json:"id"`
       Title string `json:"title"`
       Description string `json:"description"`
-      Category string `json:"category"`
+      // 指定した場合のみタグを置き換える（空配列ですべて外す）
+      TagIDs *[]uint `json:"tag_ids"`
       Deadline time.Time `json:"deadline"`
-      State bool `json:"state"`
+      DeadlineAllDay *bool `json:"deadline_all_day"`
+      DeadlineText string `json:"deadline_text"`
+      StatusID *uint `json:"status_id"`
+      Priority *int `json:"priority"`
+      // 見積もり（分）。0 で見積もりを外す
+      EstimateMinutes *int `json:"estimate_minutes"`
+      // 指定した場合のみプロジェクトを移す（0 でプロジェクトから外す）
+      ProjectID *uint `json:"project_id"`
+      // 繰り返しの Todo で、変更を反映する範囲。this（この回のみ、デフォルト）、following（この回以降）、all（すべての回）
+      // 期限の変更は常にこの回のみに反映する
+      Scope string `json:"scope"`
 }
 
 type CreateUserInput struct {
       Name string `json:"name" binding:"required"`
       Email string `json:"email" binding:"required"`
       Password string `json:"password" binding:"required"`
+      // IANA のタイムゾーン名（例: Asia/Tokyo）。省略した場合は DEFAULT_TIME_ZONE
+      TimeZone string `json:"time_zone"`
 }
 
 type UpdateUserInput struct {
       Name string `json:"name"`
       Email string `json:"email"`
       Password string `json:"password"`
+      TimeZone string `json:"time_zone"`
+}
+
+// UpdateProfileInput は PUT /api/me でログインしているユーザー自身の設定を変更する入力です。空の項目は変更しません。
+type UpdateProfileInput struct {
+      Name string `json:"name"`
+      // IANA のタイムゾーン名（例: Asia/Tokyo）
+      TimeZone string `json:"time_zone"`
 }
 
 type AuthInput struct {
@@ -51,4 +138,5 @@ type AuthOutput struct {
       ID uint `json:"id"`
       Name string `json:"name"`
       Email string `json:"email"`
+      TimeZone string `json:"time_zone"`
 }
\ No newline at end of file
@@ -0,0 +1,19 @@
+package requests
+
+import "time"
+
+type TodoOperationOutput struct {
+      ID string `json:"id"`
+      // 操作で変更された Todo の履歴
+      Events []TodoEventOutput `json:"events"`
+      CreatedAt time.Time `json:"created_at"`
+      // この日時を過ぎると取り消せない
+      ExpiresAt time.Time `json:"expires_at"`
+}
+
+type UndoOutput struct {
+      // 取り消しによる変更の操作の ID。これを取り消すとやり直しになる
+      OperationID *string `json:"operation_id"`
+      // 元に戻した Todo
+      TodoIDs []uint `json:"todo_ids"`
+}
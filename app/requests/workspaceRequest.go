@@ -0,0 +1,33 @@
+package requests
+
+import "time"
+
+type WorkspaceOutput struct {
+      ID uint `json:"id"`
+      Name string `json:"name"`
+      // 呼び出したユーザーの役割（owner、admin、member）
+      Role string `json:"role"`
+      CreatedAt time.Time `json:"created_at"`
+}
+
+type WorkspaceMemberOutput struct {
+      User AuthOutput `json:"user"`
+      Role string `json:"role"`
+      JoinedAt time.Time `json:"joined_at"`
+}
+
+type WorkspaceInput struct {
+      Name string `json:"name" binding:"required"`
+}
+
+type WorkspaceMemberInput struct {
+      // user_id または email のどちらかでユーザーを指定する
+      UserID uint `json:"user_id"`
+      Email string `json:"email"`
+      // owner、admin、member のいずれか。省略した場合は member
+      Role string `json:"role"`
+}
+
+type WorkspaceMemberRoleInput struct {
+      Role string `json:"role" binding:"required"`
+}
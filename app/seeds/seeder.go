@@ -11,25 +11,50 @@ import (
 
 // Seeder 関数はデータベースに初期データを投入するための関数です。
 func Seeder(db *gorm.DB) error {
-      // Todo モデルを使用してデータを作成
-      todos := []models.Todo{
-            {Title: "title1", Description: "description1", Category: "category1", Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), State: false},
-            {Title: "title2", Description: "description2", Category: "category2", Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), State: false},
-            {Title: "title3", Description: "description3", Category: "category3", Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), State: false},
-      }
-
       user := []models.User{
-            {Name: "name1", Email: "email1", Password: "password1"},
-            {Name: "name2", Email: "email2", Password: "password2"},
-            {Name: "name3", Email: "email3", Password: "password3"},
+            {Name: "name1", Email: "email1", Password: "password1", TimeZone: "Asia/Tokyo"},
+            {Name: "name2", Email: "email2", Password: "password2", TimeZone: "Asia/Tokyo"},
+            {Name: "name3", Email: "email3", Password: "password3", TimeZone: "America/Los_Angeles"},
       }
  
       // データをデータベースに保存
-      for _, user := range user {
-            if err := db.Create(&user).Error; err != nil {
+      for i := range user {
+            if err := db.Create(&user[i]).Error; err != nil {
                   return err
             }
       }
+
+      // 全員が参加するワークスペースを用意（最初のユーザーが持ち主）
+      workspace := models.Workspace{Name: "Seed"}
+      if err := db.Create(&workspace).Error; err != nil {
+            return err
+      }
+      for i, u := range user {
+            role := models.WorkspaceRoleMember
+            if i == 0 {
+                  role = models.WorkspaceRoleOwner
+            }
+            member := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: u.ID, Role: role}
+            if err := db.Omit("Workspace", "User").Create(&member).Error; err != nil {
+                  return err
+            }
+      }
+
+      // Todo に設定するデフォルトのステータスを用意
+      if err := models.EnsureDefaultStatuses(db, workspace.ID); err != nil {
+            return err
+      }
+      status, err := models.GetDefaultStatus(db.Where("workspace_id = ?", workspace.ID))
+      if err != nil {
+            return err
+      }
+
+      // Todo モデルを使用してデータを作成
+      todos := []models.Todo{
+            {WorkspaceID: workspace.ID, Title: "title1", Description: "description1", Tags: []*models.Tag{{WorkspaceID: workspace.ID, Name: "category1"}}, Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), DeadlineAllDay: true, StatusID: status.ID},
+            {WorkspaceID: workspace.ID, Title: "title2", Description: "description2", Tags: []*models.Tag{{WorkspaceID: workspace.ID, Name: "category2"}}, Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), DeadlineAllDay: true, StatusID: status.ID},
+            {WorkspaceID: workspace.ID, Title: "title3", Description: "description3", Tags: []*models.Tag{{WorkspaceID: workspace.ID, Name: "category3"}}, Deadline: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC), DeadlineAllDay: true, StatusID: status.ID},
+      }
       for _, todo := range todos {
             if err := db.Create(&todo).Error; err != nil {
                   return err